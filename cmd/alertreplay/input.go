@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+)
+
+type record struct {
+	Time   time.Time
+	Metric pubsub.ControllerIntMetric
+}
+
+type ndjsonRecord struct {
+	ControllerID string          `json:"controllerID"`
+	Key          string          `json:"key"`
+	Value        float64         `json:"value"`
+	Timestamp    json.RawMessage `json:"timestamp"`
+}
+
+// parseTimestamp - unix timestamp in seconds or RFC3339 date
+func parseTimestamp(ts string) (time.Time, error) {
+	ts = strings.Trim(strings.TrimSpace(ts), `"`)
+	if f, err := strconv.ParseFloat(ts, 64); err == nil {
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+	}
+	return time.Parse(time.RFC3339, ts)
+}
+
+// readCSV - controllerID,key,value,timestamp lines, an optional header line is skipped
+func readCSV(r io.Reader) ([]record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+	records := []record{}
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		t, err := parseTimestamp(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		records = append(records, record{
+			Time:   t,
+			Metric: pubsub.ControllerIntMetric{ControllerID: fields[0], Key: fields[1], Value: value},
+		})
+	}
+	return records, nil
+}
+
+func readNDJSON(r io.Reader) ([]record, error) {
+	scanner := bufio.NewScanner(r)
+	records := []record{}
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		nr := ndjsonRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &nr); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		t, err := parseTimestamp(string(nr.Timestamp))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		records = append(records, record{
+			Time:   t,
+			Metric: pubsub.ControllerIntMetric{ControllerID: nr.ControllerID, Key: nr.Key, Value: nr.Value},
+		})
+	}
+	return records, scanner.Err()
+}
+
+// readRecords - returns the records sorted by time
+func readRecords(r io.Reader, format string) ([]record, error) {
+	var records []record
+	var err error
+	switch format {
+	case "csv":
+		records, err = readCSV(r)
+	case "ndjson":
+		records, err = readNDJSON(r)
+	default:
+		return nil, errors.New("Unknown format, expected csv or ndjson")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/alerts"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+var (
+	format         = pflag.String("format", "", "Input format, csv or ndjson, guessed from the file extension by default")
+	stage          = pflag.String("stage", db.AlertStageVeg, "Grow stage preset used for the thresholds (seedling, veg, bloom, drying)")
+	thresholdsPath = pflag.String("thresholds", "", "JSON file with custom thresholds, as returned by GET /box/:id/alerts")
+	overrides      = pflag.StringArray("override", nil, "Overrides a rule's settings, ie. TEMP:hysteresis=2:minduration=10m:cooldown=1h:renotify=30m")
+	locale         = pflag.String("locale", notifications.DefaultLocale, "Locale of the printed notifications")
+	units          = pflag.String("units", notifications.UnitsMetric, "Units of the printed notifications (metric, imperial)")
+)
+
+// printNotifier - prints the alerts as they would fire
+type printNotifier struct {
+	started map[string]time.Time
+	count   int
+}
+
+func (p *printNotifier) alertKey(a alerts.Alert) string {
+	return fmt.Sprintf("%s.%d.%s", a.Metric.ControllerID, a.Box, a.RuleKey)
+}
+
+func (p *printNotifier) AlertStarted(a alerts.Alert, plants []appbackend.Plant) {
+	p.started[p.alertKey(a)] = a.Time
+	p.count++
+	fmt.Printf("%s START %s %s controller: %s box: %d value: %.2f min: %.2f max: %.2f timerPower: %.0f\n", a.Time.Format(time.RFC3339), a.RuleKey, a.Type, a.Metric.ControllerID, a.Box, a.Metric.Value, a.MinValue, a.MaxValue, a.TimerPower)
+	for _, plant := range plants {
+		title, body := a.Content(plant)
+		fmt.Printf("\t%s\n\t%s\n", title.Render(*locale, *units), strings.Replace(body.Render(*locale, *units), "\n", "\n\t", -1))
+	}
+}
+
+func (p *printNotifier) AlertUpdated(a alerts.Alert) {}
+
+func (p *printNotifier) AlertRepeated(a alerts.Alert, plants []appbackend.Plant) {
+	fmt.Printf("%s REPEAT %s %s controller: %s box: %d value: %.2f since: %s\n", a.Time.Format(time.RFC3339), a.RuleKey, a.Type, a.Metric.ControllerID, a.Box, a.Metric.Value, a.StartedAt.Format(time.RFC3339))
+}
+
+func (p *printNotifier) AlertEnded(a alerts.Alert) {
+	delete(p.started, p.alertKey(a))
+	fmt.Printf("%s END %s %s controller: %s box: %d value: %.2f duration: %s\n", a.Time.Format(time.RFC3339), a.RuleKey, a.Type, a.Metric.ControllerID, a.Box, a.Metric.Value, a.Time.Sub(a.StartedAt))
+}
+
+// parseOverride - KEY:hysteresis=2:minduration=10m:cooldown=1h:renotify=30m
+func parseOverride(o string) (string, alerts.RuleOptions, error) {
+	parts := strings.Split(o, ":")
+	opts := alerts.RuleOptions{}
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return "", opts, fmt.Errorf("Invalid override option %s", p)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "hysteresis":
+			var h float64
+			h, err = strconv.ParseFloat(kv[1], 64)
+			opts.Hysteresis = &h
+		case "minduration":
+			var d time.Duration
+			d, err = time.ParseDuration(kv[1])
+			opts.MinDuration = &d
+		case "cooldown":
+			var d time.Duration
+			d, err = time.ParseDuration(kv[1])
+			opts.Cooldown = &d
+		case "renotify":
+			var d time.Duration
+			d, err = time.ParseDuration(kv[1])
+			opts.Renotify = &d
+		default:
+			err = fmt.Errorf("Unknown override option %s", kv[0])
+		}
+		if err != nil {
+			return "", opts, err
+		}
+	}
+	return parts[0], opts, nil
+}
+
+func loadThresholds() (alerts.Thresholds, error) {
+	if *thresholdsPath == "" {
+		t, ok := alerts.StagePresets[*stage]
+		if !ok {
+			return t, fmt.Errorf("Unknown stage %s", *stage)
+		}
+		return t, nil
+	}
+	data, err := ioutil.ReadFile(*thresholdsPath)
+	if err != nil {
+		return alerts.Thresholds{}, err
+	}
+	// accepts the GET /box/:id/alerts response, or the thresholds alone,
+	// missing values are taken from the stage preset
+	t := alerts.StagePresets[*stage]
+	response := struct {
+		Thresholds *alerts.Thresholds `json:"thresholds"`
+	}{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return t, err
+	}
+	if response.Thresholds != nil {
+		return *response.Thresholds, nil
+	}
+	return t, json.Unmarshal(data, &t)
+}
+
+func main() {
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage %s [flags] metrics.csv|metrics.ndjson\n", os.Args[0])
+		pflag.PrintDefaults()
+	}
+	pflag.Parse()
+	if pflag.NArg() != 1 {
+		pflag.Usage()
+		os.Exit(1)
+	}
+	input := pflag.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(input), ".")
+	}
+
+	thresholds, err := loadThresholds()
+	if err != nil {
+		logrus.Fatalf("loadThresholds in main %q", err)
+	}
+	ruleOverrides := map[string]alerts.RuleOptions{}
+	for _, o := range *overrides {
+		key, opts, err := parseOverride(o)
+		if err != nil {
+			logrus.Fatalf("parseOverride in main %q - %s", err, o)
+		}
+		ruleOverrides[key] = opts
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		logrus.Fatalf("os.Open in main %q", err)
+	}
+	defer f.Close()
+	records, err := readRecords(f, *format)
+	if err != nil {
+		logrus.Fatalf("readRecords in main %q", err)
+	}
+
+	var now time.Time
+	clock := func() time.Time { return now }
+	controllers := alerts.NewMemoryControllers()
+	notifier := &printNotifier{started: map[string]time.Time{}}
+	evaluator := alerts.Evaluator{
+		Controllers: controllers,
+		State:       alerts.NewMemoryState(clock),
+		Incidents:   alerts.NewMemoryIncidents(),
+		Thresholds:  alerts.StaticThresholds(thresholds),
+		Plants:      alerts.StaticPlants{{Name: "replay", AlertsEnabled: true}},
+		Notifier:    notifier,
+		Now:         clock,
+		Overrides:   ruleOverrides,
+	}
+
+	for _, r := range records {
+		now = r.Time
+		controllers.Observe(r.Metric)
+		evaluator.Evaluate("", r.Metric)
+	}
+	fmt.Printf("%d metrics replayed, %d alerts fired, %d still open\n", len(records), notifier.count, len(notifier.started))
+}
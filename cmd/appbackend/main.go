@@ -23,6 +23,7 @@ import (
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
 	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
 	"github.com/SuperGreenLab/AppBackend/internal/data/storage"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
 	"github.com/SuperGreenLab/AppBackend/internal/server"
 	"github.com/SuperGreenLab/AppBackend/internal/services"
 	log "github.com/sirupsen/logrus"
@@ -35,6 +36,7 @@ func main() {
 	db.Init()
 	kv.Init()
 	storage.Init()
+	timeseries.Init()
 
 	server.Start()
 
@@ -0,0 +1,22 @@
+alter table users add column email varchar;
+alter table users add column digest varchar(10) not null default 'none';
+
+create table if not exists digests(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+
+  dtype varchar(10) not null,
+  periodstart timestamptz not null,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create unique index d_uid_dtype_ps on digests (userid, dtype, periodstart);
+
+drop trigger if exists uat_digests on digests;
+
+create trigger uat_digests
+before update on digests
+for each row
+  execute procedure moddatetime(uat);
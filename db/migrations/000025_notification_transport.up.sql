@@ -0,0 +1 @@
+alter table userends add notification_transport varchar(32) not null default 'fcm';
@@ -0,0 +1,36 @@
+create table if not exists reminders(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  plantid uuid not null,
+
+  title varchar(256) not null,
+  body text,
+
+  stype varchar(16) not null,
+  schedule varchar(64),
+  intervalhours int,
+  relativeto varchar(32),
+  relativedays int,
+  timezone varchar(64) not null default 'UTC',
+
+  nextat timestamptz,
+  lastsentat timestamptz,
+  completedat timestamptz,
+
+  done boolean not null default false,
+  deleted boolean not null default false,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index re_uid on reminders (userid);
+create index re_pid on reminders (plantid);
+create index re_nextat on reminders (nextat) where done = false and deleted = false;
+
+drop trigger if exists uat_reminders on reminders;
+
+create trigger uat_reminders
+before update on reminders
+for each row
+  execute procedure moddatetime(uat);
@@ -0,0 +1,35 @@
+create table if not exists alertrules(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  boxid uuid not null,
+
+  name varchar(64) not null,
+  metric varchar(64) not null,
+  comparison varchar(8) not null,
+
+  minday double precision,
+  maxday double precision,
+  minnight double precision,
+  maxnight double precision,
+
+  hysteresis double precision not null default 0,
+  minduration int not null default 0,
+  cooldown int not null default 0,
+  renotify int not null default 0,
+
+  enabled boolean not null default true,
+  deleted boolean not null default false,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index ar_uid on alertrules (userid);
+create index ar_bid on alertrules (boxid);
+
+drop trigger if exists uat_alertrules on alertrules;
+
+create trigger uat_alertrules
+before update on alertrules
+for each row
+  execute procedure moddatetime(uat);
@@ -0,0 +1,39 @@
+create table if not exists alertincidents(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  plantid uuid not null,
+  boxid uuid not null,
+
+  controllerid varchar(64) not null,
+  devicebox int not null,
+  rulekey varchar(64) not null,
+  metric varchar(64) not null,
+  atype varchar(16) not null,
+
+  minvalue double precision,
+  maxvalue double precision,
+  peakvalue double precision not null,
+
+  startedat timestamptz not null default now(),
+  endedat timestamptz,
+  duration int,
+
+  acknowledged boolean not null default false,
+  acknowledgedat timestamptz,
+
+  feedentryid uuid,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index ai_uid on alertincidents (userid);
+create index ai_pid on alertincidents (plantid, startedat);
+create index ai_open on alertincidents (controllerid, devicebox, rulekey) where endedat is null;
+
+drop trigger if exists uat_alertincidents on alertincidents;
+
+create trigger uat_alertincidents
+before update on alertincidents
+for each row
+  execute procedure moddatetime(uat);
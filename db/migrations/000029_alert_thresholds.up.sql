@@ -0,0 +1,28 @@
+create table if not exists alertthresholds(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  boxid uuid not null,
+
+  stage varchar(16) not null default 'auto',
+
+  mintempday double precision,
+  maxtempday double precision,
+  mintempnight double precision,
+  maxtempnight double precision,
+  minhumiday double precision,
+  maxhumiday double precision,
+  minhuminight double precision,
+  maxhuminight double precision,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create unique index at_bid on alertthresholds (boxid);
+
+drop trigger if exists uat_alertthresholds on alertthresholds;
+
+create trigger uat_alertthresholds
+before update on alertthresholds
+for each row
+  execute procedure moddatetime(uat);
@@ -0,0 +1,2 @@
+alter table users add column locale varchar(16) not null default 'en';
+alter table users add column units varchar(10) not null default 'metric';
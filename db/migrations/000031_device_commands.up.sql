@@ -0,0 +1,29 @@
+create table if not exists devicecommands(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  deviceid uuid not null,
+
+  controllerid varchar(64) not null,
+  key varchar(64) not null,
+  value varchar(256) not null,
+  firmware bigint not null default 0,
+
+  status varchar(16) not null default 'pending',
+  error text,
+
+  sentat timestamptz,
+  ackedat timestamptz,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index dc_did on devicecommands (deviceid, cat);
+create index dc_sent on devicecommands (sentat) where status = 'sent';
+
+drop trigger if exists uat_devicecommands on devicecommands;
+
+create trigger uat_devicecommands
+before update on devicecommands
+for each row
+  execute procedure moddatetime(uat);
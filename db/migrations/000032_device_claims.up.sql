@@ -0,0 +1,30 @@
+create table if not exists deviceclaims(
+  id uuid primary key default uuid_generate_v4(),
+  identifier varchar(16) not null,
+  userid uuid not null,
+
+  claimedat timestamptz not null default now(),
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create unique index dcl_identifier on deviceclaims (identifier);
+create index dcl_uid on deviceclaims (userid);
+
+drop trigger if exists uat_deviceclaims on deviceclaims;
+
+create trigger uat_deviceclaims
+before update on deviceclaims
+for each row
+  execute procedure moddatetime(uat);
+
+-- identifiers registered by a single user are claimed by them, the ones
+-- registered by several users stay unclaimed until claimed with a claim code
+insert into deviceclaims (identifier, userid, claimedat)
+  select d.identifier, (array_agg(d.userid))[1], min(d.cat)
+  from devices d
+  where d.deleted = false and d.identifier <> ''
+  group by d.identifier
+  having count(distinct d.userid) = 1
+on conflict (identifier) do nothing;
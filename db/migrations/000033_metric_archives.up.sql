@@ -0,0 +1,17 @@
+create table if not exists metricarchives(
+  controllerid varchar(64) not null,
+  name varchar(64) not null,
+  hour timestamptz not null,
+
+  boxid uuid not null,
+
+  avgvalue double precision not null,
+  minvalue double precision not null,
+  maxvalue double precision not null,
+
+  cat timestamptz default now(),
+
+  primary key (controllerid, name, hour)
+);
+
+create index ma_bid on metricarchives (boxid, hour);
@@ -0,0 +1,28 @@
+create table if not exists harvests(
+  id uuid primary key default uuid_generate_v4(),
+  userid uuid not null,
+  plantid uuid not null,
+
+  wetweight double precision,
+  dryweight double precision,
+  curestart timestamptz,
+  cureend timestamptz,
+  notes text,
+
+  feedentryid uuid,
+
+  deleted boolean not null default false,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index ha_uid on harvests (userid);
+create index ha_pid on harvests (plantid);
+
+drop trigger if exists uat_harvests on harvests;
+
+create trigger uat_harvests
+before update on harvests
+for each row
+  execute procedure moddatetime(uat);
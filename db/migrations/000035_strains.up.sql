@@ -0,0 +1,75 @@
+-- the trigram indexes need the pg_trgm extension, created by
+-- 000006_create_products_tables, creating it requires superuser rights
+
+create table if not exists seedbanks(
+  id uuid primary key default uuid_generate_v4(),
+  name varchar(256) not null,
+  verified boolean not null default false,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create unique index sb_name on seedbanks (name);
+create index sb_name_trgm on seedbanks using gin (name gin_trgm_ops);
+
+create table if not exists seedbankaliases(
+  id uuid primary key default uuid_generate_v4(),
+  seedbankid uuid not null,
+  alias varchar(256) not null,
+
+  cat timestamptz default now()
+);
+
+create unique index sba_alias on seedbankaliases (alias);
+
+create table if not exists strains(
+  id uuid primary key default uuid_generate_v4(),
+  seedbankid uuid,
+  name varchar(256) not null,
+  ptype varchar(16),
+  verified boolean not null default false,
+
+  cat timestamptz default now(),
+  uat timestamptz default now()
+);
+
+create index st_sbid on strains (seedbankid);
+-- strains without a seed bank are unique by name too
+create unique index st_name_sbid on strains (name, coalesce(seedbankid, '00000000-0000-0000-0000-000000000000'));
+create index st_name_trgm on strains using gin (name gin_trgm_ops);
+
+create table if not exists strainaliases(
+  id uuid primary key default uuid_generate_v4(),
+  strainid uuid not null,
+  alias varchar(256) not null,
+
+  cat timestamptz default now()
+);
+
+create unique index sa_alias on strainaliases (strainid, alias);
+create index sa_alias_trgm on strainaliases using gin (alias gin_trgm_ops);
+
+create table if not exists plantstrains(
+  plantid uuid primary key,
+  strainid uuid,
+  seedbankid uuid,
+
+  mappedat timestamptz not null default now()
+);
+
+create index ps_stid on plantstrains (strainid);
+
+drop trigger if exists uat_seedbanks on seedbanks;
+
+create trigger uat_seedbanks
+before update on seedbanks
+for each row
+  execute procedure moddatetime(uat);
+
+drop trigger if exists uat_strains on strains;
+
+create trigger uat_strains
+before update on strains
+for each row
+  execute procedure moddatetime(uat);
@@ -0,0 +1,31 @@
+create table if not exists plantsearches(
+  plantid uuid primary key,
+  document tsvector not null,
+
+  ptype varchar(32),
+  stage varchar(16),
+  nextstageat timestamptz,
+  hasmedia boolean not null default false,
+  lastupdate timestamptz,
+
+  indexedat timestamptz not null default now()
+);
+
+create index psr_document on plantsearches using gin (document);
+create index psr_lastupdate on plantsearches (lastupdate);
+
+create index fe_message_search on feedentries using gin (to_tsvector('simple', coalesce(params->>'message', ''))) where deleted = false;
+
+-- public plants are searchable before the plantsearch service indexes them,
+-- the -infinity indexedat gets them fully indexed on its next runs
+insert into plantsearches (plantid, document, ptype, lastupdate, indexedat)
+select p.id,
+  setweight(to_tsvector('simple', coalesce(p.name, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(p.settings->>'strain', '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(p.settings->>'seedBank', '')), 'B'),
+  p.settings->>'plantType',
+  (select max(fe.createdat) from feedentries fe where fe.feedid = p.feedid and fe.deleted = false),
+  '-infinity'
+from plants p
+where p.is_public = true and p.deleted = false
+on conflict do nothing;
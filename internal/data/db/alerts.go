@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	// AlertComparisonAbove - alert when the value goes above the max threshold
+	AlertComparisonAbove = "above"
+	// AlertComparisonBelow - alert when the value goes below the min threshold
+	AlertComparisonBelow = "below"
+	// AlertComparisonOutside - alert when the value goes outside of the min/max range
+	AlertComparisonOutside = "outside"
+)
+
+// AlertRule - user defined alert on a box's metric, thresholds are interpolated
+// between their night and day values with the box's timerPower.
+type AlertRule struct {
+	ID     uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID uuid.UUID     `db:"userid" json:"userID"`
+	BoxID  uuid.UUID     `db:"boxid" json:"boxID"`
+
+	Name       string `db:"name" json:"name"`
+	Metric     string `db:"metric" json:"metric"`
+	Comparison string `db:"comparison" json:"comparison"`
+
+	MinDay   null.Float `db:"minday" json:"minDay"`
+	MaxDay   null.Float `db:"maxday" json:"maxDay"`
+	MinNight null.Float `db:"minnight" json:"minNight"`
+	MaxNight null.Float `db:"maxnight" json:"maxNight"`
+
+	Hysteresis  float64 `db:"hysteresis" json:"hysteresis"`
+	MinDuration int     `db:"minduration" json:"minDuration"`
+	Cooldown    int     `db:"cooldown" json:"cooldown"`
+	Renotify    int     `db:"renotify" json:"renotify"`
+
+	Enabled bool `db:"enabled" json:"enabled"`
+	Deleted bool `db:"deleted" json:"deleted"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// GetID -
+func (o AlertRule) GetID() uuid.NullUUID {
+	return o.ID
+}
+
+// SetUserID -
+func (o *AlertRule) SetUserID(userID uuid.UUID) {
+	o.UserID = userID
+}
+
+// GetUserID -
+func (o AlertRule) GetUserID() uuid.UUID {
+	return o.UserID
+}
+
+// DeviceAlertRule - AlertRule with the controller and box slot it applies to
+type DeviceAlertRule struct {
+	AlertRule `db:",inline"`
+
+	ControllerID string `db:"controllerid"`
+	DeviceBox    int    `db:"devicebox"`
+}
+
+// AlertIncident - an alert on a plant, from the moment its metric went out of bounds until it came back
+type AlertIncident struct {
+	ID      uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID  uuid.UUID     `db:"userid" json:"userID"`
+	PlantID uuid.UUID     `db:"plantid" json:"plantID"`
+	BoxID   uuid.UUID     `db:"boxid" json:"boxID"`
+
+	ControllerID string `db:"controllerid" json:"controllerID"`
+	DeviceBox    int    `db:"devicebox" json:"deviceBox"`
+	RuleKey      string `db:"rulekey" json:"ruleKey"`
+	Metric       string `db:"metric" json:"metric"`
+	Type         string `db:"atype" json:"type"`
+
+	MinValue  null.Float `db:"minvalue" json:"minValue"`
+	MaxValue  null.Float `db:"maxvalue" json:"maxValue"`
+	PeakValue float64    `db:"peakvalue" json:"peakValue"`
+
+	StartedAt time.Time `db:"startedat" json:"startedAt"`
+	EndedAt   null.Time `db:"endedat,omitempty" json:"endedAt"`
+	Duration  null.Int  `db:"duration,omitempty" json:"duration"`
+
+	Acknowledged   bool      `db:"acknowledged" json:"acknowledged"`
+	AcknowledgedAt null.Time `db:"acknowledgedat,omitempty" json:"acknowledgedAt"`
+
+	FeedEntryID uuid.NullUUID `db:"feedentryid,omitempty" json:"feedEntryID"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// GetID -
+func (o AlertIncident) GetID() uuid.NullUUID {
+	return o.ID
+}
+
+// SetUserID -
+func (o *AlertIncident) SetUserID(userID uuid.UUID) {
+	o.UserID = userID
+}
+
+// GetUserID -
+func (o AlertIncident) GetUserID() uuid.UUID {
+	return o.UserID
+}
+
+const (
+	// AlertStageAuto - thresholds follow the grow stage computed from the box's plants
+	AlertStageAuto = "auto"
+	// AlertStageCustom - thresholds are the user's values
+	AlertStageCustom = "custom"
+	// AlertStageSeedling -
+	AlertStageSeedling = "seedling"
+	// AlertStageVeg -
+	AlertStageVeg = "veg"
+	// AlertStageBloom -
+	AlertStageBloom = "bloom"
+	// AlertStageDrying -
+	AlertStageDrying = "drying"
+)
+
+// AlertThresholds - box's temperature and humidity thresholds, the values are
+// only used when Stage is custom, otherwise the stage's preset applies.
+type AlertThresholds struct {
+	ID     uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID uuid.UUID     `db:"userid" json:"userID"`
+	BoxID  uuid.UUID     `db:"boxid" json:"boxID"`
+
+	Stage string `db:"stage" json:"stage"`
+
+	MinTempDay   null.Float `db:"mintempday" json:"minTempDay"`
+	MaxTempDay   null.Float `db:"maxtempday" json:"maxTempDay"`
+	MinTempNight null.Float `db:"mintempnight" json:"minTempNight"`
+	MaxTempNight null.Float `db:"maxtempnight" json:"maxTempNight"`
+	MinHumiDay   null.Float `db:"minhumiday" json:"minHumiDay"`
+	MaxHumiDay   null.Float `db:"maxhumiday" json:"maxHumiDay"`
+	MinHumiNight null.Float `db:"minhuminight" json:"minHumiNight"`
+	MaxHumiNight null.Float `db:"maxhuminight" json:"maxHumiNight"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
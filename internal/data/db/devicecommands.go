@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	// DeviceCommandStatusPending - stored, not delivered yet
+	DeviceCommandStatusPending = "pending"
+	// DeviceCommandStatusSent - delivered to the command channel, waiting for the device's ack
+	DeviceCommandStatusSent = "sent"
+	// DeviceCommandStatusAcked - the device applied the value
+	DeviceCommandStatusAcked = "acked"
+	// DeviceCommandStatusFailed - delivery failed or the device refused the value
+	DeviceCommandStatusFailed = "failed"
+	// DeviceCommandStatusExpired - the device never acked the command
+	DeviceCommandStatusExpired = "expired"
+)
+
+// DeviceCommand - a parameter write queued for a controller
+type DeviceCommand struct {
+	ID       uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID   uuid.UUID     `db:"userid" json:"userID"`
+	DeviceID uuid.UUID     `db:"deviceid" json:"deviceID"`
+
+	ControllerID string `db:"controllerid" json:"controllerID"`
+	Key          string `db:"key" json:"key"`
+	Value        string `db:"value" json:"value"`
+	Firmware     int64  `db:"firmware" json:"firmware"`
+
+	Status string      `db:"status" json:"status"`
+	Error  null.String `db:"error" json:"error"`
+
+	SentAt  null.Time `db:"sentat" json:"sentAt"`
+	AckedAt null.Time `db:"ackedat" json:"ackedAt"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// GetID -
+func (o DeviceCommand) GetID() uuid.NullUUID {
+	return o.ID
+}
+
+// SetUserID -
+func (o *DeviceCommand) SetUserID(userID uuid.UUID) {
+	o.UserID = userID
+}
+
+// GetUserID -
+func (o DeviceCommand) GetUserID() uuid.UUID {
+	return o.UserID
+}
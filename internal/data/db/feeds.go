@@ -205,7 +205,8 @@ type UserEnd struct {
 	ID     uuid.NullUUID `db:"id,omitempty" json:"id"`
 	UserID uuid.UUID     `db:"userid" json:"userID"`
 
-	NotificationToken null.String `db:"notification_token" json:"notificationToken"`
+	NotificationToken     null.String `db:"notification_token" json:"notificationToken"`
+	NotificationTransport string      `db:"notification_transport,omitempty" json:"notificationTransport,omitempty"`
 
 	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
 	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
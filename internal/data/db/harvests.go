@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Harvest - harvest of a plant, weights are in grams, photos are the medias of
+// its feed entry
+type Harvest struct {
+	ID      uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID  uuid.UUID     `db:"userid" json:"userID"`
+	PlantID uuid.UUID     `db:"plantid" json:"plantID"`
+
+	WetWeight null.Float  `db:"wetweight" json:"wetWeight"`
+	DryWeight null.Float  `db:"dryweight" json:"dryWeight"`
+	CureStart null.Time   `db:"curestart" json:"cureStart"`
+	CureEnd   null.Time   `db:"cureend" json:"cureEnd"`
+	Notes     null.String `db:"notes" json:"notes"`
+
+	FeedEntryID uuid.NullUUID `db:"feedentryid,omitempty" json:"feedEntryID"`
+
+	Deleted bool `db:"deleted" json:"deleted"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// GetID -
+func (o Harvest) GetID() uuid.NullUUID {
+	return o.ID
+}
+
+// SetUserID -
+func (o *Harvest) SetUserID(userID uuid.UUID) {
+	o.UserID = userID
+}
+
+// GetUserID -
+func (o Harvest) GetUserID() uuid.UUID {
+	return o.UserID
+}
+
+// HarvestYield - harvests aggregated by the catalog strain their plants are
+// mapped to, StrainID isn't set for the plants without one
+type HarvestYield struct {
+	StrainID uuid.NullUUID `db:"strainid" json:"strainID"`
+	Strain   string        `db:"strain" json:"strain"`
+	SeedBank string        `db:"seedbank" json:"seedBank"`
+
+	Harvests int `db:"harvests" json:"harvests"`
+	Growers  int `db:"growers" json:"growers"`
+
+	AvgWetWeight   null.Float `db:"avgwetweight" json:"avgWetWeight"`
+	AvgDryWeight   null.Float `db:"avgdryweight" json:"avgDryWeight"`
+	TotalDryWeight null.Float `db:"totaldryweight" json:"totalDryWeight"`
+	MaxDryWeight   null.Float `db:"maxdryweight" json:"maxDryWeight"`
+	AvgCureDays    null.Float `db:"avgcuredays" json:"avgCureDays"`
+}
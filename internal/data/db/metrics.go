@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// MetricArchive - hourly aggregate of a controller metric, kept after the
+// time-series backend's retention
+type MetricArchive struct {
+	ControllerID string    `db:"controllerid" json:"controllerID"`
+	Name         string    `db:"name" json:"name"`
+	Hour         time.Time `db:"hour" json:"hour"`
+
+	BoxID uuid.UUID `db:"boxid" json:"boxID"`
+
+	Avg float64 `db:"avgvalue" json:"avg"`
+	Min float64 `db:"minvalue" json:"min"`
+	Max float64 `db:"maxvalue" json:"max"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+}
+
+// ArchivedBox - a box with a live plant, its metrics are archived
+type ArchivedBox struct {
+	BoxID        uuid.UUID `db:"boxid"`
+	ControllerID string    `db:"controllerid"`
+	DeviceBox    int       `db:"devicebox"`
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+	udb "upper.io/db.v3"
+)
+
+func GetEnabledDeviceAlertRules() ([]DeviceAlertRule, error) {
+	rules := []DeviceAlertRule{}
+	selector := Sess.Select("alertrules.*", "devices.identifier as controllerid", "boxes.devicebox as devicebox").
+		From("alertrules").
+		Join("boxes").On("boxes.id = alertrules.boxid").
+		Join("devices").On("devices.id = boxes.deviceid").
+		Where("alertrules.enabled = true").
+		And("alertrules.deleted = false").
+		And("boxes.deleted = false").
+		And("devices.deleted = false").
+		And("boxes.devicebox is not null")
+	err := selector.All(&rules)
+	return rules, err
+}
+
+func CreateAlertIncident(incident AlertIncident) (uuid.UUID, error) {
+	id, err := Sess.Collection("alertincidents").Insert(incident)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return uuid.FromStringOrNil(string(id.([]uint8))), nil
+}
+
+// GetOpenAlertIncident - returns the oldest open incident of the box's rule, if any
+func GetOpenAlertIncident(controllerID string, box int, ruleKey string) (AlertIncident, bool, error) {
+	incident := AlertIncident{}
+	err := Sess.Select("*").From("alertincidents").
+		Where("controllerid = ?", controllerID).
+		And("devicebox = ?", box).
+		And("rulekey = ?", ruleKey).
+		And("endedat is null").
+		OrderBy("startedat").
+		Limit(1).
+		One(&incident)
+	if err == udb.ErrNoMoreRows {
+		return incident, false, nil
+	}
+	return incident, err == nil, err
+}
+
+// UpdateOpenAlertIncidentsPeak - keeps the furthest value from the thresholds reached during the incident
+func UpdateOpenAlertIncidentsPeak(controllerID string, box int, ruleKey, alertType string, value float64) error {
+	peak := "greatest(peakvalue, ?)"
+	if alertType == "TOO_LOW" {
+		peak = "least(peakvalue, ?)"
+	}
+	_, err := Sess.Update("alertincidents").
+		Set("peakvalue", udb.Raw(peak, value)).
+		Where("controllerid = ?", controllerID).
+		And("devicebox = ?", box).
+		And("rulekey = ?", ruleKey).
+		And("endedat is null").
+		Exec()
+	return err
+}
+
+// EndOpenAlertIncidents - ends all open incidents for the box's rule and returns them
+func EndOpenAlertIncidents(controllerID string, box int, ruleKey string, endedAt time.Time) ([]AlertIncident, error) {
+	incidents := []AlertIncident{}
+	err := Sess.Select("*").From("alertincidents").
+		Where("controllerid = ?", controllerID).
+		And("devicebox = ?", box).
+		And("rulekey = ?", ruleKey).
+		And("endedat is null").
+		All(&incidents)
+	if err != nil {
+		return incidents, err
+	}
+	for i, incident := range incidents {
+		incidents[i].EndedAt = null.TimeFrom(endedAt)
+		incidents[i].Duration = null.IntFrom(int64(endedAt.Sub(incident.StartedAt).Seconds()))
+		_, err := Sess.Update("alertincidents").
+			Set("endedat", incidents[i].EndedAt).
+			Set("duration", incidents[i].Duration).
+			Where("id = ?", incident.ID.UUID).
+			Exec()
+		if err != nil {
+			return incidents, err
+		}
+	}
+	return incidents, nil
+}
+
+// GetPlantAlertIncidentsBetween - the plant's incidents that overlap the range, open ones included
+func GetPlantAlertIncidentsBetween(plantID uuid.UUID, from, to time.Time) ([]AlertIncident, error) {
+	incidents := []AlertIncident{}
+	err := Sess.Select("*").From("alertincidents").
+		Where("plantid = ?", plantID).
+		And("startedat < ?", to).
+		And(udb.Or(udb.Raw("endedat is null"), udb.Cond{"endedat >": from})).
+		OrderBy("startedat").
+		All(&incidents)
+	return incidents, err
+}
+
+func GetAlertIncident(id uuid.UUID) (AlertIncident, error) {
+	incident := AlertIncident{}
+	err := GetObjectWithID(id, "alertincidents", &incident)
+	return incident, err
+}
+
+func AcknowledgeAlertIncident(id uuid.UUID, date time.Time) error {
+	_, err := Sess.Update("alertincidents").Set("acknowledged", true).Set("acknowledgedat", date).Where("id = ?", id).Exec()
+	return err
+}
+
+func SetAlertIncidentFeedEntryID(id, feedEntryID uuid.UUID) error {
+	_, err := Sess.Update("alertincidents").Set("feedentryid", feedEntryID).Where("id = ?", id).Exec()
+	return err
+}
+
+func GetAlertThresholdsForBox(boxID uuid.UUID) (AlertThresholds, error) {
+	t := AlertThresholds{}
+	err := Sess.Collection("alertthresholds").Find("boxid", boxID).One(&t)
+	return t, err
+}
+
+// SetAlertThresholds - creates or replaces the box's thresholds, there's at most one row per box
+func SetAlertThresholds(t AlertThresholds) (AlertThresholds, error) {
+	existing, err := GetAlertThresholdsForBox(t.BoxID)
+	if err == udb.ErrNoMoreRows {
+		id, err := Sess.Collection("alertthresholds").Insert(t)
+		if err != nil {
+			return t, err
+		}
+		t.ID = uuid.NullUUID{UUID: uuid.FromStringOrNil(string(id.([]uint8))), Valid: true}
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	t.ID = existing.ID
+	t.CreatedAt = existing.CreatedAt
+	t.UpdatedAt = time.Time{}
+	err = Sess.Collection("alertthresholds").Find("id", existing.ID.UUID).Update(t)
+	return t, err
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+)
+
+// controllerOwnerCond - restricts the devices table aliased as devices to the
+// identifier's owner: the user holding its claim, or its earliest registrant
+// while it's unclaimed
+func controllerOwnerCond(devices string) udb.RawValue {
+	return udb.Raw(fmt.Sprintf(`%[1]s.userid = coalesce(
+		(select dcl.userid from deviceclaims dcl where dcl.identifier = %[1]s.identifier),
+		(select od.userid from devices od where od.identifier = %[1]s.identifier and od.deleted = false order by od.cat limit 1))`, devices))
+}
+
+// GetControllerOwnerBox - the box at the controller's slot, on its owner's device
+func GetControllerOwnerBox(controllerID string, box int) (appbackend.Box, error) {
+	b := appbackend.Box{}
+	err := Sess.Select("boxes.*").From("boxes").
+		Join("devices").On("devices.id = boxes.deviceid").
+		Where("devices.identifier = ?", controllerID).
+		And("boxes.devicebox = ?", box).
+		And("boxes.deleted = false").
+		And("devices.deleted = false").
+		And(controllerOwnerCond("devices")).
+		OrderBy("boxes.cat").
+		Limit(1).
+		One(&b)
+	return b, err
+}
+
+// GetControllerOwnerDevice - the device, only if its user owns the controller,
+// other registrants' devices return ErrNoMoreRows
+func GetControllerOwnerDevice(deviceID uuid.UUID) (appbackend.Device, error) {
+	d := appbackend.Device{}
+	err := Sess.Select("devices.*").From("devices").
+		Where("devices.id = ?", deviceID).
+		And("devices.deleted = false").
+		And(controllerOwnerCond("devices")).
+		One(&d)
+	return d, err
+}
+
+// GetFirstDeviceRegistrant - user who registered the identifier first, the
+// identifier's owner while it's unclaimed
+func GetFirstDeviceRegistrant(identifier string) (uuid.UUID, error) {
+	d := appbackend.Device{}
+	err := Sess.Select("userid").From("devices").
+		Where("identifier = ?", identifier).
+		And("deleted = false").
+		OrderBy("cat").
+		Limit(1).
+		One(&d)
+	return d.UserID, err
+}
+
+func GetDeviceClaim(identifier string) (DeviceClaim, error) {
+	claim := DeviceClaim{}
+	err := Sess.Collection("deviceclaims").Find("identifier", identifier).One(&claim)
+	return claim, err
+}
+
+// IsDeviceClaimedBy - false when the identifier is not claimed or claimed by another user
+func IsDeviceClaimedBy(identifier string, userID uuid.UUID) (bool, error) {
+	claim, err := GetDeviceClaim(identifier)
+	if err == udb.ErrNoMoreRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return claim.UserID == userID, nil
+}
+
+// ClaimDevice - the unique index on identifier makes concurrent claims fail
+func ClaimDevice(identifier string, userID uuid.UUID, claimedAt time.Time) (DeviceClaim, error) {
+	claim := DeviceClaim{Identifier: identifier, UserID: userID, ClaimedAt: claimedAt}
+	id, err := Sess.Collection("deviceclaims").Insert(claim)
+	if err != nil {
+		return claim, err
+	}
+	claim.ID = uuid.NullUUID{UUID: uuid.FromStringOrNil(string(id.([]uint8))), Valid: true}
+	return claim, nil
+}
+
+func TransferDeviceClaim(identifier string, fromUserID, toUserID uuid.UUID, claimedAt time.Time) (bool, error) {
+	res, err := Sess.Update("deviceclaims").
+		Set("userid", toUserID).
+		Set("claimedat", claimedAt).
+		Where("identifier = ?", identifier).
+		And("userid = ?", fromUserID).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+func ReleaseDeviceClaim(identifier string, userID uuid.UUID) (bool, error) {
+	res, err := Sess.DeleteFrom("deviceclaims").
+		Where("identifier = ?", identifier).
+		And("userid = ?", userID).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+	udb "upper.io/db.v3"
+)
+
+func CreateDeviceCommand(cmd DeviceCommand) (uuid.UUID, error) {
+	id, err := Sess.Collection("devicecommands").Insert(cmd)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return uuid.FromStringOrNil(string(id.([]uint8))), nil
+}
+
+func GetDeviceCommand(id uuid.UUID) (DeviceCommand, error) {
+	cmd := DeviceCommand{}
+	err := GetObjectWithID(id, "devicecommands", &cmd)
+	return cmd, err
+}
+
+// SetDeviceCommandSent - marks a pending command as sent before it's handed to
+// the command channel, so its ack can't arrive first. Returns false when the
+// command isn't pending anymore, ie. another instance is sending it.
+func SetDeviceCommandSent(id uuid.UUID, sentAt time.Time) (bool, error) {
+	res, err := Sess.Update("devicecommands").
+		Set("status", DeviceCommandStatusSent).
+		Set("sentat", sentAt).
+		Where("id = ?", id).
+		And("status = ?", DeviceCommandStatusPending).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+// SetDeviceCommandPending - the controller wasn't connected, the command is resent later
+func SetDeviceCommandPending(id uuid.UUID) error {
+	_, err := Sess.Update("devicecommands").
+		Set("status", DeviceCommandStatusPending).
+		Set("sentat", nil).
+		Where("id = ?", id).
+		And("status = ?", DeviceCommandStatusSent).
+		Exec()
+	return err
+}
+
+// GetPendingDeviceCommands - commands created after the date and not delivered yet, oldest first
+func GetPendingDeviceCommands(createdAfter time.Time) ([]DeviceCommand, error) {
+	cmds := []DeviceCommand{}
+	err := Sess.Select("*").From("devicecommands").
+		Where("status = ?", DeviceCommandStatusPending).
+		And("cat > ?", createdAfter).
+		OrderBy("cat").
+		All(&cmds)
+	return cmds, err
+}
+
+func SetDeviceCommandFailed(id uuid.UUID, errorMsg string) error {
+	_, err := Sess.Update("devicecommands").
+		Set("status", DeviceCommandStatusFailed).
+		Set("error", null.StringFrom(errorMsg)).
+		Where("id = ?", id).
+		Exec()
+	return err
+}
+
+// AckDeviceCommand - only commands waiting for their ack can be acked, late acks
+// on expired commands are ignored. Pending commands are accepted too, a resent
+// command can be acked before it's marked sent again. Returns false when nothing was updated.
+func AckDeviceCommand(id uuid.UUID, controllerID, status string, errorMsg null.String, ackedAt time.Time) (bool, error) {
+	res, err := Sess.Update("devicecommands").
+		Set("status", status).
+		Set("error", errorMsg).
+		Set("ackedat", ackedAt).
+		Where("id = ?", id).
+		And("controllerid = ?", controllerID).
+		And("status IN ?", []string{DeviceCommandStatusPending, DeviceCommandStatusSent}).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+// ExpireDeviceCommands - commands sent before the date and still waiting for
+// their ack, and pending commands that were never delivered
+func ExpireDeviceCommands(sentBefore, createdBefore time.Time) (int64, error) {
+	res, err := Sess.Update("devicecommands").
+		Set("status", DeviceCommandStatusExpired).
+		Where(udb.Or(
+			udb.Raw("status = ? AND sentat < ?", DeviceCommandStatusSent, sentBefore),
+			udb.Raw("status = ? AND cat < ?", DeviceCommandStatusPending, createdBefore),
+		)).
+		Exec()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
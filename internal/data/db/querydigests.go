@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+)
+
+func GetUsersForDigest(dtype string) ([]User, error) {
+	users := []User{}
+	err := GetObjectsWithField("digest", dtype, "users", &users)
+	return users, err
+}
+
+// CreateDigest - returns false if the digest was already sent for this period
+func CreateDigest(userID uuid.UUID, dtype string, periodStart time.Time) (bool, error) {
+	res, err := Sess.Exec("insert into digests (userid, dtype, periodstart) values (?, ?, ?) on conflict do nothing", userID, dtype, periodStart)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+func DeleteDigest(userID uuid.UUID, dtype string, periodStart time.Time) error {
+	_, err := Sess.DeleteFrom("digests").Where("userid = ?", userID).And("dtype = ?", dtype).And("periodstart = ?", periodStart).Exec()
+	return err
+}
+
+type DigestPlantEntries struct {
+	PlantID   uuid.UUID `db:"plantid" json:"plantID"`
+	PlantName string    `db:"plantname" json:"plantName"`
+	N         int       `db:"n" json:"n"`
+}
+
+func GetFollowedPlantsEntriesCount(userID uuid.UUID, from, to time.Time) ([]DigestPlantEntries, error) {
+	res := []DigestPlantEntries{}
+	selector := Sess.Select("p.id as plantid", "p.name as plantname", udb.Raw("count(fe.id) as n")).
+		From("follows fo").
+		Join("plants p").On("p.id = fo.plantid").
+		Join("feedentries fe").On("fe.feedid = p.feedid").
+		Where("fo.userid = ?", userID).
+		And("p.is_public = true").
+		And("p.deleted = false").
+		And("fe.deleted = false").
+		And("fe.etype not in ('FE_TOWELIE_INFO', 'FE_PRODUCTS')").
+		And("fe.cat >= ?", from).
+		And("fe.cat < ?", to).
+		GroupBy("p.id", "p.name").
+		OrderBy("n desc")
+	if err := selector.All(&res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+type count struct {
+	N int `db:"n"`
+}
+
+func GetLikesReceivedCount(userID uuid.UUID, from, to time.Time) (int, error) {
+	c := count{}
+	selector := Sess.Select(udb.Raw("count(l.id) as n")).
+		From("likes l").
+		LeftJoin("feedentries fe").On("fe.id = l.feedentryid").
+		LeftJoin("comments c").On("c.id = l.commentid").
+		Where(udb.Or(udb.Raw("fe.userid = ?", userID), udb.Raw("c.userid = ?", userID))).
+		And("l.userid != ?", userID).
+		And("l.cat >= ?", from).
+		And("l.cat < ?", to)
+	err := selector.One(&c)
+	return c.N, err
+}
+
+func GetCommentsReceivedCount(userID uuid.UUID, from, to time.Time) (int, error) {
+	c := count{}
+	selector := Sess.Select(udb.Raw("count(c.id) as n")).
+		From("comments c").
+		Join("feedentries fe").On("fe.id = c.feedentryid").
+		Where("fe.userid = ?", userID).
+		And("c.userid != ?", userID).
+		And("c.cat >= ?", from).
+		And("c.cat < ?", to)
+	err := selector.One(&c)
+	return c.N, err
+}
+
+func GetNewFollowersCount(userID uuid.UUID, from, to time.Time) (int, error) {
+	c := count{}
+	selector := Sess.Select(udb.Raw("count(fo.id) as n")).
+		From("follows fo").
+		Join("plants p").On("p.id = fo.plantid").
+		Where("p.userid = ?", userID).
+		And("fo.userid != ?", userID).
+		And("fo.cat >= ?", from).
+		And("fo.cat < ?", to)
+	err := selector.One(&c)
+	return c.N, err
+}
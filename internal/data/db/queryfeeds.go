@@ -40,6 +40,20 @@ func GetFeedEntriesBetweenDates(from, to time.Time) ([]appbackend.FeedEntry, err
 	return feedEntries, nil
 }
 
+// GetFeedEntriesOfTypes - the feed's live entries of the given types, oldest first
+func GetFeedEntriesOfTypes(feedID uuid.UUID, types []string) ([]appbackend.FeedEntry, error) {
+	feedEntries := []appbackend.FeedEntry{}
+	selector := Sess.Select("*").From("feedentries").
+		Where("feedid = ?", feedID).
+		And("deleted = false").
+		And("etype in ?", types).
+		OrderBy("createdat")
+	if err := selector.All(&feedEntries); err != nil {
+		return feedEntries, err
+	}
+	return feedEntries, nil
+}
+
 func SetFeedEntryMeta(feedEntryID uuid.UUID, meta string) error {
 	if _, err := Sess.Update("feedentries").Set("meta", meta).Where("id = ?", feedEntryID).Exec(); err != nil {
 		return err
@@ -90,6 +104,32 @@ func GetUserEndsForUserID(userID uuid.UUID) ([]UserEnd, error) {
 	return userends, err
 }
 
+func ClearUserEndNotificationToken(transport, token string) error {
+	_, err := Sess.Update("userends").Set("notification_token", nil).Where("notification_token = ?", token).And("notification_transport = ?", transport).Exec()
+	return err
+}
+
+// CreateFeedEntry - inserts a server generated feed entry, and marks it dirty for all the user's userends so they sync it
+func CreateFeedEntry(fe appbackend.FeedEntry) (uuid.UUID, error) {
+	res, err := Sess.Collection("feedentries").Insert(fe)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	id := uuid.FromStringOrNil(string(res.([]uint8)))
+
+	userends, err := GetUserEndsForUserID(fe.UserID)
+	if err != nil {
+		return id, err
+	}
+	for _, userend := range userends {
+		ueo := UserEndFeedEntry{UserEndID: userend.ID.UUID, FeedEntryID: id, Dirty: true}
+		if _, err := Sess.Collection("userend_feedentries").Insert(ueo); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
 func GetPlant(id uuid.UUID) (appbackend.Plant, error) {
 	plant := appbackend.Plant{}
 	err := GetObjectWithField("id", id, "plants", &plant)
@@ -112,6 +152,12 @@ func GetPlantForFeedEntryID(feedEntryID uuid.UUID) (appbackend.Plant, error) {
 	return plant, nil
 }
 
+// IsPlantSharedWith - the plant was shared with the user through a plantsharing
+func IsPlantSharedWith(plantID, userID uuid.UUID) (bool, error) {
+	n, err := Sess.Collection("plantsharings").Find().Where("plantid = ?", plantID).And("touserid = ?", userID).Count()
+	return n != 0, err
+}
+
 func GetActivePlantsForControllerIdentifier(controllerID string, boxSlotID int) ([]appbackend.Plant, error) {
 	plants := []appbackend.Plant{}
 	selector := Sess.Select("plants.*").From("plants").Join("boxes").On("boxes.id = plants.boxid").Join("devices").On("devices.id = boxes.deviceid").Where("devices.identifier = ?", controllerID).And("boxes.devicebox = ?", boxSlotID).And("plants.deleted = false").And("plants.archived = false").And("devices.deleted = false")
@@ -169,3 +215,13 @@ func GetTimelapseFrame(timelapseID uuid.UUID) (appbackend.TimelapseFrame, error)
 
 	return timelapseFrame, nil
 }
+
+func GetActivePlantsForBox(boxID uuid.UUID) ([]appbackend.Plant, error) {
+	plants := []appbackend.Plant{}
+	selector := Sess.Select("*").From("plants").Where("boxid = ?", boxID).And("deleted = false").And("archived = false")
+	if err := selector.All(&plants); err != nil {
+		return plants, err
+	}
+
+	return plants, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+)
+
+func SetHarvestFeedEntryID(id, feedEntryID uuid.UUID) error {
+	_, err := Sess.Update("harvests").Set("feedentryid", feedEntryID).Where("id = ?", id).Exec()
+	return err
+}
+
+// harvestYieldsColumns - harvests grouped by the catalog strain their plant is
+// mapped to, with its seed bank
+const harvestYieldsColumns = `s.id as strainid,
+	coalesce(s.name, '') as strain,
+	coalesce(sb.name, '') as seedbank,
+	count(*) as harvests,
+	count(distinct h.userid) as growers,
+	avg(h.wetweight) as avgwetweight,
+	avg(h.dryweight) as avgdryweight,
+	sum(h.dryweight) as totaldryweight,
+	max(h.dryweight) as maxdryweight,
+	avg(extract(epoch from h.cureend - h.curestart) / 86400) as avgcuredays`
+
+// GetUserHarvestYields - yields of the user's own grows, the plants not
+// mapped to a strain are grouped together
+func GetUserHarvestYields(userID uuid.UUID) ([]HarvestYield, error) {
+	yields := []HarvestYield{}
+	err := Sess.Select(udb.Raw(harvestYieldsColumns)).From("harvests h").
+		Join("plants p").On("p.id = h.plantid").
+		LeftJoin("plantstrains ps").On("ps.plantid = p.id").
+		LeftJoin("strains s").On("s.id = ps.strainid").
+		LeftJoin("seedbanks sb").On("sb.id = s.seedbankid").
+		Where("h.deleted = false").
+		And("p.deleted = false").
+		And("h.dryweight is not null").
+		And("h.userid = ?", userID).
+		GroupBy(udb.Raw("1"), udb.Raw("2"), udb.Raw("3")).
+		OrderBy(udb.Raw("harvests desc")).
+		All(&yields)
+	return yields, err
+}
+
+// GetPublicHarvestYields - yields of public plants by verified strain, only
+// strains harvested by at least minGrowers growers are returned so no grower
+// can be singled out
+func GetPublicHarvestYields(minGrowers, limit int) ([]HarvestYield, error) {
+	yields := []HarvestYield{}
+	err := Sess.Iterator(`select `+harvestYieldsColumns+`
+		from harvests h
+		join plants p on p.id = h.plantid
+		join plantstrains ps on ps.plantid = p.id
+		join strains s on s.id = ps.strainid
+		left join seedbanks sb on sb.id = s.seedbankid
+		where h.deleted = false
+		and p.deleted = false
+		and h.dryweight is not null
+		and p.is_public = true
+		and s.verified = true
+		group by 1, 2, 3
+		having count(distinct h.userid) >= ?
+		order by harvests desc
+		limit ?`, minGrowers, limit).All(&yields)
+	return yields, err
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+)
+
+// CanReadControllerMetrics - the user holds the identifier's claim, or is its
+// first registrant while it's unclaimed, or one of the plants on the owner's
+// device was shared with them
+func CanReadControllerMetrics(userID uuid.UUID, controllerID string) (bool, error) {
+	res := struct {
+		N int `db:"n"`
+	}{}
+	err := Sess.Select(udb.Raw("count(*) as n")).From("devices d").
+		Where("d.identifier = ?", controllerID).
+		And("d.deleted = false").
+		And(controllerOwnerCond("d")).
+		And(udb.Or(
+			udb.Cond{"d.userid": userID},
+			udb.Raw(`exists (
+				select 1 from plantsharings ps
+				join plants p on p.id = ps.plantid
+				join boxes b on b.id = p.boxid
+				where b.deviceid = d.id and ps.touserid = ? and p.deleted = false
+			)`, userID),
+		)).
+		One(&res)
+	return res.N != 0, err
+}
+
+// GetBoxesForMetricsArchive - boxes with a controller and at least one live plant
+func GetBoxesForMetricsArchive() ([]ArchivedBox, error) {
+	boxes := []ArchivedBox{}
+	err := Sess.Select(udb.Raw("distinct b.id as boxid"), "d.identifier as controllerid", "b.devicebox as devicebox").
+		From("boxes b").
+		Join("devices d").On("d.id = b.deviceid").
+		Join("plants p").On("p.boxid = b.id").
+		Where("b.deleted = false").
+		And("d.deleted = false").
+		And("b.devicebox is not null").
+		And("p.deleted = false").
+		And("p.archived = false").
+		All(&boxes)
+	return boxes, err
+}
+
+// GetLatestMetricArchiveHour - zero time when the metric was never archived
+func GetLatestMetricArchiveHour(controllerID, name string) (time.Time, error) {
+	res := struct {
+		Hour *time.Time `db:"hour"`
+	}{}
+	err := Sess.Select(udb.Raw("max(hour) as hour")).From("metricarchives").
+		Where("controllerid = ?", controllerID).
+		And("name = ?", name).
+		One(&res)
+	if err != nil || res.Hour == nil {
+		return time.Time{}, err
+	}
+	return *res.Hour, nil
+}
+
+// CreateMetricArchive - hours are only archived once
+func CreateMetricArchive(a MetricArchive) error {
+	_, err := Sess.Exec("insert into metricarchives (controllerid, name, hour, boxid, avgvalue, minvalue, maxvalue) values (?, ?, ?, ?, ?, ?, ?) on conflict do nothing",
+		a.ControllerID, a.Name, a.Hour, a.BoxID, a.Avg, a.Min, a.Max)
+	return err
+}
+
+// GetMetricArchives - hours in [from, to), sorted
+func GetMetricArchives(controllerID, name string, from, to time.Time) ([]MetricArchive, error) {
+	archives := []MetricArchive{}
+	err := Sess.Select("*").From("metricarchives").
+		Where("controllerid = ?", controllerID).
+		And("name = ?", name).
+		And("hour >= ?", from).
+		And("hour < ?", to).
+		OrderBy("hour").
+		All(&archives)
+	return archives, err
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+func GetReminder(id uuid.UUID) (Reminder, error) {
+	reminder := Reminder{}
+	err := GetObjectWithID(id, "reminders", &reminder)
+	return reminder, err
+}
+
+func GetDueReminders(now time.Time) ([]Reminder, error) {
+	reminders := []Reminder{}
+	err := Sess.Select("*").From("reminders").
+		Where("nextat <= ?", now).
+		And("done = false").
+		And("deleted = false").
+		OrderBy("nextat").
+		All(&reminders)
+	return reminders, err
+}
+
+// ClaimReminder - moves the reminder to its next occurrence, returns false if another instance already did
+func ClaimReminder(id uuid.UUID, prevNextAt time.Time, nextAt null.Time, sentAt time.Time) (bool, error) {
+	res, err := Sess.Update("reminders").
+		Set("nextat", nextAt).
+		Set("lastsentat", sentAt).
+		Where("id = ?", id).
+		And("nextat = ?", prevNextAt).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+func SetReminderNextAt(id uuid.UUID, nextAt null.Time) error {
+	_, err := Sess.Update("reminders").Set("nextat", nextAt).Where("id = ?", id).Exec()
+	return err
+}
+
+func CompleteReminder(id uuid.UUID, nextAt null.Time, done bool, completedAt time.Time) error {
+	_, err := Sess.Update("reminders").
+		Set("nextat", nextAt).
+		Set("done", done).
+		Set("completedat", completedAt).
+		Where("id = ?", id).
+		Exec()
+	return err
+}
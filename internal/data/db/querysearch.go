@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+	udb "upper.io/db.v3"
+)
+
+// GetPlantsToIndex - public plants never indexed, whose plant, box or feed
+// changed since their last indexation, or that reached a planned stage
+func GetPlantsToIndex(limit int) ([]appbackend.Plant, error) {
+	plants := []appbackend.Plant{}
+	err := Sess.Select("p.*").From("plants p").
+		Join("boxes b").On("b.id = p.boxid").
+		LeftJoin("plantsearches psr").On("psr.plantid = p.id").
+		Where("p.is_public = true").
+		And("p.deleted = false").
+		And(udb.Or(
+			udb.Raw("psr.plantid is null"),
+			udb.Raw("psr.indexedat < p.uat"),
+			udb.Raw("psr.indexedat < b.uat"),
+			udb.Raw("psr.nextstageat <= now()"),
+			udb.Raw("exists (select 1 from feedentries fe where fe.feedid = p.feedid and fe.uat > psr.indexedat)"),
+		)).
+		Limit(limit).
+		All(&plants)
+	return plants, err
+}
+
+// DeleteUnsearchablePlants - removes the search documents of the plants that
+// were unpublished or deleted since their indexation
+func DeleteUnsearchablePlants() (int64, error) {
+	res, err := Sess.Exec(`delete from plantsearches psr where not exists (
+		select 1 from plants p where p.id = psr.plantid and p.is_public = true and p.deleted = false
+	)`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// IndexPlant - rebuilds the plant's search document, weighted from its name
+// and strain (A), seed bank (B), box name, light schedule and dimensions (C)
+// to its latest entries' messages (D)
+func IndexPlant(plantID uuid.UUID, stage string, nextStageAt null.Time, messagesLimit int) error {
+	_, err := Sess.Exec(`insert into plantsearches (plantid, document, ptype, stage, nextstageat, hasmedia, lastupdate, indexedat)
+		select p.id,
+			setweight(to_tsvector('simple', coalesce(p.name, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(p.settings->>'strain', '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(p.settings->>'seedBank', '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(b.name, '')), 'C') ||
+			setweight(to_tsvector('simple', concat_ws(' ', b.settings->>'schedule',
+				b.settings->>'width', b.settings->>'height', b.settings->>'depth', b.settings->>'unit')), 'C') ||
+			setweight(to_tsvector('simple', coalesce((
+				select string_agg(m.message, ' ') from (
+					select fe.params->>'message' as message from feedentries fe
+					where fe.feedid = p.feedid and fe.deleted = false and coalesce(fe.params->>'message', '') != ''
+					order by fe.createdat desc limit ?
+				) m
+			), '')), 'D'),
+			p.settings->>'plantType',
+			?,
+			?,
+			exists (
+				select 1 from feedmedias fm join feedentries fe on fe.id = fm.feedentryid
+				where fe.feedid = p.feedid and fe.deleted = false and fm.deleted = false
+			),
+			(select max(fe.createdat) from feedentries fe where fe.feedid = p.feedid and fe.deleted = false),
+			now()
+		from plants p join boxes b on b.id = p.boxid
+		where p.id = ?
+		on conflict (plantid) do update set document = excluded.document, ptype = excluded.ptype, stage = excluded.stage, nextstageat = excluded.nextstageat,
+			hasmedia = excluded.hasmedia, lastupdate = excluded.lastupdate, indexedat = excluded.indexedat`,
+		messagesLimit, stage, nextStageAt, plantID)
+	return err
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// GetPlantsToMapStrains - live plants never mapped, or updated since their last
+// mapping, least recently updated first
+func GetPlantsToMapStrains(limit int) ([]appbackend.Plant, error) {
+	plants := []appbackend.Plant{}
+	err := Sess.Select("p.*").From("plants p").
+		LeftJoin("plantstrains ps").On("ps.plantid = p.id").
+		Where("p.deleted = false").
+		And(udb.Or(udb.Raw("ps.plantid is null"), udb.Raw("ps.mappedat < p.uat"))).
+		OrderBy("p.uat").
+		Limit(limit).
+		All(&plants)
+	return plants, err
+}
+
+// SetPlantStrain - creates or replaces the plant's mapping
+func SetPlantStrain(ps PlantStrain) error {
+	_, err := Sess.Exec(`insert into plantstrains (plantid, strainid, seedbankid, mappedat) values (?, ?, ?, ?)
+		on conflict (plantid) do update set strainid = excluded.strainid, seedbankid = excluded.seedbankid, mappedat = excluded.mappedat`,
+		ps.PlantID, ps.StrainID, ps.SeedBankID, ps.MappedAt)
+	return err
+}
+
+// GetSeedBankByAlias - udb.ErrNoMoreRows when no seed bank has this alias
+func GetSeedBankByAlias(alias string) (SeedBank, error) {
+	seedBank := SeedBank{}
+	err := Sess.Select("sb.*").From("seedbanks sb").
+		Join("seedbankaliases sba").On("sba.seedbankid = sb.id").
+		Where("sba.alias = ?", alias).
+		One(&seedBank)
+	return seedBank, err
+}
+
+// GetSimilarSeedBank - closest seed bank name with a trigram similarity of at least minSimilarity
+func GetSimilarSeedBank(name string, minSimilarity float64) (SeedBank, error) {
+	seedBank := SeedBank{}
+	err := Sess.Select("*").From("seedbanks").
+		Where(udb.Raw("similarity(name, ?) >= ?", name, minSimilarity)).
+		OrderBy(udb.Raw("similarity(name, ?) desc", name)).
+		Limit(1).
+		One(&seedBank)
+	return seedBank, err
+}
+
+// UpsertSeedBank - the seed bank with this name, created pending when it
+// doesn't exist yet
+func UpsertSeedBank(name string) (uuid.UUID, error) {
+	var id uuid.UUID
+	row, err := Sess.QueryRow(`insert into seedbanks (name) values (?)
+		on conflict (name) do update set name = excluded.name
+		returning id`, name)
+	if err != nil {
+		return id, err
+	}
+	err = row.Scan(&id)
+	return id, err
+}
+
+func CreateSeedBankAlias(seedBankID uuid.UUID, alias string) error {
+	_, err := Sess.Exec("insert into seedbankaliases (seedbankid, alias) values (?, ?) on conflict do nothing", seedBankID, alias)
+	return err
+}
+
+// GetStrainByAlias - udb.ErrNoMoreRows when the seed bank has no strain with this alias
+func GetStrainByAlias(seedBankID uuid.NullUUID, alias string) (Strain, error) {
+	strain := Strain{}
+	err := Sess.Select("s.*").From("strains s").
+		Join("strainaliases sa").On("sa.strainid = s.id").
+		Where("sa.alias = ?", alias).
+		And(udb.Raw("s.seedbankid is not distinct from ?", seedBankID)).
+		One(&strain)
+	return strain, err
+}
+
+// GetSimilarStrain - closest strain name of the seed bank with a trigram
+// similarity of at least minSimilarity
+func GetSimilarStrain(seedBankID uuid.NullUUID, name string, minSimilarity float64) (Strain, error) {
+	strain := Strain{}
+	err := Sess.Select("*").From("strains").
+		Where(udb.Raw("seedbankid is not distinct from ?", seedBankID)).
+		And(udb.Raw("similarity(name, ?) >= ?", name, minSimilarity)).
+		OrderBy(udb.Raw("similarity(name, ?) desc", name)).
+		Limit(1).
+		One(&strain)
+	return strain, err
+}
+
+// UpsertStrain - the seed bank's strain with this name, created pending when
+// it doesn't exist yet
+func UpsertStrain(strain Strain) (uuid.UUID, error) {
+	var id uuid.UUID
+	row, err := Sess.QueryRow(`insert into strains (seedbankid, name, ptype) values (?, ?, ?)
+		on conflict (name, (coalesce(seedbankid, '00000000-0000-0000-0000-000000000000'))) do update set name = excluded.name
+		returning id`, strain.SeedBankID, strain.Name, strain.Type)
+	if err != nil {
+		return id, err
+	}
+	err = row.Scan(&id)
+	return id, err
+}
+
+// VerifyCatalog - pending seed banks and strains the public plants of at least
+// minGrowers growers are mapped to are verified
+func VerifyCatalog(minGrowers int) error {
+	if _, err := Sess.Exec(`update seedbanks sb set verified = true
+		where sb.verified = false
+		and (select count(distinct p.userid) from plantstrains ps
+			join plants p on p.id = ps.plantid
+			where ps.seedbankid = sb.id and p.is_public = true and p.deleted = false) >= ?`, minGrowers); err != nil {
+		return err
+	}
+	_, err := Sess.Exec(`update strains s set verified = true
+		where s.verified = false
+		and (select count(distinct p.userid) from plantstrains ps
+			join plants p on p.id = ps.plantid
+			where ps.strainid = s.id and p.is_public = true and p.deleted = false) >= ?`, minGrowers)
+	return err
+}
+
+func CreateStrainAlias(strainID uuid.UUID, alias string) error {
+	_, err := Sess.Exec("insert into strainaliases (strainid, alias) values (?, ?) on conflict do nothing", strainID, alias)
+	return err
+}
+
+func strainResultSelector(columns ...interface{}) sqlbuilder.Selector {
+	return Sess.Select(append([]interface{}{"s.*", "sb.name as seedbankname"}, columns...)...).From("strains s").
+		LeftJoin("seedbanks sb").On("sb.id = s.seedbankid")
+}
+
+// SearchStrains - verified strains whose name or aliases are similar to q, best matches first
+func SearchStrains(q string, limit int) ([]StrainResult, error) {
+	strains := []StrainResult{}
+	score := udb.Raw(`greatest(similarity(s.name, ?), coalesce((select max(similarity(sa.alias, ?)) from strainaliases sa where sa.strainid = s.id), 0)) as score`, q, q)
+	err := strainResultSelector(score).
+		Where(udb.Or(
+			udb.Raw("s.name % ?", q),
+			udb.Raw("s.name ilike ? || '%'", q),
+			udb.Raw("exists (select 1 from strainaliases sa where sa.strainid = s.id and sa.alias % ?)", q),
+		)).
+		And("s.verified = true").
+		OrderBy(udb.Raw("score desc")).
+		Limit(limit).
+		All(&strains)
+	return strains, err
+}
+
+// GetStrain - udb.ErrNoMoreRows when the strain doesn't exist or is still pending
+func GetStrain(id uuid.UUID) (StrainResult, error) {
+	strain := StrainResult{}
+	err := strainResultSelector().Where("s.id = ?", id).And("s.verified = true").One(&strain)
+	return strain, err
+}
+
+// GetStrainStats - yields are left null when less than minGrowers growers
+// harvested the strain, so no grower can be singled out
+func GetStrainStats(strainID uuid.UUID, minGrowers int) (StrainStats, error) {
+	stats := StrainStats{}
+	err := Sess.Select(
+		udb.Raw("count(distinct p.id) as plants"),
+		udb.Raw("count(distinct p.userid) as growers"),
+	).From("plants p").
+		Join("plantstrains ps").On("ps.plantid = p.id").
+		Where("ps.strainid = ?", strainID).
+		And("p.is_public = true").
+		And("p.deleted = false").
+		One(&stats)
+	if err != nil {
+		return stats, err
+	}
+
+	yields := StrainStats{}
+	err = Sess.Select(
+		udb.Raw("count(*) as harvests"),
+		udb.Raw("count(distinct h.userid) as growers"),
+		udb.Raw("avg(h.wetweight) as avgwetweight"),
+		udb.Raw("avg(h.dryweight) as avgdryweight"),
+		udb.Raw("avg(extract(epoch from h.cureend - h.curestart) / 86400) as avgcuredays"),
+	).From("harvests h").
+		Join("plants p").On("p.id = h.plantid").
+		Join("plantstrains ps").On("ps.plantid = p.id").
+		Where("ps.strainid = ?", strainID).
+		And("p.is_public = true").
+		And("p.deleted = false").
+		And("h.deleted = false").
+		And("h.dryweight is not null").
+		One(&yields)
+	if err != nil {
+		return stats, err
+	}
+	if yields.Growers >= minGrowers {
+		stats.Harvests = yields.Harvests
+		stats.AvgWetWeight = yields.AvgWetWeight
+		stats.AvgDryWeight = yields.AvgDryWeight
+		stats.AvgCureDays = yields.AvgCureDays
+	}
+	return stats, nil
+}
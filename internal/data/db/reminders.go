@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	// ReminderTypeCron - reminder sent following a cron expression, ie. "0 9 * * fri"
+	ReminderTypeCron = "cron"
+	// ReminderTypeInterval - reminder sent every IntervalHours since the last completion
+	ReminderTypeInterval = "interval"
+	// ReminderTypeRelative - one-off reminder sent RelativeDays after a plant date (germinationDate, bloomingStart)
+	ReminderTypeRelative = "relative"
+)
+
+// Reminder -
+type Reminder struct {
+	ID      uuid.NullUUID `db:"id,omitempty" json:"id"`
+	UserID  uuid.UUID     `db:"userid" json:"userID"`
+	PlantID uuid.UUID     `db:"plantid" json:"plantID"`
+
+	Title string      `db:"title" json:"title"`
+	Body  null.String `db:"body" json:"body"`
+
+	Type          string      `db:"stype" json:"type"`
+	Schedule      null.String `db:"schedule" json:"schedule"`
+	IntervalHours null.Int    `db:"intervalhours" json:"intervalHours"`
+	RelativeTo    null.String `db:"relativeto" json:"relativeTo"`
+	RelativeDays  null.Int    `db:"relativedays" json:"relativeDays"`
+	// Timezone - IANA name cron schedules are evaluated in, ie. "Europe/Paris"
+	Timezone string `db:"timezone,omitempty" json:"timezone"`
+
+	NextAt      null.Time `db:"nextat" json:"nextAt"`
+	LastSentAt  null.Time `db:"lastsentat,omitempty" json:"lastSentAt"`
+	CompletedAt null.Time `db:"completedat,omitempty" json:"completedAt"`
+
+	Done    bool `db:"done" json:"done"`
+	Deleted bool `db:"deleted" json:"deleted"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// GetID -
+func (o Reminder) GetID() uuid.NullUUID {
+	return o.ID
+}
+
+// SetUserID -
+func (o *Reminder) SetUserID(userID uuid.UUID) {
+	o.UserID = userID
+}
+
+// GetUserID -
+func (o Reminder) GetUserID() uuid.UUID {
+	return o.UserID
+}
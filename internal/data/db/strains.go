@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package db
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+// SeedBank - catalog entry the plants' seedBank setting is mapped to, pending
+// until Verified
+type SeedBank struct {
+	ID       uuid.NullUUID `db:"id,omitempty" json:"id"`
+	Name     string        `db:"name" json:"name"`
+	Verified bool          `db:"verified" json:"verified"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// SeedBankAlias - normalized seedBank setting that maps to the seed bank
+type SeedBankAlias struct {
+	ID         uuid.NullUUID `db:"id,omitempty" json:"id"`
+	SeedBankID uuid.UUID     `db:"seedbankid" json:"seedBankID"`
+	Alias      string        `db:"alias" json:"alias"`
+}
+
+// Strain - catalog entry the plants' strain setting is mapped to, strains
+// with the same name from different seed banks are different entries, pending
+// until Verified
+type Strain struct {
+	ID         uuid.NullUUID `db:"id,omitempty" json:"id"`
+	SeedBankID uuid.NullUUID `db:"seedbankid" json:"seedBankID"`
+	Name       string        `db:"name" json:"name"`
+	Type       null.String   `db:"ptype" json:"type"`
+	Verified   bool          `db:"verified" json:"verified"`
+
+	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
+	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
+}
+
+// StrainAlias - normalized strain setting that maps to the strain
+type StrainAlias struct {
+	ID       uuid.NullUUID `db:"id,omitempty" json:"id"`
+	StrainID uuid.UUID     `db:"strainid" json:"strainID"`
+	Alias    string        `db:"alias" json:"alias"`
+}
+
+// PlantStrain - catalog entries of a plant's settings, as of MappedAt
+type PlantStrain struct {
+	PlantID    uuid.UUID     `db:"plantid" json:"plantID"`
+	StrainID   uuid.NullUUID `db:"strainid" json:"strainID"`
+	SeedBankID uuid.NullUUID `db:"seedbankid" json:"seedBankID"`
+	MappedAt   time.Time     `db:"mappedat" json:"mappedAt"`
+}
+
+// StrainResult - strain with its seed bank's name, Score is the search's similarity
+type StrainResult struct {
+	Strain `db:",inline"`
+
+	SeedBankName null.String `db:"seedbankname" json:"seedBankName"`
+	Score        float64     `db:"score" json:"score"`
+}
+
+// StrainStats - aggregates of the strain's public plants, yields are only set
+// when harvested by enough growers
+type StrainStats struct {
+	Plants   int `db:"plants" json:"plants"`
+	Growers  int `db:"growers" json:"growers"`
+	Harvests int `db:"harvests" json:"harvests"`
+
+	AvgWetWeight null.Float `db:"avgwetweight" json:"avgWetWeight"`
+	AvgDryWeight null.Float `db:"avgdryweight" json:"avgDryWeight"`
+	AvgCureDays  null.Float `db:"avgcuredays" json:"avgCureDays"`
+}
@@ -34,6 +34,12 @@ type User struct {
 	Pic   null.String `db:"pic,omitempty" json:"pic,omitempty"`
 	Liked bool        `db:"liked,omitempty" json:"liked,omitempty"`
 
+	Email  null.String `db:"email,omitempty" json:"email,omitempty"`
+	Digest string      `db:"digest,omitempty" json:"digest,omitempty"`
+
+	Locale string `db:"locale,omitempty" json:"locale,omitempty"`
+	Units  string `db:"units,omitempty" json:"units,omitempty"`
+
 	CreatedAt time.Time `db:"cat,omitempty" json:"cat"`
 	UpdatedAt time.Time `db:"uat,omitempty" json:"uat"`
 }
@@ -19,49 +19,43 @@
 package kv
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
 	"time"
-)
-
-func GetAlertMinTemperatureDay(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MIN_TEMP_DAY", controllerID, box)
-	return GetNum(key, def)
-}
 
-func GetAlertMaxTemperatureDay(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MAX_TEMP_DAY", controllerID, box)
-	return GetNum(key, def)
-}
+	"github.com/go-redis/redis"
+)
 
-func GetAlertMinTemperatureNight(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MIN_TEMP_NIGHT", controllerID, box)
-	return GetNum(key, def)
+// GetAlertThreshold - legacy thresholds, name is the threshold's suffix, ie.
+// MIN_TEMP_DAY for <cid>.ALERT.BOX_<n>_MIN_TEMP_DAY
+func GetAlertThreshold(controllerID string, box int, name string, def float64) (float64, error) {
+	return GetNum(alertKey(controllerID, box, name, ""), def)
 }
 
-func GetAlertMaxTemperatureNight(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MAX_TEMP_NIGHT", controllerID, box)
-	return GetNum(key, def)
+func HasAlertThreshold(controllerID string, box int, name string) (bool, error) {
+	return HasNumKey(alertKey(controllerID, box, name, ""))
 }
 
-func GetAlertMinHumidityDay(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MIN_HUMI_DAY", controllerID, box)
-	return GetNum(key, def)
+func DelAlertThreshold(controllerID string, box int, name string) error {
+	return Del(alertKey(controllerID, box, name, ""))
 }
 
-func GetAlertMaxHumidityDay(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MAX_HUMI_DAY", controllerID, box)
-	return GetNum(key, def)
+// GetAlertThresholds - cached json thresholds of the box, empty when they're not cached
+func GetAlertThresholds(controllerID string, box int) (string, error) {
+	s, err := GetString(alertKey(controllerID, box, "THRESHOLDS", ""))
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return s, err
 }
 
-func GetAlertMinHumidityNight(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MIN_HUMI_NIGHT", controllerID, box)
-	return GetNum(key, def)
+func SetAlertThresholds(controllerID string, box int, thresholds string, expiration time.Duration) error {
+	return SetStringWithExpiration(alertKey(controllerID, box, "THRESHOLDS", ""), thresholds, expiration)
 }
 
-func GetAlertMaxHumidityNight(controllerID string, box int, def float64) (float64, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_MAX_HUMI_NIGHT", controllerID, box)
-	return GetNum(key, def)
+// DelAlertThresholds - the box's thresholds are read from postgres on the next metric
+func DelAlertThresholds(controllerID string, box int) error {
+	return Del(alertKey(controllerID, box, "THRESHOLDS", ""))
 }
 
 func GetTemperature(controllerID string, box int) (float64, error) {
@@ -69,6 +63,11 @@ func GetTemperature(controllerID string, box int) (float64, error) {
 	return GetNum(key, 0)
 }
 
+func GetHumidity(controllerID string, box int) (float64, error) {
+	key := fmt.Sprintf("%s.KV.BOX_%d_HUMI", controllerID, box)
+	return GetNum(key, 0)
+}
+
 func GetBoxTempSource(controllerID string, box int) (int, error) {
 	key := fmt.Sprintf("%s.KV.BOX_%d_TEMP_SOURCE", controllerID, box)
 	return GetInt(key, 0)
@@ -99,61 +98,81 @@ func GetTimerPower(controllerID string, box int) (float64, error) {
 	return GetNum(key, 0)
 }
 
-func GetLedBox(controllerID string, led int) (int, error) {
-	key := fmt.Sprintf("%s.KV.LED_%d_BOX", controllerID, led)
-	n, err := r.Get(key).Int()
-	return n, err
+// GetControllerValues - reads the controller's keys in one roundtrip, keys are
+// given and returned without the <cid>.KV. prefix, missing keys are omitted
+func GetControllerValues(controllerID string, keys []string) (map[string]string, error) {
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = fmt.Sprintf("%s.KV.%s", controllerID, k)
+	}
+	values, err := r.MGet(prefixed...).Result()
+	if err != nil {
+		return nil, err
+	}
+	results := map[string]string{}
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			results[keys[i]] = s
+		}
+	}
+	return results, nil
 }
 
-type GetAlertStatusFunc func(controllerID string, box int) (bool, error)
-
-func GetTemperatureAlertStatus(controllerID string, box int) (bool, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_TEMP", controllerID, box)
-	return GetBool(key)
+func alertKey(controllerID string, box int, rule, suffix string) string {
+	return fmt.Sprintf("%s.ALERT.BOX_%d_%s%s", controllerID, box, rule, suffix)
 }
 
-func GetHumidityAlertStatus(controllerID string, box int) (bool, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_HUMI", controllerID, box)
-	return GetBool(key)
+// GetAlertSince - returns when the value first went out of bounds, zero if it's not
+func GetAlertSince(controllerID string, box int, rule string) (time.Time, error) {
+	ts, err := GetNum(alertKey(controllerID, box, rule, "_SINCE"), 0)
+	if err != nil || ts == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(ts), 0), nil
 }
 
-type SetAlertStatusFunc func(controllerID string, box int, value bool) error
-
-func SetTemperatureAlertStatus(controllerID string, box int, value bool) error {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_TEMP", controllerID, box)
-	return SetBool(key, value, time.Duration(30+rand.Int()%15)*time.Minute)
+func SetAlertSince(controllerID string, box int, rule string, since time.Time, expiration time.Duration) error {
+	return SetNum(alertKey(controllerID, box, rule, "_SINCE"), float64(since.Unix()), expiration)
 }
 
-func SetHumidityAlertStatus(controllerID string, box int, value bool) error {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_HUMI", controllerID, box)
-	return SetBool(key, value, time.Duration(30+rand.Int()%15)*time.Minute)
+func DelAlertSince(controllerID string, box int, rule string) error {
+	return Del(alertKey(controllerID, box, rule, "_SINCE"))
 }
 
-type GetAlertTypeFunc func(controllerID string, box int) (string, error)
+// GetAlertLastNotification - returns when the last notification was sent for this alert, used for cooldowns
+func GetAlertLastNotification(controllerID string, box int, rule string) (time.Time, error) {
+	ts, err := GetNum(alertKey(controllerID, box, rule, "_LAST"), 0)
+	if err != nil || ts == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(ts), 0), nil
+}
 
-func GetTemperatureAlertType(controllerID string, box int) (string, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_TEMP_TYPE", controllerID, box)
-	return GetString(key)
+func SetAlertLastNotification(controllerID string, box int, rule string, date time.Time, expiration time.Duration) error {
+	return SetNum(alertKey(controllerID, box, rule, "_LAST"), float64(date.Unix()), expiration)
 }
 
-func GetHumidityAlertType(controllerID string, box int) (string, error) {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_HUMI_TYPE", controllerID, box)
-	return GetString(key)
+func GetBoxEnabled(controllerID string, box int) (bool, error) {
+	key := fmt.Sprintf("%s.KV.BOX_%d_ENABLED", controllerID, box)
+	return GetBool(key)
 }
 
-type SetAlertTypeFunc func(controllerID string, box int, atype string) error
+// GetFirmwareTimestamp - OTA_TIMESTAMP is the controller's firmware build date
+func GetFirmwareTimestamp(controllerID string) (int64, error) {
+	key := fmt.Sprintf("%s.KV.OTA_TIMESTAMP", controllerID)
+	n, err := GetNum(key, 0)
+	return int64(n), err
+}
 
-func SetTemperatureAlertType(controllerID string, box int, atype string) error {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_TEMP_TYPE", controllerID, box)
-	return SetString(key, atype)
+func claimCodeKey(controllerID string) string {
+	return fmt.Sprintf("%s.CLAIM.CODE", controllerID)
 }
 
-func SetHumidityAlertType(controllerID string, box int, atype string) error {
-	key := fmt.Sprintf("%s.ALERT.BOX_%d_HUMI_TYPE", controllerID, box)
-	return SetString(key, atype)
+func SetClaimCode(controllerID, code string, expiration time.Duration) error {
+	return SetStringWithExpiration(claimCodeKey(controllerID), code, expiration)
 }
 
-func GetBoxEnabled(controllerID string, box int) (bool, error) {
-	key := fmt.Sprintf("%s.KV.BOX_%d_ENABLED", controllerID, box)
-	return GetBool(key)
+// TakeClaimCode - claim codes are one-time, reading it deletes it
+func TakeClaimCode(controllerID string) (string, error) {
+	return TakeString(claimCodeKey(controllerID))
 }
@@ -45,6 +45,10 @@ func GetNum(key string, def float64) (float64, error) {
 	return n, err
 }
 
+func SetNum(key string, value float64, expiration time.Duration) error {
+	return r.Set(key, value, expiration).Err()
+}
+
 func GetInt(key string, def int) (int, error) {
 	n, err := r.Get(key).Int()
 	if errors.Is(err, redis.Nil) {
@@ -84,17 +88,59 @@ func SetStringWithExpiration(key, value string, expiration time.Duration) error
 	return r.Set(key, value, expiration).Err()
 }
 
+// TakeString - gets and deletes the key atomically, returns an empty string when it doesn't exist
+func TakeString(key string) (string, error) {
+	pipe := r.TxPipeline()
+	get := pipe.Get(key)
+	pipe.Del(key)
+	if _, err := pipe.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	s, err := get.Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return s, err
+}
+
+func Del(key string) error {
+	return r.Del(key).Err()
+}
+
+// ScanKeys - iterates with SCAN instead of KEYS, which blocks redis on large keyspaces
+func ScanKeys(pattern string) ([]string, error) {
+	keys := []string{}
+	var cursor uint64
+	for {
+		ks, next, err := r.Scan(cursor, pattern, 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ks...)
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return keys, nil
+}
+
 func GetKeys(patterns []string) ([]string, error) {
 	keys := []string{}
+	seen := map[string]bool{}
 	for _, p := range patterns {
+		ks := []string{p}
 		if strings.ContainsRune(p, '*') {
-			if ks, err := r.Keys(p).Result(); err != nil {
+			var err error
+			if ks, err = ScanKeys(p); err != nil {
 				return nil, err
-			} else {
-				keys = append(keys, ks...)
 			}
-		} else {
-			keys = append(keys, p)
+		}
+		for _, k := range ks {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
 		}
 	}
 	return keys, nil
@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package kv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	watchdogControllersKey = "WATCHDOG.CONTROLLERS"
+	watchdogOfflineKey     = "WATCHDOG.OFFLINE"
+	watchdogStaleKey       = "WATCHDOG.STALE"
+	// watchdogRetention - controllers silent for longer are forgotten
+	watchdogRetention = 30 * 24 * time.Hour
+)
+
+func watchdogSensorsKey(controllerID string) string {
+	return fmt.Sprintf("%s.WATCHDOG.SENSORS", controllerID)
+}
+
+func watchdogStaleMember(controllerID string, box int) string {
+	return fmt.Sprintf("%s.%d", controllerID, box)
+}
+
+// TryLock - returns true if the lock was free, it's released when it expires
+func TryLock(name string, expiration time.Duration) (bool, error) {
+	return r.SetNX(fmt.Sprintf("LOCK.%s", name), 1, expiration).Result()
+}
+
+// SetControllerSeen - records when the controller last published a metric
+func SetControllerSeen(controllerID string, date time.Time) error {
+	return r.ZAdd(watchdogControllersKey, redis.Z{Score: float64(date.Unix()), Member: controllerID}).Err()
+}
+
+// SetSensorSeen - records when the box's sensor last published a metric
+func SetSensorSeen(controllerID string, box int, date time.Time) error {
+	key := watchdogSensorsKey(controllerID)
+	pipe := r.TxPipeline()
+	pipe.HSet(key, strconv.Itoa(box), date.Unix())
+	pipe.Expire(key, watchdogRetention)
+	_, err := pipe.Exec()
+	return err
+}
+
+// GetSensorsSeen - returns when each box's sensor last published a metric
+func GetSensorsSeen(controllerID string) (map[int]time.Time, error) {
+	values, err := r.HGetAll(watchdogSensorsKey(controllerID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int]time.Time{}
+	for k, v := range values {
+		box, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		seen[box] = time.Unix(ts, 0)
+	}
+	return seen, nil
+}
+
+// GetControllersSeenBetween - controllers last seen in [from, to]
+func GetControllersSeenBetween(from, to time.Time) ([]string, error) {
+	return r.ZRangeByScore(watchdogControllersKey, redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: strconv.FormatInt(to.Unix(), 10),
+	}).Result()
+}
+
+// PruneControllersSeen - forgets the controllers silent for longer than the retention
+func PruneControllersSeen(now time.Time) error {
+	controllerIDs, err := GetControllersSeenBetween(time.Unix(0, 0), now.Add(-watchdogRetention))
+	if err != nil || len(controllerIDs) == 0 {
+		return err
+	}
+	members := make([]interface{}, len(controllerIDs))
+	for i, controllerID := range controllerIDs {
+		members[i] = controllerID
+	}
+	pipe := r.TxPipeline()
+	pipe.ZRem(watchdogControllersKey, members...)
+	pipe.SRem(watchdogOfflineKey, members...)
+	_, err = pipe.Exec()
+	return err
+}
+
+// SetControllerOffline - returns true if the controller was not already offline,
+// only one instance sees the transition
+func SetControllerOffline(controllerID string) (bool, error) {
+	n, err := r.SAdd(watchdogOfflineKey, controllerID).Result()
+	return n != 0, err
+}
+
+// SetControllerOnline - returns true if the controller was offline
+func SetControllerOnline(controllerID string) (bool, error) {
+	n, err := r.SRem(watchdogOfflineKey, controllerID).Result()
+	return n != 0, err
+}
+
+// SetSensorStale - returns true if the sensor was not already stale
+func SetSensorStale(controllerID string, box int) (bool, error) {
+	n, err := r.SAdd(watchdogStaleKey, watchdogStaleMember(controllerID, box)).Result()
+	return n != 0, err
+}
+
+// SetSensorFresh - returns true if the sensor was stale
+func SetSensorFresh(controllerID string, box int) (bool, error) {
+	n, err := r.SRem(watchdogStaleKey, watchdogStaleMember(controllerID, box)).Result()
+	return n != 0, err
+}
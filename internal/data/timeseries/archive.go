@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"math"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+const archiveResolution int64 = 60 * 60
+
+// ArchiveStore - serves the part of a range older than Retention from the
+// hourly archive in postgres, and the rest from Store
+type ArchiveStore struct {
+	Store     TimeSeriesStore
+	Retention time.Duration
+}
+
+func NewArchiveStore(store TimeSeriesStore, retention time.Duration) ArchiveStore {
+	return ArchiveStore{Store: store, Retention: retention}
+}
+
+// split - first aligned timestamp served by Store
+func (s ArchiveStore) split(start, step int64) int64 {
+	cutoff := time.Now().Add(-s.Retention).Unix()
+	if start >= cutoff {
+		return start
+	}
+	return start + int64(math.Ceil(float64(cutoff-start)/float64(step)))*step
+}
+
+func (s ArchiveStore) load(start, end, step int64, archived, live func(liveStart int64) (appbackend.TimeSeries, error)) (appbackend.TimeSeries, error) {
+	if step < 1 {
+		step = 1
+	}
+	liveStart := s.split(start, step)
+	res := appbackend.TimeSeries{}
+	if liveStart > start {
+		ts, err := archived(liveStart)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ts...)
+	}
+	if liveStart <= end {
+		ts, err := live(liveStart)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ts...)
+	}
+	return res, nil
+}
+
+// ArchiveQuery - aggregation of a series' archived hours, used for the parts of
+// expression queries older than the retention
+type ArchiveQuery struct {
+	Series Series
+	// Fn - avg, min, max, or rate: change of the hourly averages per second
+	Fn string
+	// Window - the hours ending within Window before each step are aggregated,
+	// at least an hour
+	Window int64
+	// Timer - only the hours where the timer's average is on when Day is set, or off otherwise
+	Timer *Series
+	Day   bool
+}
+
+// loadArchive - one value every step from start, before liveStart and end
+func loadArchive(q ArchiveQuery, start, end, liveStart, step int64) (appbackend.TimeSeries, error) {
+	window := q.Window
+	if window < archiveResolution {
+		window = archiveResolution
+	}
+	last := liveStart - step
+	if last > end {
+		last = end
+	}
+	from, to := time.Unix(start-window-archiveResolution, 0), time.Unix(last, 0)
+	rows, err := db.GetMetricArchives(q.Series.ControllerID, q.Series.Name, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if q.Timer != nil {
+		timer, err := db.GetMetricArchives(q.Timer.ControllerID, q.Timer.Name, from, to)
+		if err != nil {
+			return nil, err
+		}
+		on := map[int64]bool{}
+		for _, r := range timer {
+			on[r.Hour.Unix()] = r.Avg > 0
+		}
+		filtered := []db.MetricArchive{}
+		for _, r := range rows {
+			if isOn, ok := on[r.Hour.Unix()]; ok && isOn == q.Day {
+				filtered = append(filtered, r)
+			}
+		}
+		rows = filtered
+	}
+
+	res := appbackend.TimeSeries{}
+	first := 0
+	for t := start; t <= last; t += step {
+		for first < len(rows) && rows[first].Hour.Unix()+archiveResolution <= t-window {
+			first++
+		}
+		n := first
+		for n < len(rows) && rows[n].Hour.Unix()+archiveResolution <= t {
+			n++
+		}
+		hours := rows[first:n]
+		if len(hours) == 0 {
+			continue
+		}
+		var v float64
+		switch q.Fn {
+		case AggregationAvg:
+			for _, r := range hours {
+				v += r.Avg
+			}
+			v /= float64(len(hours))
+		case AggregationMin:
+			v = hours[0].Min
+			for _, r := range hours {
+				v = math.Min(v, r.Min)
+			}
+		case AggregationMax:
+			v = hours[0].Max
+			for _, r := range hours {
+				v = math.Max(v, r.Max)
+			}
+		case AggregationRate:
+			if len(hours) < 2 {
+				continue
+			}
+			a, b := hours[0], hours[len(hours)-1]
+			v = (b.Avg - a.Avg) / b.Hour.Sub(a.Hour).Seconds()
+		}
+		res = append(res, []float64{float64(t), v})
+	}
+	return res, nil
+}
+
+func (s ArchiveStore) QueryRange(series Series, start, end, step int64) (appbackend.TimeSeries, error) {
+	return s.load(start, end, step, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return loadArchive(ArchiveQuery{Series: series, Fn: AggregationAvg, Window: step}, start, end, liveStart, step)
+	}, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return s.Store.QueryRange(series, liveStart, end, step)
+	})
+}
+
+func (s ArchiveStore) Latest(series Series) (Sample, bool, error) {
+	return s.Store.Latest(series)
+}
+
+func (s ArchiveStore) Downsample(series Series, start, end, step int64, fn string) (appbackend.TimeSeries, error) {
+	if err := checkAggregation(fn); err != nil {
+		return nil, err
+	}
+	return s.load(start, end, step, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return loadArchive(ArchiveQuery{Series: series, Fn: fn, Window: step}, start, end, liveStart, step)
+	}, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return s.Store.Downsample(series, liveStart, end, step, fn)
+	})
+}
+
+// QueryExprRange - expressions are only evaluated by Store, the archive is not
+// used, see QueryExprRangeArchive
+func (s ArchiveStore) QueryExprRange(expr string, start, end, step int64) (appbackend.TimeSeries, error) {
+	es, ok := s.Store.(ExprStore)
+	if !ok {
+		return nil, ErrExprNotSupported
+	}
+	return es.QueryExprRange(expr, start, end, step)
+}
+
+// QueryExprRangeArchive - the part of the range older than Retention is
+// computed by q from the archive, the rest is evaluated by Store
+func (s ArchiveStore) QueryExprRangeArchive(expr string, start, end, step int64, q ArchiveQuery) (appbackend.TimeSeries, error) {
+	if err := checkArchiveQuery(q); err != nil {
+		return nil, err
+	}
+	return s.load(start, end, step, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return loadArchive(q, start, end, liveStart, step)
+	}, func(liveStart int64) (appbackend.TimeSeries, error) {
+		return s.QueryExprRange(expr, liveStart, end, step)
+	})
+}
+
+func checkArchiveQuery(q ArchiveQuery) error {
+	if q.Fn == AggregationRate {
+		return nil
+	}
+	return checkAggregation(q.Fn)
+}
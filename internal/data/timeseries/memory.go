@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+// memoryLookback - like prometheus, a sample stays the series' value for 5 minutes
+const memoryLookback = 5 * time.Minute
+
+// MemoryStore - in-memory store used by the tests, samples are only added with
+// Add, it doesn't evaluate expressions. It follows PrometheusStore: NaN and
+// Inf samples are returned as NaN by QueryRange and skipped by the aggregations.
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	series map[Series][]Sample
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{series: map[Series][]Sample{}}
+}
+
+// Add - samples can be added in any order
+func (m *MemoryStore) Add(s Series, t time.Time, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	samples := append(m.series[s], Sample{Time: t, Value: value})
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	m.series[s] = samples
+}
+
+// between - samples in (from, to]
+func (m *MemoryStore) between(s Series, from, to time.Time) []Sample {
+	samples := m.series[s]
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Time.After(from) })
+	j := sort.Search(len(samples), func(i int) bool { return samples[i].Time.After(to) })
+	return samples[i:j]
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func (m *MemoryStore) QueryRange(s Series, start, end, step int64) (appbackend.TimeSeries, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if step < 1 {
+		step = 1
+	}
+	res := appbackend.TimeSeries{}
+	for t := start; t <= end; t += step {
+		at := time.Unix(t, 0)
+		samples := m.between(s, at.Add(-memoryLookback), at)
+		if len(samples) == 0 {
+			continue
+		}
+		v := samples[len(samples)-1].Value
+		if math.IsInf(v, 0) {
+			v = math.NaN()
+		}
+		res = append(res, []float64{float64(t), v})
+	}
+	return res, nil
+}
+
+func (m *MemoryStore) Latest(s Series) (Sample, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	samples := m.series[s]
+	for i := len(samples) - 1; i >= 0; i-- {
+		if finite(samples[i].Value) {
+			return samples[i], true, nil
+		}
+	}
+	return Sample{}, false, nil
+}
+
+func (m *MemoryStore) Downsample(s Series, start, end, step int64, fn string) (appbackend.TimeSeries, error) {
+	if err := checkAggregation(fn); err != nil {
+		return nil, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if step < 1 {
+		step = 1
+	}
+	res := appbackend.TimeSeries{}
+	for t := start; t <= end; t += step {
+		samples := []Sample{}
+		for _, sample := range m.between(s, time.Unix(t-step, 0), time.Unix(t, 0)) {
+			if finite(sample.Value) {
+				samples = append(samples, sample)
+			}
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		v := samples[0].Value
+		sum := 0.0
+		for _, sample := range samples {
+			sum += sample.Value
+			switch fn {
+			case AggregationMin:
+				v = math.Min(v, sample.Value)
+			case AggregationMax:
+				v = math.Max(v, sample.Value)
+			}
+		}
+		if fn == AggregationAvg {
+			v = sum / float64(len(samples))
+		}
+		res = append(res, []float64{float64(t), v})
+	}
+	return res, nil
+}
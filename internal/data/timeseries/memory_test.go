@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+// formatSeries - NaN can't be compared with reflect.DeepEqual
+func formatSeries(ts appbackend.TimeSeries) string {
+	return fmt.Sprint(ts)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := Series{ControllerID: "c1", Name: "BOX_0_TEMP"}
+	m := NewMemoryStore()
+	// added out of order
+	m.Add(s, time.Unix(120, 0), 22)
+	m.Add(s, time.Unix(60, 0), 20)
+	m.Add(s, time.Unix(180, 0), math.NaN())
+	m.Add(s, time.Unix(240, 0), 26)
+	m.Add(s, time.Unix(1000, 0), math.Inf(1))
+
+	tests := []struct {
+		name  string
+		query func() (appbackend.TimeSeries, error)
+		want  string
+	}{
+		{
+			name:  "query range",
+			query: func() (appbackend.TimeSeries, error) { return m.QueryRange(s, 60, 240, 60) },
+			want:  "[[60 20] [120 22] [180 NaN] [240 26]]",
+		},
+		{
+			name:  "query range looks back 5 minutes",
+			query: func() (appbackend.TimeSeries, error) { return m.QueryRange(s, 500, 600, 60) },
+			want:  "[[500 26]]",
+		},
+		{
+			name:  "query range keeps Inf as NaN",
+			query: func() (appbackend.TimeSeries, error) { return m.QueryRange(s, 1000, 1000, 60) },
+			want:  "[[1000 NaN]]",
+		},
+		{
+			name:  "query range of an unknown series",
+			query: func() (appbackend.TimeSeries, error) { return m.QueryRange(Series{ControllerID: "c2"}, 60, 240, 60) },
+			want:  "[]",
+		},
+		{
+			name:  "avg skips NaN",
+			query: func() (appbackend.TimeSeries, error) { return m.Downsample(s, 120, 240, 120, AggregationAvg) },
+			want:  "[[120 21] [240 26]]",
+		},
+		{
+			name:  "min",
+			query: func() (appbackend.TimeSeries, error) { return m.Downsample(s, 240, 240, 240, AggregationMin) },
+			want:  "[[240 20]]",
+		},
+		{
+			name:  "max",
+			query: func() (appbackend.TimeSeries, error) { return m.Downsample(s, 240, 240, 240, AggregationMax) },
+			want:  "[[240 26]]",
+		},
+		{
+			name:  "only non finite samples",
+			query: func() (appbackend.TimeSeries, error) { return m.Downsample(s, 1000, 1000, 60, AggregationAvg) },
+			want:  "[]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := tt.query()
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got := formatSeries(ts); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := m.Downsample(s, 0, 240, 60, "sum"); err == nil {
+		t.Errorf("Downsample(sum): expected an error")
+	}
+	sample, ok, err := m.Latest(s)
+	if err != nil || !ok || sample.Value != 26 {
+		t.Errorf("Latest = %+v %v %v, want the last finite sample", sample, ok, err)
+	}
+	if _, ok, _ := m.Latest(Series{ControllerID: "c2"}); ok {
+		t.Errorf("Latest of an unknown series: expected no sample")
+	}
+}
+
+func TestCarryForward(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   appbackend.TimeSeries
+		want string
+	}{
+		{"empty", appbackend.TimeSeries{}, "[]"},
+		{"in bounds", appbackend.TimeSeries{{1, 20}, {2, 21}}, "[[1 20] [2 21]]"},
+		{"NaN", appbackend.TimeSeries{{1, 20}, {2, math.NaN()}, {3, 22}}, "[[1 20] [2 20] [3 22]]"},
+		{"leading NaN", appbackend.TimeSeries{{1, math.NaN()}, {2, 21}}, "[[1 0] [2 21]]"},
+		{"out of bounds", appbackend.TimeSeries{{1, 20}, {2, 150}, {3, -10}, {4, 22}}, "[[1 20] [2 20] [3 20] [4 22]]"},
+	}
+	for _, tt := range tests {
+		if got := formatSeries(CarryForward(tt.ts, 0, 100)); got != tt.want {
+			t.Errorf("%s: CarryForward = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
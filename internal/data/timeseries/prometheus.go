@@ -0,0 +1,200 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+type httpAuth struct {
+	User     string
+	Password string
+	Token    string
+}
+
+// apiResult - response of the prometheus HTTP API, Values is set for range
+// queries, Value for instant queries
+type apiResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// parsePoint - ok is false when the point can't be parsed, NaN and Inf values
+// are returned as NaN
+func parsePoint(v []interface{}) (float64, float64, bool) {
+	if len(v) != 2 {
+		return 0, 0, false
+	}
+	ts, ok := v[0].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	s, ok := v[1].(string)
+	if !ok {
+		return 0, 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if math.IsInf(f, 0) {
+		f = math.NaN()
+	}
+	return ts, f, true
+}
+
+// toTimeSeries - first result's values, NaN and Inf values are kept as NaN
+// when keepNaN is set, skipped otherwise
+func (r apiResult) toTimeSeries(keepNaN bool) appbackend.TimeSeries {
+	res := appbackend.TimeSeries{}
+	if len(r.Data.Result) < 1 {
+		return res
+	}
+	for _, v := range r.Data.Result[0].Values {
+		ts, f, ok := parsePoint(v)
+		if !ok || (math.IsNaN(f) && !keepNaN) {
+			continue
+		}
+		res = append(res, []float64{ts, f})
+	}
+	return res
+}
+
+// PrometheusStore - reads from the prometheus HTTP API
+type PrometheusStore struct {
+	URL    string
+	Auth   httpAuth
+	Client *http.Client
+}
+
+func NewPrometheusStore(url string, auth httpAuth, timeout time.Duration) *PrometheusStore {
+	return &PrometheusStore{
+		URL:    strings.TrimRight(url, "/"),
+		Auth:   auth,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *PrometheusStore) get(path string, v url.Values) (apiResult, error) {
+	res := apiResult{}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?%s", s.URL, path, v.Encode()), nil)
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.Auth.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.Auth.Token))
+	} else if s.Auth.User != "" {
+		req.SetBasicAuth(s.Auth.User, s.Auth.Password)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, err
+	}
+	if res.Status != "success" {
+		return res, QueryError{Status: res.Status, Message: res.Error}
+	}
+	return res, nil
+}
+
+// QueryExprRange - NaN and Inf values are skipped
+func (s *PrometheusStore) QueryExprRange(expr string, start, end, step int64) (appbackend.TimeSeries, error) {
+	return s.queryRange(expr, start, end, step, false)
+}
+
+func (s *PrometheusStore) queryRange(expr string, start, end, step int64, keepNaN bool) (appbackend.TimeSeries, error) {
+	if step < 1 {
+		step = 1
+	}
+	v := url.Values{}
+	v.Set("query", expr)
+	v.Set("start", fmt.Sprintf("%d", start))
+	v.Set("end", fmt.Sprintf("%d", end))
+	v.Set("step", fmt.Sprintf("%d", step))
+	res, err := s.get("/api/v1/query_range", v)
+	if err != nil {
+		return nil, err
+	}
+	return res.toTimeSeries(keepNaN), nil
+}
+
+func (s *PrometheusStore) queryInstant(expr string) (Sample, bool, error) {
+	v := url.Values{}
+	v.Set("query", expr)
+	res, err := s.get("/api/v1/query", v)
+	if err != nil || len(res.Data.Result) < 1 {
+		return Sample{}, false, err
+	}
+	ts, f, ok := parsePoint(res.Data.Result[0].Value)
+	if !ok || math.IsNaN(f) {
+		return Sample{}, false, nil
+	}
+	sec, frac := math.Modf(ts)
+	return Sample{Time: time.Unix(int64(sec), int64(frac*1e9)), Value: f}, true, nil
+}
+
+func (s *PrometheusStore) QueryRange(series Series, start, end, step int64) (appbackend.TimeSeries, error) {
+	return s.queryRange(series.selector(), start, end, step, true)
+}
+
+// Latest - prometheus only looks back 5 minutes for instant queries
+func (s *PrometheusStore) Latest(series Series) (Sample, bool, error) {
+	return s.queryInstant(series.selector())
+}
+
+func (s *PrometheusStore) Downsample(series Series, start, end, step int64, fn string) (appbackend.TimeSeries, error) {
+	if err := checkAggregation(fn); err != nil {
+		return nil, err
+	}
+	if step < 1 {
+		step = 1
+	}
+	return s.QueryExprRange(fmt.Sprintf("%s_over_time(%s[%ds])", fn, series.selector(), step), start, end, step)
+}
+
+func checkAggregation(fn string) error {
+	switch fn {
+	case AggregationAvg, AggregationMin, AggregationMax:
+		return nil
+	}
+	return fmt.Errorf("Unknown aggregation %q", fn)
+}
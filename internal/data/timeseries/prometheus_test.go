@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrometheusStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		raw     string
+		expr    string
+		wantErr bool
+	}{
+		{
+			name: "NaN and Inf are kept by raw queries",
+			body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[60,"20"],[120,"NaN"],[180,"+Inf"],[240,"22"]]}]}}`,
+			raw:  "[[60 20] [120 NaN] [180 NaN] [240 22]]",
+			expr: "[[60 20] [240 22]]",
+		},
+		{
+			name: "no result",
+			body: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			raw:  "[]",
+			expr: "[]",
+		},
+		{
+			name:    "error status",
+			body:    `{"status":"error","error":"bad query"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			s := NewPrometheusStore(srv.URL, httpAuth{}, time.Second)
+
+			raw, err := s.QueryRange(Series{ControllerID: "c1", Name: "BOX_0_TEMP"}, 60, 240, 60)
+			if tt.wantErr {
+				var queryErr QueryError
+				if !errors.As(err, &queryErr) || queryErr.Status != "error" {
+					t.Errorf("QueryRange error = %v, want a QueryError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QueryRange: unexpected error %s", err)
+			}
+			if got := formatSeries(raw); got != tt.raw {
+				t.Errorf("QueryRange = %s, want %s", got, tt.raw)
+			}
+			expr, err := s.QueryExprRange("rate(g_BOX_0_TEMP[5m])", 60, 240, 60)
+			if err != nil {
+				t.Fatalf("QueryExprRange: unexpected error %s", err)
+			}
+			if got := formatSeries(expr); got != tt.expr {
+				t.Errorf("QueryExprRange = %s, want %s", got, tt.expr)
+			}
+		})
+	}
+}
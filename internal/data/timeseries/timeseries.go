@@ -0,0 +1,185 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	AggregationAvg = "avg"
+	AggregationMin = "min"
+	AggregationMax = "max"
+	// AggregationRate - only used by the archive queries, see ArchiveQuery
+	AggregationRate = "rate"
+)
+
+var (
+	// Store - the backend configured with --timeseriesbackend, ranges older than
+	// --timeseriesretention are read from the hourly archive
+	Store TimeSeriesStore
+	// Live - the backend without the archive, only serves the last Retention
+	Live      TimeSeriesStore
+	Retention time.Duration
+
+	ErrExprNotSupported = errors.New("The time-series backend doesn't support query expressions")
+
+	_ = pflag.String("timeseriesbackend", "prometheus", "Time-series backend the metrics are read from (prometheus, victoriametrics)")
+	_ = pflag.String("timeseriesurl", "", "Url of the time-series backend, defaults to http://prometheus:9090 or http://victoriametrics:8428")
+	_ = pflag.String("timeseriesuser", "", "Basic auth user of the time-series backend")
+	_ = pflag.String("timeseriespassword", "", "Basic auth password of the time-series backend")
+	_ = pflag.String("timeseriestoken", "", "Bearer token of the time-series backend")
+	_ = pflag.String("timeseriestimeout", "10s", "Timeout of the time-series backend requests")
+	_ = pflag.String("victoriametricstenant", "", "VictoriaMetrics cluster tenant, leave empty for single-node")
+	_ = pflag.String("timeseriesretention", "360h", "Retention of the time-series backend, older ranges are read from the hourly archive")
+)
+
+func init() {
+	viper.SetDefault("TimeSeriesBackend", "prometheus")
+	viper.SetDefault("TimeSeriesTimeout", "10s")
+	viper.SetDefault("TimeSeriesRetention", "360h")
+}
+
+// Series - a controller metric, stored as g_<Name>{id="<ControllerID>"}
+type Series struct {
+	ControllerID string
+	Name         string
+}
+
+func (s Series) selector() string {
+	return fmt.Sprintf("g_%s{id=\"%s\"}", s.Name, s.ControllerID)
+}
+
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// QueryError - the backend answered with a non-success status
+type QueryError struct {
+	Status  string
+	Message string
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("query failed with status %s: %s", e.Status, e.Message)
+}
+
+// TimeSeriesStore - values are returned as [timestamp, value] pairs, timestamps
+// are aligned on start + k*step
+type TimeSeriesStore interface {
+	// QueryRange - the series' value every step seconds between start and end,
+	// NaN and Inf samples are kept as NaN, see CarryForward
+	QueryRange(s Series, start, end, step int64) (appbackend.TimeSeries, error)
+	// Latest - the series' last value, ok is false when it has none
+	Latest(s Series) (sample Sample, ok bool, err error)
+	// Downsample - the series aggregated over each step, fn is one of avg, min or max
+	Downsample(s Series, start, end, step int64, fn string) (appbackend.TimeSeries, error)
+}
+
+// CarryForward - NaN values and values outside of [min, max] are replaced by
+// the previous value, or 0 when there's none
+func CarryForward(ts appbackend.TimeSeries, min, max float64) appbackend.TimeSeries {
+	res := appbackend.TimeSeries{}
+	var last float64
+	for _, v := range ts {
+		if math.IsNaN(v[1]) || v[1] < min || v[1] > max {
+			res = append(res, []float64{v[0], last})
+			continue
+		}
+		last = v[1]
+		res = append(res, v)
+	}
+	return res
+}
+
+// ExprStore - stores that can also evaluate PromQL expressions
+type ExprStore interface {
+	TimeSeriesStore
+	QueryExprRange(expr string, start, end, step int64) (appbackend.TimeSeries, error)
+}
+
+// QueryExprRange - evaluates the expression on Store, fails when it's not an ExprStore
+func QueryExprRange(expr string, start, end, step int64) (appbackend.TimeSeries, error) {
+	es, ok := Store.(ExprStore)
+	if !ok {
+		return nil, ErrExprNotSupported
+	}
+	return es.QueryExprRange(expr, start, end, step)
+}
+
+// QueryExprRangeArchive - same as QueryExprRange, the range older than the
+// retention is computed by q from the archive when Store has one
+func QueryExprRangeArchive(expr string, start, end, step int64, q ArchiveQuery) (appbackend.TimeSeries, error) {
+	if as, ok := Store.(ArchiveStore); ok {
+		return as.QueryExprRangeArchive(expr, start, end, step, q)
+	}
+	return QueryExprRange(expr, start, end, step)
+}
+
+func LoadTimeSeries(device appbackend.Device, from, to time.Time, module, metric string, i int) (appbackend.TimeSeries, error) {
+	s := Series{ControllerID: device.Identifier, Name: fmt.Sprintf("%s_%d_%s", module, i, metric)}
+	ts, err := Store.QueryRange(s, from.Unix(), to.Unix(), (to.Unix()-from.Unix())/50)
+	if err != nil {
+		logrus.Errorf("Store.QueryRange in LoadTimeSeries %q - device: %+v from: %d to: %d module: %s metric: %s i: %d", err, device, from.Unix(), to.Unix(), module, metric, i)
+		return appbackend.TimeSeries{}, err
+	}
+	return CarryForward(ts, float64(math.MinInt32), float64(math.MaxInt32)), nil
+}
+
+func Init() {
+	timeout, err := time.ParseDuration(viper.GetString("TimeSeriesTimeout"))
+	if err != nil {
+		logrus.Fatalf("time.ParseDuration in timeseries.Init %q", err)
+	}
+	auth := httpAuth{
+		User:     viper.GetString("TimeSeriesUser"),
+		Password: viper.GetString("TimeSeriesPassword"),
+		Token:    viper.GetString("TimeSeriesToken"),
+	}
+	Retention, err = time.ParseDuration(viper.GetString("TimeSeriesRetention"))
+	if err != nil {
+		logrus.Fatalf("time.ParseDuration in timeseries.Init %q", err)
+	}
+	url := viper.GetString("TimeSeriesURL")
+
+	switch backend := viper.GetString("TimeSeriesBackend"); backend {
+	case "prometheus":
+		if url == "" {
+			url = "http://prometheus:9090"
+		}
+		Live = NewPrometheusStore(url, auth, timeout)
+	case "victoriametrics":
+		if url == "" {
+			url = "http://victoriametrics:8428"
+		}
+		Live = NewVictoriaMetricsStore(url, viper.GetString("VictoriaMetricsTenant"), auth, timeout)
+	default:
+		logrus.Fatalf("Unknown time-series backend %s", backend)
+	}
+	Store = NewArchiveStore(Live, Retention)
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package timeseries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// victoriaMetricsLatestLookback - controllers that report rarely are still
+// seen, prometheus stops at 5 minutes
+const victoriaMetricsLatestLookback = time.Hour
+
+// VictoriaMetricsStore - VictoriaMetrics serves the prometheus HTTP API, under
+// /select/<tenant>/prometheus on cluster setups
+type VictoriaMetricsStore struct {
+	*PrometheusStore
+}
+
+func NewVictoriaMetricsStore(url, tenant string, auth httpAuth, timeout time.Duration) *VictoriaMetricsStore {
+	url = strings.TrimRight(url, "/")
+	if tenant != "" {
+		url = fmt.Sprintf("%s/select/%s/prometheus", url, tenant)
+	}
+	return &VictoriaMetricsStore{PrometheusStore: NewPrometheusStore(url, auth, timeout)}
+}
+
+func (s *VictoriaMetricsStore) Latest(series Series) (Sample, bool, error) {
+	return s.queryInstant(fmt.Sprintf("last_over_time(%s[%ds])", series.selector(), int64(victoriaMetricsLatestLookback.Seconds())))
+}
@@ -48,3 +48,21 @@ func PublishInsert(collection string) middleware.Middleware {
 		}
 	}
 }
+
+type UpdateMessage struct {
+	Object interface{} `json:"object"`
+}
+
+func PublishUpdate(collection string) middleware.Middleware {
+	return func(fn httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			o := r.Context().Value(ObjectContextKey{})
+
+			msg := UpdateMessage{o}
+			if err := pubsub.PublishObject(fmt.Sprintf("update.%s", collection), msg); err != nil {
+				logrus.Errorf("PublishObject in PublishUpdate %q", err)
+			}
+			fn(w, r, p)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/alerts"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func validateAlertRule(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rule := r.Context().Value(middlewares.ObjectContextKey{}).(*db.AlertRule)
+		if err := alerts.ValidateAlertRule(*rule); err != nil {
+			logrus.Errorf("alerts.ValidateAlertRule in validateAlertRule %q - %+v", err, rule)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fn(w, r, p)
+	}
+}
+
+var createAlertRuleHandler = middlewares.InsertEndpoint(
+	"alertrules",
+	func() interface{} { return &db.AlertRule{} },
+	[]middleware.Middleware{
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("boxes", "BoxID", false, func() appbackend.UserObject { return &appbackend.Box{} }),
+		validateAlertRule,
+	},
+	[]middleware.Middleware{
+		middlewares.PublishInsert("alertrules"),
+	},
+)
+
+var updateAlertRuleHandler = middlewares.UpdateEndpoint(
+	"alertrules",
+	func() interface{} { return &db.AlertRule{} },
+	[]middleware.Middleware{
+		middlewares.ObjectIDRequired,
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("alertrules", "ID", false, func() appbackend.UserObject { return &db.AlertRule{} }),
+		middlewares.CheckAccessRight("boxes", "BoxID", false, func() appbackend.UserObject { return &appbackend.Box{} }),
+		validateAlertRule,
+	},
+	[]middleware.Middleware{
+		middlewares.PublishUpdate("alertrules"),
+	},
+)
+
+type SelectBoxAlertRulesParams struct {
+	middlewares.SelectParamsOffsetLimit
+}
+
+func filterBoxAlertRules(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		selector = selector.Where("t.userid = ?", uid).And("t.boxid = ?", p.ByName("id")).And("t.deleted = false")
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectBoxAlertRules = middlewares.SelectEndpoint(
+	"alertrules",
+	func() interface{} { return &[]db.AlertRule{} },
+	func() interface{} { return &SelectBoxAlertRulesParams{} },
+	[]middleware.Middleware{
+		filterBoxAlertRules,
+	},
+	[]middleware.Middleware{},
+)
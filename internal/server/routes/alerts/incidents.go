@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+type SelectPlantAlertIncidentsParams struct {
+	middlewares.SelectParamsOffsetLimit
+
+	Unacknowledged bool
+}
+
+func filterPlantAlertIncidents(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		params := r.Context().Value(middlewares.QueryObjectContextKey{}).(*SelectPlantAlertIncidentsParams)
+		selector = selector.Where("t.userid = ?", uid).And("t.plantid = ?", p.ByName("id"))
+		if params.Unacknowledged {
+			selector = selector.And("t.acknowledged = false")
+		}
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectPlantAlertIncidents = middlewares.SelectEndpoint(
+	"alertincidents",
+	func() interface{} { return &[]db.AlertIncident{} },
+	func() interface{} { return &SelectPlantAlertIncidentsParams{} },
+	[]middleware.Middleware{
+		filterPlantAlertIncidents,
+	},
+	[]middleware.Middleware{},
+)
+
+func ackAlertIncidentHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		logrus.Errorf("uuid.FromString in ackAlertIncidentHandler %q - uid: %s", err, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	incident, err := db.GetAlertIncident(id)
+	if err != nil {
+		logrus.Errorf("db.GetAlertIncident in ackAlertIncidentHandler %q - id: %s uid: %s", err, id, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if incident.UserID != uid {
+		errorMsg := "Incident is owned by another user"
+		logrus.Errorf("incident.UserID != uid in ackAlertIncidentHandler %q - uid: %s incident: %+v", errorMsg, uid, incident)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	if err := db.AcknowledgeAlertIncident(id, now); err != nil {
+		logrus.Errorf("db.AcknowledgeAlertIncident in ackAlertIncidentHandler %q - %+v", err, incident)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	incident.Acknowledged = true
+	incident.AcknowledgedAt = null.TimeFrom(now)
+
+	if err := json.NewEncoder(w).Encode(incident); err != nil {
+		logrus.Errorf("json.NewEncoder in ackAlertIncidentHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Init -
+func Init(router *httprouter.Router) {
+	auth := middlewares.AuthStack()
+
+	router.POST("/alertrule", auth.Wrap(createAlertRuleHandler))
+	router.PUT("/alertrule", auth.Wrap(updateAlertRuleHandler))
+
+	router.POST("/alertincident/:id/ack", auth.Wrap(ackAlertIncidentHandler))
+
+	router.GET("/box/:id/alerts", auth.Wrap(getBoxAlertsHandler))
+	router.PUT("/box/:id/alerts", auth.Wrap(setBoxAlertsHandler))
+
+	router.GET("/box/:id/alertrules", auth.Wrap(selectBoxAlertRules))
+	router.GET("/plant/:id/alertincidents", auth.Wrap(selectPlantAlertIncidents))
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/server/tools"
+	"github.com/SuperGreenLab/AppBackend/internal/services/alerts"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+)
+
+type boxAlertsResponse struct {
+	Settings     db.AlertThresholds           `json:"settings"`
+	CurrentStage string                       `json:"currentStage"`
+	Thresholds   alerts.Thresholds            `json:"thresholds"`
+	Presets      map[string]alerts.Thresholds `json:"presets"`
+}
+
+func loadUserBox(w http.ResponseWriter, r *http.Request, p httprouter.Params, caller string) (appbackend.Box, bool) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		logrus.Errorf("uuid.FromString in %s %q - uid: %s", caller, err, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return appbackend.Box{}, false
+	}
+	box, err := db.GetBox(id)
+	if err != nil {
+		logrus.Errorf("db.GetBox in %s %q - id: %s uid: %s", caller, err, id, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return box, false
+	}
+	if box.UserID != uid {
+		errorMsg := "Box is owned by another user"
+		logrus.Errorf("box.UserID != uid in %s %q - uid: %s box: %+v", caller, errorMsg, uid, box)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return box, false
+	}
+	return box, true
+}
+
+func writeBoxAlerts(w http.ResponseWriter, box appbackend.Box, t db.AlertThresholds, caller string) {
+	plants, err := db.GetActivePlantsForBox(box.ID.UUID)
+	if err != nil {
+		logrus.Errorf("db.GetActivePlantsForBox in %s %q - %+v", caller, err, box)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stage, thresholds := alerts.ResolveThresholds(t, plants)
+
+	// the alerts read the new thresholds on the next metric
+	if box.DeviceID.Valid && box.DeviceBox != nil {
+		device, err := db.GetDevice(box.DeviceID.UUID)
+		if err != nil {
+			logrus.Errorf("db.GetDevice in %s %q - %+v", caller, err, box)
+		} else if err := kv.DelAlertThresholds(device.Identifier, int(*box.DeviceBox)); err != nil {
+			logrus.Errorf("kv.DelAlertThresholds in %s %q - %+v", caller, err, box)
+		}
+	}
+
+	response := boxAlertsResponse{
+		Settings:     t,
+		CurrentStage: stage,
+		Thresholds:   thresholds,
+		Presets:      alerts.StagePresets,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.Errorf("json.NewEncoder in %s %q", caller, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func getBoxAlertsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	box, ok := loadUserBox(w, r, p, "getBoxAlertsHandler")
+	if !ok {
+		return
+	}
+
+	t, err := db.GetAlertThresholdsForBox(box.ID.UUID)
+	if err == udb.ErrNoMoreRows {
+		t = db.AlertThresholds{UserID: box.UserID, BoxID: box.ID.UUID, Stage: db.AlertStageAuto}
+	} else if err != nil {
+		logrus.Errorf("db.GetAlertThresholdsForBox in getBoxAlertsHandler %q - %+v", err, box)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeBoxAlerts(w, box, t, "getBoxAlertsHandler")
+}
+
+func setBoxAlertsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	t := db.AlertThresholds{}
+	if err := tools.DecodeJSONBody(w, r, &t); err != nil {
+		logrus.Errorf("tools.DecodeJSONBody in setBoxAlertsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if t.Stage == "" {
+		t.Stage = db.AlertStageAuto
+	}
+	if err := alerts.ValidateAlertThresholds(t); err != nil {
+		logrus.Errorf("alerts.ValidateAlertThresholds in setBoxAlertsHandler %q - %+v", err, t)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	box, ok := loadUserBox(w, r, p, "setBoxAlertsHandler")
+	if !ok {
+		return
+	}
+	t.UserID = box.UserID
+	t.BoxID = box.ID.UUID
+
+	t, err := db.SetAlertThresholds(t)
+	if err != nil {
+		logrus.Errorf("db.SetAlertThresholds in setBoxAlertsHandler %q - %+v", err, t)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeBoxAlerts(w, box, t, "setBoxAlertsHandler")
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/server/tools"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// checkDeviceIdentifierClaim - refuses to create or update a device with an
+// identifier claimed by another user
+func checkDeviceIdentifierClaim(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		o := r.Context().Value(middlewares.ObjectContextKey{}).(*appbackend.Device)
+		claim, err := db.GetDeviceClaim(o.Identifier)
+		if err != nil && err != udb.ErrNoMoreRows {
+			logrus.Errorf("db.GetDeviceClaim in checkDeviceIdentifierClaim %q - %+v", err, o)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err == nil && claim.UserID != o.UserID {
+			errorMsg := "Device is claimed by another user"
+			logrus.Errorf("claim.UserID != o.UserID in checkDeviceIdentifierClaim %q - %+v", errorMsg, o)
+			http.Error(w, errorMsg, http.StatusConflict)
+			return
+		}
+		fn(w, r, p)
+	}
+}
+
+// filterClaimed - only returns devices claimed by the caller
+func filterClaimed(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		selector = selector.Where("exists (select 1 from deviceclaims dcl where dcl.identifier = t.identifier and dcl.userid = ?)", uid)
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+// loadClaimedDevice - loads the caller's device, writes the error response and
+// returns false when it's not found, owned by another user, or not claimed when
+// mustBeClaimed is set
+func loadClaimedDevice(w http.ResponseWriter, r *http.Request, p httprouter.Params, caller string, mustBeClaimed bool) (appbackend.Device, bool) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		logrus.Errorf("uuid.FromString in %s %q - uid: %s", caller, err, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return appbackend.Device{}, false
+	}
+	device, err := db.GetDevice(id)
+	if err != nil {
+		logrus.Errorf("db.GetDevice in %s %q - id: %s uid: %s", caller, err, id, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return device, false
+	}
+	if device.UserID != uid {
+		errorMsg := "Device is owned by another user"
+		logrus.Errorf("device.UserID != uid in %s %q - uid: %s device: %+v", caller, errorMsg, uid, device)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return device, false
+	}
+	if !mustBeClaimed {
+		return device, true
+	}
+	claimed, err := db.IsDeviceClaimedBy(device.Identifier, uid)
+	if err != nil {
+		logrus.Errorf("db.IsDeviceClaimedBy in %s %q - uid: %s device: %+v", caller, err, uid, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return device, false
+	}
+	if !claimed {
+		errorMsg := "Device is not claimed"
+		logrus.Errorf("db.IsDeviceClaimedBy in %s %q - uid: %s device: %+v", caller, errorMsg, uid, device)
+		http.Error(w, errorMsg, http.StatusForbidden)
+		return device, false
+	}
+	return device, true
+}
+
+func outputDeviceClaim(w http.ResponseWriter, claim db.DeviceClaim, caller string) {
+	if err := json.NewEncoder(w).Encode(claim); err != nil {
+		logrus.Errorf("json.NewEncoder in %s %q", caller, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// checkClaimable - only the first registrant of an unclaimed identifier can
+// ask for a claim code and use it, writes the error response and returns false otherwise
+func checkClaimable(w http.ResponseWriter, device appbackend.Device, caller string) bool {
+	if _, err := db.GetDeviceClaim(device.Identifier); err == nil {
+		errorMsg := "Device is already claimed"
+		logrus.Errorf("db.GetDeviceClaim in %s %q - %+v", caller, errorMsg, device)
+		http.Error(w, errorMsg, http.StatusConflict)
+		return false
+	} else if err != udb.ErrNoMoreRows {
+		logrus.Errorf("db.GetDeviceClaim in %s %q - %+v", caller, err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	registrant, err := db.GetFirstDeviceRegistrant(device.Identifier)
+	if err != nil {
+		logrus.Errorf("db.GetFirstDeviceRegistrant in %s %q - %+v", caller, err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if registrant != device.UserID {
+		errorMsg := "Device is registered by another user"
+		logrus.Errorf("db.GetFirstDeviceRegistrant in %s %q - %+v", caller, errorMsg, device)
+		http.Error(w, errorMsg, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// claimCodeHandler - sends a one-time claim code to the controller, the app
+// reads it from the controller on the LAN and sends it to claimDeviceHandler
+func claimCodeHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	device, ok := loadClaimedDevice(w, r, p, "claimCodeHandler", false)
+	if !ok {
+		return
+	}
+	if !checkClaimable(w, device, "claimCodeHandler") {
+		return
+	}
+	if err := devices.IssueClaimCode(device.Identifier); err != nil {
+		logrus.Errorf("devices.IssueClaimCode in claimCodeHandler %q - %+v", err, device)
+		status := http.StatusInternalServerError
+		if err == devices.ErrControllerNotConnected {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	middlewares.OutputOK(w, r, p)
+}
+
+type ClaimDeviceParams struct {
+	Code string `json:"code"`
+}
+
+func claimDeviceHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	params := ClaimDeviceParams{}
+	if err := tools.DecodeJSONBody(w, r, &params); err != nil {
+		logrus.Errorf("tools.DecodeJSONBody in claimDeviceHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, ok := loadClaimedDevice(w, r, p, "claimDeviceHandler", false)
+	if !ok {
+		return
+	}
+
+	claim, err := db.GetDeviceClaim(device.Identifier)
+	if err == nil {
+		if claim.UserID == uid {
+			outputDeviceClaim(w, claim, "claimDeviceHandler")
+			return
+		}
+		errorMsg := "Device is claimed by another user"
+		logrus.Errorf("db.GetDeviceClaim in claimDeviceHandler %q - uid: %s device: %+v", errorMsg, uid, device)
+		http.Error(w, errorMsg, http.StatusConflict)
+		return
+	} else if err != udb.ErrNoMoreRows {
+		logrus.Errorf("db.GetDeviceClaim in claimDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !checkClaimable(w, device, "claimDeviceHandler") {
+		return
+	}
+
+	valid, err := devices.CheckClaimCode(device.Identifier, params.Code)
+	if err != nil {
+		logrus.Errorf("devices.CheckClaimCode in claimDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		errorMsg := "Invalid or expired claim code"
+		logrus.Errorf("devices.CheckClaimCode in claimDeviceHandler %q - uid: %s device: %+v", errorMsg, uid, device)
+		http.Error(w, errorMsg, http.StatusForbidden)
+		return
+	}
+
+	claim, err = db.ClaimDevice(device.Identifier, uid, time.Now())
+	if err != nil {
+		// most likely a concurrent claim on the same identifier
+		logrus.Errorf("db.ClaimDevice in claimDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	outputDeviceClaim(w, claim, "claimDeviceHandler")
+}
+
+type TransferDeviceParams struct {
+	Nickname string `json:"nickname"`
+}
+
+// transferDeviceHandler - hands the claim over to another user, who then
+// adds the device to their account with POST /device
+func transferDeviceHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	params := TransferDeviceParams{}
+	if err := tools.DecodeJSONBody(w, r, &params); err != nil {
+		logrus.Errorf("tools.DecodeJSONBody in transferDeviceHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, ok := loadClaimedDevice(w, r, p, "transferDeviceHandler", true)
+	if !ok {
+		return
+	}
+
+	to, err := db.GetUserForNickname(params.Nickname)
+	if err != nil {
+		logrus.Errorf("db.GetUserForNickname in transferDeviceHandler %q - %+v", err, params)
+		http.Error(w, "Unknown user", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if _, err := db.TransferDeviceClaim(device.Identifier, uid, to.ID.UUID, now); err != nil {
+		logrus.Errorf("db.TransferDeviceClaim in transferDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	claim, err := db.GetDeviceClaim(device.Identifier)
+	if err != nil {
+		logrus.Errorf("db.GetDeviceClaim in transferDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	outputDeviceClaim(w, claim, "transferDeviceHandler")
+}
+
+func releaseDeviceHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	device, ok := loadClaimedDevice(w, r, p, "releaseDeviceHandler", true)
+	if !ok {
+		return
+	}
+	if _, err := db.ReleaseDeviceClaim(device.Identifier, uid); err != nil {
+		logrus.Errorf("db.ReleaseDeviceClaim in releaseDeviceHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	middlewares.OutputOK(w, r, p)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/server/tools"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+type UpdateDeviceParamParams struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// updateDeviceParamHandler - queues a parameter write for the controller,
+// the returned command's status tells if the controller applied it.
+func updateDeviceParamHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	params := UpdateDeviceParamParams{}
+	if err := tools.DecodeJSONBody(w, r, &params); err != nil {
+		logrus.Errorf("tools.DecodeJSONBody in updateDeviceParamHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, ok := loadClaimedDevice(w, r, p, "updateDeviceParamHandler", true)
+	if !ok {
+		return
+	}
+
+	cmd, err := devices.NewCommand(device, uid, params.Key, params.Value)
+	if err != nil {
+		logrus.Errorf("devices.NewCommand in updateDeviceParamHandler %q - %+v", err, params)
+		status := http.StatusInternalServerError
+		var invalidParam devices.InvalidParamError
+		if err == devices.ErrUnknownParam || errors.As(err, &invalidParam) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	cmd, err = devices.QueueCommand(cmd)
+	if err != nil {
+		logrus.Errorf("devices.QueueCommand in updateDeviceParamHandler %q - %+v", err, cmd)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(cmd); err != nil {
+		logrus.Errorf("json.NewEncoder in updateDeviceParamHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type SelectDeviceCommandsParams struct {
+	middlewares.SelectParamsOffsetLimit
+
+	Pending bool
+}
+
+func filterDeviceCommands(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		params := r.Context().Value(middlewares.QueryObjectContextKey{}).(*SelectDeviceCommandsParams)
+		selector = selector.Where("t.userid = ?", uid).And("t.deviceid = ?", p.ByName("id"))
+		if params.Pending {
+			selector = selector.And("t.status in ?", []string{db.DeviceCommandStatusPending, db.DeviceCommandStatusSent})
+		}
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectDeviceCommands = middlewares.SelectEndpoint(
+	"devicecommands",
+	func() interface{} { return &[]db.DeviceCommand{} },
+	func() interface{} { return &SelectDeviceCommandsParams{} },
+	[]middleware.Middleware{
+		filterDeviceCommands,
+	},
+	[]middleware.Middleware{},
+)
@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gorilla/schema"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+const deviceKVStreamKeepAlive = 30 * time.Second
+
+var (
+	deviceKeyRegexp = regexp.MustCompile(`^[A-Z0-9_*]+$`)
+)
+
+type SelectDeviceKVParams struct {
+	Keys []string
+}
+
+type SelectDeviceKVResponse struct {
+	KV map[string]interface{} `json:"kv"`
+}
+
+// deviceKeyPatterns - keys can be repeated or comma separated, all keys when empty
+func deviceKeyPatterns(keys []string) ([]string, error) {
+	patterns := []string{}
+	for _, k := range keys {
+		for _, p := range strings.Split(k, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			if !deviceKeyRegexp.MatchString(p) {
+				return nil, fmt.Errorf("Invalid key %s", p)
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = append(patterns, "*")
+	}
+	return patterns, nil
+}
+
+// loadDeviceKV - returns the device's KV values, without the <cid>.KV. prefix
+func loadDeviceKV(device appbackend.Device, patterns []string) (map[string]interface{}, error) {
+	prefix := fmt.Sprintf("%s.KV.", device.Identifier)
+	prefixed := make([]string, len(patterns))
+	for i, p := range patterns {
+		prefixed[i] = prefix + p
+	}
+	keys, err := kv.GetKeys(prefixed)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if len(keys) == 0 {
+		return values, nil
+	}
+	m, err := kv.GetValues(keys)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range m {
+		if v == nil {
+			continue
+		}
+		values[strings.TrimPrefix(k, prefix)] = v
+	}
+	return values, nil
+}
+
+func loadKV(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		params := r.Context().Value(middlewares.QueryObjectContextKey{}).(*SelectDeviceKVParams)
+		device := r.Context().Value(middlewares.SelectResultContextKey{}).(*appbackend.Device)
+		patterns, err := deviceKeyPatterns(params.Keys)
+		if err != nil {
+			logrus.Errorf("deviceKeyPatterns in loadKV %q - %+v", err, params)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values, err := loadDeviceKV(*device, patterns)
+		if err != nil {
+			logrus.Errorf("loadDeviceKV in loadKV %q - %+v", err, params)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), middlewares.SelectResultContextKey{}, SelectDeviceKVResponse{KV: values})
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectDeviceKV = middlewares.SelectOneEndpoint(
+	"devices",
+	func() interface{} { return &appbackend.Device{} },
+	func() interface{} { return &SelectDeviceKVParams{} },
+	[]middleware.Middleware{
+		filterID,
+		filterUserID,
+		filterClaimed,
+	},
+	[]middleware.Middleware{
+		loadKV,
+	},
+)
+
+func writeSSE(w http.ResponseWriter, f http.Flusher, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	f.Flush()
+	return nil
+}
+
+// streamDeviceKVHandler - server-sent events, a snapshot event with the current
+// values, then an update event for each key published by the controller.
+func streamDeviceKVHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	params := SelectDeviceKVParams{}
+	if err := schema.NewDecoder().Decode(&params, r.URL.Query()); err != nil {
+		logrus.Errorf("schema.Decode in streamDeviceKVHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patterns, err := deviceKeyPatterns(params.Keys)
+	if err != nil {
+		logrus.Errorf("deviceKeyPatterns in streamDeviceKVHandler %q - %+v", err, params)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, ok := loadClaimedDevice(w, r, p, "streamDeviceKVHandler", true)
+	if !ok {
+		return
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		err := errors.New("Streaming not supported")
+		logrus.Errorf("w.(http.Flusher) in streamDeviceKVHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// subscribes before loading the snapshot so no update is lost in between
+	ch := pubsub.SubscribeControllerKeys(r.Context(), device.Identifier, patterns)
+
+	values, err := loadDeviceKV(device, patterns)
+	if err != nil {
+		logrus.Errorf("loadDeviceKV in streamDeviceKVHandler %q - %+v", err, device)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if err := writeSSE(w, f, "snapshot", SelectDeviceKVResponse{KV: values}); err != nil {
+		logrus.Errorf("writeSSE in streamDeviceKVHandler %q - %+v", err, device)
+		return
+	}
+
+	ticker := time.NewTicker(deviceKVStreamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case update, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, f, "update", update); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
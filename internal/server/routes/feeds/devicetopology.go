@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package feeds
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+type SelectDeviceTopologyParams struct{}
+
+func loadTopology(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		device := r.Context().Value(middlewares.SelectResultContextKey{}).(*appbackend.Device)
+		topology, err := devices.GetTopology(device.Identifier)
+		if err != nil {
+			logrus.Errorf("devices.GetTopology in loadTopology %q - %+v", err, device)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), middlewares.SelectResultContextKey{}, topology)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectDeviceTopology = middlewares.SelectOneEndpoint(
+	"devices",
+	func() interface{} { return &appbackend.Device{} },
+	func() interface{} { return &SelectDeviceTopologyParams{} },
+	[]middleware.Middleware{
+		filterID,
+		filterUserID,
+		filterClaimed,
+	},
+	[]middleware.Middleware{
+		loadTopology,
+	},
+)
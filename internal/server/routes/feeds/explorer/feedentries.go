@@ -40,6 +40,11 @@ type SelectFeedEntriesEndpointBuilder struct {
 	Selector middleware.Middleware
 }
 
+func (dbe SelectFeedEntriesEndpointBuilder) SetParam(param middlewares.Factory) SelectFeedEntriesEndpointBuilder {
+	dbe.Params = middlewares.DecodeQuery(param)
+	return dbe
+}
+
 func (dbe SelectFeedEntriesEndpointBuilder) Endpoint() middlewares.Endpoint {
 	if dbe.Cache != nil {
 		dbe.Pre = append([]middleware.Middleware{dbe.Cache}, dbe.Pre...)
@@ -65,7 +65,7 @@ func NewSelectPlantsEndpointBuilder(pre []middleware.Middleware) SelectPlantsEnd
 func NewSelectPlantsEndpointBuilderWithSelector(selector middleware.Middleware, pre []middleware.Middleware) SelectPlantsEndpointBuilder {
 	pre = append([]middleware.Middleware{
 		selector,
-		publicPlantsOnly,
+		PublicPlantsOnly,
 		joinLatestPlantFeedMedia,
 		joinBoxSettings,
 		joinFollows,
@@ -99,7 +99,7 @@ func NewSelectPlantEndpointBuilder(pre []middleware.Middleware) SelectPlantsEndp
 
 	pre = append([]middleware.Middleware{
 		defaultSelector,
-		publicPlantsOnly,
+		PublicPlantsOnly,
 		joinLatestPlantFeedMedia,
 		joinBoxSettings,
 		joinFollows,
@@ -164,7 +164,8 @@ func joinFeedEntrySocialSelector(fn httprouter.Handle) httprouter.Handle {
 	}
 }
 
-func publicPlantsOnly(fn httprouter.Handle) httprouter.Handle {
+// PublicPlantsOnly - restricts the selector's plants p to the ones shown in the explorer
+func PublicPlantsOnly(fn httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
 
@@ -35,6 +35,7 @@ func Init(router *httprouter.Router) {
 	router.GET("/public/plants", optionalAuth.Wrap(fetchLatestUpdatedPublicPlants))
 	router.GET("/public/plants/search", optionalAuth.Wrap(searchPublicPlants))
 	router.GET("/public/feedEntries", optionalAuth.Wrap(fetchLatestPublicFeedEntries))
+	router.GET("/public/feedEntries/search", optionalAuth.Wrap(searchPublicFeedEntries))
 	router.GET("/public/plant/:id", optionalAuth.Wrap(fetchPublicPlant))
 	router.GET("/public/plant/:id/feedEntries", optionalAuth.Wrap(fetchPublicPlantFeedEntries))
 	router.GET("/public/feedEntries/commented", optionalAuth.Wrap(fetchLatestCommentedFeedEntries))
@@ -42,4 +43,9 @@ func Init(router *httprouter.Router) {
 	router.GET("/public/feedEntry/:id", optionalAuth.Wrap(fetchPublicFeedEntry))
 	router.GET("/public/feedEntry/:id/feedMedias", optionalAuth.Wrap(fetchPublicEntryFeedMedias))
 	router.GET("/public/feedMedia/:id", optionalAuth.Wrap(fetchPublicFeedMedia))
+
+	router.GET("/public/strains", optionalAuth.Wrap(searchStrainsHandler))
+	router.GET("/public/strains/:id", optionalAuth.Wrap(fetchStrainHandler))
+	router.GET("/public/strains/:id/plants", optionalAuth.Wrap(fetchStrainPublicPlants))
+	router.GET("/public/strains/:id/feedMedias", optionalAuth.Wrap(fetchStrainFeedMedias))
 }
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package explorer
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+type SearchFeedEntriesParams struct {
+	SelectFeedEntriesParams
+
+	Q string
+}
+
+// searchFeedEntriesSelector - entries whose message matches all the words of
+// q, uses the fe_message_search index
+func searchFeedEntriesSelector(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		sess := r.Context().Value(middlewares.SessContextKey{}).(sqlbuilder.Database)
+		params := r.Context().Value(middlewares.QueryObjectContextKey{}).(*SearchFeedEntriesParams)
+
+		q := searchTSQuery(params.Q)
+		if q == "" {
+			errorMsg := "Missing q parameter"
+			logrus.Errorf("searchTSQuery in searchFeedEntriesSelector %q - q: %s", errorMsg, params.Q)
+			http.Error(w, errorMsg, http.StatusBadRequest)
+			return
+		}
+
+		selector := sess.Select("fe.*").From("feedentries fe").
+			Where(udb.Raw("to_tsvector('simple', coalesce(fe.params->>'message', '')) @@ to_tsquery('simple', ?)", q)).
+			OrderBy(udb.Raw("ts_rank(to_tsvector('simple', coalesce(fe.params->>'message', '')), to_tsquery('simple', ?)) desc, fe.createdat desc", q)).
+			Offset(params.GetOffset()).Limit(params.GetLimit())
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var searchPublicFeedEntries = NewSelectFeedEntriesEndpointBuilderWithSelector(searchFeedEntriesSelector, []middleware.Middleware{
+	joinPlantForFeedEntry,
+	joinBoxSettings,
+	joinFollows,
+}).SetParam(func() interface{} { return &SearchFeedEntriesParams{} }).JoinSocial().Endpoint().Handle()
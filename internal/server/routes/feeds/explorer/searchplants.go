@@ -21,7 +21,9 @@ package explorer
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
 	"github.com/julienschmidt/httprouter"
@@ -30,29 +32,55 @@ import (
 	"upper.io/db.v3/lib/sqlbuilder"
 )
 
+var searchWordRegexp = regexp.MustCompile(`[\pL\pN]+`)
+
+// searchTSQuery - all the words of q as prefixes, only letters and digits are
+// kept so the query can't break to_tsquery's syntax
+func searchTSQuery(q string) string {
+	words := searchWordRegexp.FindAllString(strings.ToLower(q), -1)
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
 type SearchPlantsParams struct {
 	SelectPlantsParams
 
 	Q string
+
+	Type         string
+	Stage        string
+	HasMedia     bool
+	UpdatedSince int64
 }
 
+// searchPublicPlants - ranked search over the plants' search documents,
+// maintained by the plantsearch service
 var searchPublicPlants = NewSelectPlantsEndpointBuilder([]middleware.Middleware{
 	func(fn httprouter.Handle) httprouter.Handle {
 		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 			selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
 			params := r.Context().Value(middlewares.QueryObjectContextKey{}).(*SearchPlantsParams)
 
-			searchTests := []udb.Compound{}
-			qs := strings.Split(params.Q, " ")
-			for _, q := range qs {
-				searchTests = append(searchTests,
-					udb.Or(udb.Raw("p.name ilike '%' || ? || '%'", q)).
-						Or(udb.Raw("p.settings::text ilike '%' || ? || '%'", q)).
-						Or(udb.Raw("boxes.settings::text ilike '%' || ? || '%'", q)),
-				)
+			selector = selector.Join("plantsearches psr").On("psr.plantid = p.id")
+			if params.Type != "" {
+				selector = selector.And("psr.ptype = ?", params.Type)
+			}
+			if params.Stage != "" {
+				selector = selector.And("psr.stage = ?", params.Stage)
+			}
+			if params.HasMedia {
+				selector = selector.And("psr.hasmedia = true")
+			}
+			if params.UpdatedSince > 0 {
+				selector = selector.And("psr.lastupdate >= ?", time.Unix(params.UpdatedSince, 0))
+			}
+			if q := searchTSQuery(params.Q); q != "" {
+				selector = selector.And(udb.Raw("psr.document @@ to_tsquery('simple', ?)", q)).
+					OrderBy(udb.Raw("ts_rank(psr.document, to_tsquery('simple', ?)) desc, latestferow.createdat desc", q))
 			}
 
-			selector = selector.Where(udb.And(searchTests...))
 			ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
 			fn(w, r.WithContext(ctx), p)
 		}
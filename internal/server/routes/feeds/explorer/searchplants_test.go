@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package explorer
+
+import "testing"
+
+func TestSearchTSQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"Blue", "blue:*"},
+		{"blue dream", "blue:* & dream:*"},
+		{"  Blue   DREAM ", "blue:* & dream:*"},
+		{"gorilla glue #4", "gorilla:* & glue:* & 4:*"},
+		{"og-kush", "og:* & kush:*"},
+		{"a & b | !c", "a:* & b:* & c:*"},
+		{"'); drop table plants; --", "drop:* & table:* & plants:*"},
+		{"crème brûlée", "crème:* & brûlée:*"},
+		{"&|!():*", ""},
+	}
+	for _, tt := range tests {
+		if got := searchTSQuery(tt.q); got != tt.want {
+			t.Errorf("searchTSQuery(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/strains"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+const (
+	strainsSearchMinLength = 2
+	strainsSearchLimit     = 20
+	strainYieldsMinGrowers = 3
+)
+
+// searchStrainsHandler - trigram search over the catalog's strain names and aliases
+func searchStrainsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	q := strains.Normalize(r.URL.Query().Get("q"))
+	if len(q) < strainsSearchMinLength {
+		errorMsg := "q should be at least 2 characters long"
+		logrus.Errorf("len(q) in searchStrainsHandler %q - q: %s", errorMsg, q)
+		http.Error(w, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.SearchStrains(q, strainsSearchLimit)
+	if err != nil {
+		logrus.Errorf("db.SearchStrains in searchStrainsHandler %q - q: %s", err, q)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Strains []db.StrainResult `json:"strains"`
+	}{res}); err != nil {
+		logrus.Errorf("json.NewEncoder in searchStrainsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// fetchStrainHandler - the strain with its public plants' stats, the plants
+// and their photos are served by fetchStrainPublicPlants and fetchStrainFeedMedias
+func fetchStrainHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		logrus.Errorf("uuid.FromString in fetchStrainHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	strain, err := db.GetStrain(id)
+	if err == udb.ErrNoMoreRows {
+		logrus.Errorf("db.GetStrain in fetchStrainHandler %q - id: %s", err, id)
+		http.Error(w, "Unknown strain", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.Errorf("db.GetStrain in fetchStrainHandler %q - id: %s", err, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := db.GetStrainStats(id, strainYieldsMinGrowers)
+	if err != nil {
+		logrus.Errorf("db.GetStrainStats in fetchStrainHandler %q - id: %s", err, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Strain db.StrainResult `json:"strain"`
+		Stats  db.StrainStats  `json:"stats"`
+	}{strain, stats}); err != nil {
+		logrus.Errorf("json.NewEncoder in fetchStrainHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var fetchStrainPublicPlants = NewSelectPlantsEndpointBuilder([]middleware.Middleware{
+	middlewares.Filter(func(p httprouter.Params, selector sqlbuilder.Selector) sqlbuilder.Selector {
+		return selector.Join("plantstrains ps").On("ps.plantid = p.id").Where("ps.strainid = ?", p.ByName("id"))
+	}),
+}).Endpoint().Handle()
+
+var fetchStrainFeedMedias = NewSelectFeedMediasEndpointBuilder([]middleware.Middleware{
+	middlewares.Filter(func(p httprouter.Params, selector sqlbuilder.Selector) sqlbuilder.Selector {
+		return selector.Join("plantstrains ps").On("ps.plantid = pfmo.id").
+			Where("ps.strainid = ?", p.ByName("id")).
+			And("pfmo.deleted = false").
+			And("fe.deleted = false")
+	}),
+}).Endpoint().Handle()
@@ -51,7 +51,7 @@ func fillUserEnd(sess sqlbuilder.Database, ueid uuid.UUID, collection string, al
 var createUserEndHandler = middlewares.InsertEndpoint(
 	"userends",
 	func() interface{} { return &db.UserEnd{} },
-	[]middleware.Middleware{middlewares.SetUserID},
+	[]middleware.Middleware{middlewares.SetUserID, checkUserEndTransport},
 	[]middleware.Middleware{
 		func(fn httprouter.Handle) httprouter.Handle {
 			return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -205,7 +205,7 @@ var createTimelapseFrameHandler = middlewares.InsertEndpoint(
 var createDeviceHandler = middlewares.InsertEndpoint(
 	"devices",
 	func() interface{} { return &appbackend.Device{} },
-	[]middleware.Middleware{middlewares.SetUserID},
+	[]middleware.Middleware{middlewares.SetUserID, checkDeviceIdentifierClaim},
 	[]middleware.Middleware{
 		fmiddlewares.CreateUserEndObjects("userend_devices", func() db.UserEndObject { return &db.UserEndDevice{} }),
 	},
@@ -102,6 +102,15 @@ func Init(router *httprouter.Router) {
 	router.GET("/devices", auth.Wrap(selectDevices))
 	router.GET("/device/:id", auth.Wrap(selectDevice))
 	router.GET("/device/:id/params", auth.Wrap(selectDeviceParams))
+	router.GET("/device/:id/kv", auth.Wrap(selectDeviceKV))
+	router.GET("/device/:id/kv/stream", auth.Wrap(streamDeviceKVHandler))
+	router.GET("/device/:id/topology", auth.Wrap(selectDeviceTopology))
+	router.PUT("/device/:id/params", auth.Wrap(updateDeviceParamHandler))
+	router.GET("/device/:id/commands", auth.Wrap(selectDeviceCommands))
+	router.POST("/device/:id/claimcode", auth.Wrap(claimCodeHandler))
+	router.POST("/device/:id/claim", auth.Wrap(claimDeviceHandler))
+	router.POST("/device/:id/transfer", auth.Wrap(transferDeviceHandler))
+	router.POST("/device/:id/release", auth.Wrap(releaseDeviceHandler))
 	router.GET("/bookmarks", auth.Wrap(selectBookmarks))
 	router.GET("/bookmark/:id", auth.Wrap(selectBookmark))
 	router.GET("/timelapses", auth.Wrap(selectTimelapses))
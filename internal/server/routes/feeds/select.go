@@ -226,6 +226,7 @@ var selectDeviceParams = middlewares.SelectOneEndpoint(
 	[]middleware.Middleware{
 		filterID,
 		filterUserID,
+		filterClaimed,
 	},
 	[]middleware.Middleware{
 		loadParams,
@@ -25,6 +25,7 @@ import (
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
 	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
 	fmiddlewares "github.com/SuperGreenLab/AppBackend/internal/server/routes/feeds/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
 	"github.com/gofrs/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -82,6 +83,7 @@ var updateDeviceHandler = middlewares.UpdateEndpoint(
 		middlewares.ObjectIDRequired,
 		middlewares.SetUserID,
 		middlewares.CheckAccessRight("devices", "ID", false, func() appbackend.UserObject { return &appbackend.Device{} }),
+		checkDeviceIdentifierClaim,
 	},
 	[]middleware.Middleware{
 		fmiddlewares.UpdateUserEndObjects("userend_devices", "deviceid"),
@@ -150,7 +152,19 @@ var updateUserEndHandler = middlewares.UpdateEndpoint(
 	[]middleware.Middleware{
 		middlewares.SetUserID,
 		setUserEndID,
+		checkUserEndTransport,
 		middlewares.CheckAccessRight("userends", "ID", false, func() appbackend.UserObject { return &db.UserEnd{} }),
 	},
 	[]middleware.Middleware{},
 )
+
+func checkUserEndTransport(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ue := r.Context().Value(middlewares.ObjectContextKey{}).(*db.UserEnd)
+		if ue.NotificationTransport != "" && !notifications.IsKnownTransport(ue.NotificationTransport) {
+			http.Error(w, "Unknown notification transport", http.StatusBadRequest)
+			return
+		}
+		fn(w, r, p)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package harvests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+const (
+	// feedEntryTypeHarvest - the app shows media entries with their message,
+	// the harvest's photos are attached to it
+	feedEntryTypeHarvest = "FE_MEDIA"
+
+	publicYieldsMinGrowers = 3
+	publicYieldsLimit      = 100
+)
+
+type harvestFeedEntryParams struct {
+	Message   string `json:"message"`
+	HarvestID string `json:"harvestID"`
+}
+
+// harvestMessage - message of the harvest's feed entry, with its weights when set
+func harvestMessage(harvest db.Harvest) string {
+	message := "Harvest"
+	if harvest.WetWeight.Valid {
+		message += fmt.Sprintf(" - %gg wet", harvest.WetWeight.Float64)
+	}
+	if harvest.DryWeight.Valid {
+		message += fmt.Sprintf(" - %gg dry", harvest.DryWeight.Float64)
+	}
+	return message
+}
+
+func validateHarvest(harvest db.Harvest) error {
+	if (harvest.WetWeight.Valid && harvest.WetWeight.Float64 < 0) || (harvest.DryWeight.Valid && harvest.DryWeight.Float64 < 0) {
+		return errors.New("Weights can't be negative")
+	}
+	if harvest.WetWeight.Valid && harvest.DryWeight.Valid && harvest.DryWeight.Float64 > harvest.WetWeight.Float64 {
+		return errors.New("Dry weight can't be above wet weight")
+	}
+	if harvest.CureStart.Valid && harvest.CureEnd.Valid && harvest.CureEnd.Time.Before(harvest.CureStart.Time) {
+		return errors.New("Cure end can't be before cure start")
+	}
+	return nil
+}
+
+// checkHarvest - validates the harvest, and that its feed entry, when set, is
+// in its plant's feed
+func checkHarvest(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		harvest := r.Context().Value(middlewares.ObjectContextKey{}).(*db.Harvest)
+
+		if err := validateHarvest(*harvest); err != nil {
+			logrus.Errorf("validateHarvest in checkHarvest %q - %+v", err, harvest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if harvest.FeedEntryID.Valid {
+			plant, err := db.GetPlant(harvest.PlantID)
+			if err != nil {
+				logrus.Errorf("db.GetPlant in checkHarvest %q - %+v", err, harvest)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fe, err := db.GetFeedEntry(harvest.FeedEntryID.UUID)
+			if err != nil {
+				logrus.Errorf("db.GetFeedEntry in checkHarvest %q - %+v", err, harvest)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if fe.FeedID != plant.FeedID {
+				errorMsg := "Feed entry is not in the plant's feed"
+				logrus.Errorf("fe.FeedID != plant.FeedID in checkHarvest %q - %+v", errorMsg, harvest)
+				http.Error(w, errorMsg, http.StatusBadRequest)
+				return
+			}
+		}
+
+		fn(w, r, p)
+	}
+}
+
+// createHarvestFeedEntry - harvests recorded without a feed entry get one, so
+// their photos can be attached to it as feed medias
+func createHarvestFeedEntry(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		harvest := r.Context().Value(middlewares.ObjectContextKey{}).(*db.Harvest)
+		id := r.Context().Value(middlewares.InsertedIDContextKey{}).(uuid.UUID)
+
+		if harvest.FeedEntryID.Valid {
+			fn(w, r, p)
+			return
+		}
+
+		plant, err := db.GetPlant(harvest.PlantID)
+		if err != nil {
+			logrus.Errorf("db.GetPlant in createHarvestFeedEntry %q - %+v", err, harvest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		params, err := json.Marshal(harvestFeedEntryParams{Message: harvestMessage(*harvest), HarvestID: id.String()})
+		if err != nil {
+			logrus.Errorf("json.Marshal in createHarvestFeedEntry %q - %+v", err, harvest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		date := time.Now()
+		if harvest.CureStart.Valid {
+			date = harvest.CureStart.Time
+		}
+		feID, err := db.CreateFeedEntry(appbackend.FeedEntry{
+			UserID: plant.UserID,
+			FeedID: plant.FeedID,
+			Date:   date,
+			Type:   feedEntryTypeHarvest,
+			Params: string(params),
+		})
+		if err != nil {
+			logrus.Errorf("db.CreateFeedEntry in createHarvestFeedEntry %q - %+v", err, harvest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.SetHarvestFeedEntryID(id, feID); err != nil {
+			logrus.Errorf("db.SetHarvestFeedEntryID in createHarvestFeedEntry %q - %+v", err, harvest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fn(w, r, p)
+	}
+}
+
+var createHarvestHandler = middlewares.InsertEndpoint(
+	"harvests",
+	func() interface{} { return &db.Harvest{} },
+	[]middleware.Middleware{
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("plants", "PlantID", false, func() appbackend.UserObject { return &appbackend.Plant{} }),
+		checkHarvest,
+	},
+	[]middleware.Middleware{
+		createHarvestFeedEntry,
+	},
+)
+
+var updateHarvestHandler = middlewares.UpdateEndpoint(
+	"harvests",
+	func() interface{} { return &db.Harvest{} },
+	[]middleware.Middleware{
+		middlewares.ObjectIDRequired,
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("harvests", "ID", false, func() appbackend.UserObject { return &db.Harvest{} }),
+		middlewares.CheckAccessRight("plants", "PlantID", false, func() appbackend.UserObject { return &appbackend.Plant{} }),
+		checkHarvest,
+	},
+	[]middleware.Middleware{},
+)
+
+type SelectPlantHarvestsParams struct {
+	middlewares.SelectParamsOffsetLimit
+}
+
+func filterPlantHarvests(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		selector = selector.Where("t.userid = ?", uid).And("t.plantid = ?", p.ByName("id")).And("t.deleted = false")
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectPlantHarvests = middlewares.SelectEndpoint(
+	"harvests",
+	func() interface{} { return &[]db.Harvest{} },
+	func() interface{} { return &SelectPlantHarvestsParams{} },
+	[]middleware.Middleware{
+		filterPlantHarvests,
+	},
+	[]middleware.Middleware{},
+)
+
+type harvestYieldsResult struct {
+	Yields []db.HarvestYield `json:"yields"`
+}
+
+// selectHarvestYieldsHandler - the user's yields by strain and seed bank
+func selectHarvestYieldsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	yields, err := db.GetUserHarvestYields(uid)
+	if err != nil {
+		logrus.Errorf("db.GetUserHarvestYields in selectHarvestYieldsHandler %q - uid: %s", err, uid)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(harvestYieldsResult{yields}); err != nil {
+		logrus.Errorf("json.NewEncoder in selectHarvestYieldsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// selectPublicHarvestYieldsHandler - anonymised yields of the public plants by
+// strain and seed bank
+func selectPublicHarvestYieldsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	yields, err := db.GetPublicHarvestYields(publicYieldsMinGrowers, publicYieldsLimit)
+	if err != nil {
+		logrus.Errorf("db.GetPublicHarvestYields in selectPublicHarvestYieldsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(harvestYieldsResult{yields}); err != nil {
+		logrus.Errorf("json.NewEncoder in selectPublicHarvestYieldsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package harvests
+
+import (
+	"testing"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestHarvestMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		harvest db.Harvest
+		want    string
+	}{
+		{"no weights", db.Harvest{}, "Harvest"},
+		{"wet weight", db.Harvest{WetWeight: null.FloatFrom(420)}, "Harvest - 420g wet"},
+		{"dry weight", db.Harvest{DryWeight: null.FloatFrom(98.5)}, "Harvest - 98.5g dry"},
+		{"both weights", db.Harvest{WetWeight: null.FloatFrom(420), DryWeight: null.FloatFrom(98.5)}, "Harvest - 420g wet - 98.5g dry"},
+	}
+	for _, tt := range tests {
+		if got := harvestMessage(tt.harvest); got != tt.want {
+			t.Errorf("%s: harvestMessage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const maxComparedPlants = 4
+
+// ComparedDay - averages of one day of the grow, Day counts from germination
+type ComparedDay struct {
+	Day   int    `json:"day"`
+	Stage string `json:"stage"`
+
+	DayTemp   *float64 `json:"dayTemp"`
+	NightTemp *float64 `json:"nightTemp"`
+	DayHumi   *float64 `json:"dayHumi"`
+	NightHumi *float64 `json:"nightHumi"`
+	DLI       *float64 `json:"dli"`
+}
+
+type ComparedPlant struct {
+	PlantID  uuid.UUID `json:"plantID"`
+	Name     string    `json:"name"`
+	Public   bool      `json:"public"`
+	Archived bool      `json:"archived"`
+	Strain   string    `json:"strain"`
+	SeedBank string    `json:"seedBank"`
+
+	Stats PlantStats    `json:"stats"`
+	Days  []ComparedDay `json:"days"`
+}
+
+type CompareResult struct {
+	Days   int             `json:"days"`
+	Plants []ComparedPlant `json:"plants"`
+}
+
+// comparedDays - the hours grouped by day since from
+func comparedDays(hours []plantHour, stages []StageStats, from time.Time) []ComparedDay {
+	type dayMeans struct {
+		dayTemp, nightTemp, dayHumi, nightHumi mean
+		mol                                    float64
+		lightHours                             int
+	}
+	maxPPFD := viper.GetFloat64("DLIMaxPPFD")
+	means := []dayMeans{}
+	for _, h := range hours {
+		day := int((h.Time - from.Unix()) / (24 * 3600))
+		if day < 0 {
+			continue
+		}
+		for len(means) <= day {
+			means = append(means, dayMeans{})
+		}
+		if h.isDay() {
+			means[day].dayTemp.add(h.Temp)
+			means[day].dayHumi.add(h.Humi)
+		} else if h.Timer != nil {
+			means[day].nightTemp.add(h.Temp)
+			means[day].nightHumi.add(h.Humi)
+		}
+		if mol, ok := h.lightMol(maxPPFD); ok {
+			means[day].mol += mol
+			means[day].lightHours++
+		}
+	}
+
+	days := make([]ComparedDay, len(means))
+	for i, m := range means {
+		days[i] = ComparedDay{
+			Day:       i,
+			DayTemp:   m.dayTemp.Value(),
+			NightTemp: m.nightTemp.Value(),
+			DayHumi:   m.dayHumi.Value(),
+			NightHumi: m.nightHumi.Value(),
+		}
+		if s := stageAt(stages, from.Add(time.Duration(i)*24*time.Hour)); s >= 0 {
+			days[i].Stage = stages[s].Stage
+		}
+		if m.lightHours != 0 {
+			dli := m.mol / (float64(m.lightHours) / 24)
+			days[i].DLI = &dli
+		}
+	}
+	return days
+}
+
+func comparePlant(s plantSource, owner bool, now time.Time) (ComparedPlant, error) {
+	cp := ComparedPlant{
+		PlantID:  s.Plant.ID.UUID,
+		Name:     s.Plant.Name,
+		Public:   s.Plant.Public,
+		Archived: s.Plant.Archived,
+		Strain:   s.Plant.SettingString("strain"),
+		SeedBank: s.Plant.SettingString("seedBank"),
+	}
+	from, to := s.Plant.Lifetime(now)
+	hours, err := loadPlantHours(s, from, to)
+	if err != nil {
+		return cp, err
+	}
+	cp.Stats, err = computePlantStats(s, hours, from, to)
+	if err != nil {
+		return cp, err
+	}
+	cp.Days = comparedDays(hours, cp.Stats.Stages, from)
+	if !owner {
+		cp.Stats = cp.Stats.trimmed()
+	}
+	return cp, nil
+}
+
+// comparePlantsHandler - aligns plants on the days since their germination,
+// the caller's own and shared plants, or public ones like in the explorer,
+// plants the caller doesn't own only get trimmed stats
+func comparePlantsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ids := []uuid.UUID{}
+	for _, idStr := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if idStr == "" {
+			continue
+		}
+		id, err := uuid.FromString(idStr)
+		if err != nil {
+			log.Errorf("uuid.FromString in comparePlantsHandler %q - ids: %s", err, r.URL.Query().Get("ids"))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 || len(ids) > maxComparedPlants {
+		errorMsg := fmt.Sprintf("Compare between 2 and %d plants", maxComparedPlants)
+		log.Errorf("len(ids) in comparePlantsHandler %q - ids: %+v", errorMsg, ids)
+		http.Error(w, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	sources := make([]plantSource, len(ids))
+	for i, id := range ids {
+		s, ok := loadReadablePlantSource(w, r, id, true, false, "comparePlantsHandler")
+		if !ok {
+			return
+		}
+		sources[i] = s
+	}
+
+	now := time.Now()
+	res := CompareResult{Plants: make([]ComparedPlant, len(sources))}
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s plantSource) {
+			defer wg.Done()
+			res.Plants[i], errs[i] = comparePlant(s, isPlantOwner(r, s.Plant), now)
+		}(i, s)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			log.Errorf("comparePlant in comparePlantsHandler %q - plant: %+v", err, sources[i].Plant)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, cp := range res.Plants {
+		if len(cp.Days) > res.Days {
+			res.Days = len(cp.Days)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("json.NewEncoder in comparePlantsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exportMinResolution = time.Minute
+	exportMaxRows       = 100000
+	// exportChunkRows - rows loaded from the store, then written, at once
+	exportChunkRows = 1000
+)
+
+// exportWriter - writes the export's wide table, one row per timestamp
+type exportWriter interface {
+	header(columns []string) error
+	row(t int64, values []*float64) error
+	flush() error
+	close() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (ew *csvExportWriter) header(columns []string) error {
+	return ew.w.Write(append([]string{"timestamp"}, columns...))
+}
+
+func (ew *csvExportWriter) row(t int64, values []*float64) error {
+	record := make([]string, len(values)+1)
+	record[0] = time.Unix(t, 0).UTC().Format(time.RFC3339)
+	for i, v := range values {
+		if v != nil {
+			record[i+1] = strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	}
+	return ew.w.Write(record)
+}
+
+func (ew *csvExportWriter) flush() error {
+	ew.w.Flush()
+	return ew.w.Error()
+}
+
+func (ew *csvExportWriter) close() error {
+	return ew.flush()
+}
+
+// jsonExportWriter - {"columns": [...], "rows": [[timestamp, values...], ...]},
+// written as it goes instead of encoding the whole table at once
+type jsonExportWriter struct {
+	w    io.Writer
+	rows int
+}
+
+func (ew *jsonExportWriter) header(columns []string) error {
+	b, err := json.Marshal(append([]string{"timestamp"}, columns...))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ew.w, "{\"columns\":%s,\"rows\":[", b)
+	return err
+}
+
+func (ew *jsonExportWriter) row(t int64, values []*float64) error {
+	row := make([]interface{}, len(values)+1)
+	row[0] = t
+	for i, v := range values {
+		row[i+1] = v
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if ew.rows != 0 {
+		if _, err := ew.w.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	ew.rows++
+	_, err = ew.w.Write(b)
+	return err
+}
+
+func (ew *jsonExportWriter) flush() error {
+	return nil
+}
+
+func (ew *jsonExportWriter) close() error {
+	_, err := ew.w.Write([]byte("]}\n"))
+	return err
+}
+
+// loadExportChunk - averages of each series over the steps between start and end
+func loadExportChunk(s plantSource, columns []boxColumn, start, end, step int64) ([]map[int64]float64, error) {
+	values := make([]map[int64]float64, len(columns))
+	errs := make([]error, len(columns))
+	var wg sync.WaitGroup
+	for i, c := range columns {
+		wg.Add(1)
+		go func(i int, c boxColumn) {
+			defer wg.Done()
+			series := timeseries.Series{ControllerID: s.Device.Identifier, Name: c.Name}
+			ts, err := timeseries.Store.Downsample(series, start, end, step, timeseries.AggregationAvg)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			values[i] = map[int64]float64{}
+			for _, v := range ts {
+				values[i][int64(v[0])] = v[1]
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", columns[i].Name, err)
+		}
+	}
+	return values, nil
+}
+
+// exportPlantMetricsHandler - streams the plant's box metrics as a wide table,
+// averaged over each resolution step and clamped to the plant's lifetime
+func exportPlantMetricsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	s, ok := loadPlantSource(w, r, p, true, "exportPlantMetricsHandler")
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		errorMsg := fmt.Sprintf("Unknown format %s", format)
+		log.Errorf("format in exportPlantMetricsHandler %q", errorMsg)
+		http.Error(w, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	resolution := time.Hour
+	if v := r.URL.Query().Get("resolution"); v != "" {
+		d, err := parseWindow(v)
+		if err != nil || d < exportMinResolution {
+			log.Errorf("parseWindow in exportPlantMetricsHandler %q - resolution: %s", err, v)
+			http.Error(w, "Invalid resolution, should be between 1m and 30d", http.StatusBadRequest)
+			return
+		}
+		resolution = d
+	}
+
+	from, to, err := s.clampToLifetime(r, time.Now())
+	if err != nil {
+		log.Errorf("s.clampToLifetime in exportPlantMetricsHandler %q - plant: %+v", err, s.Plant)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step := int64(resolution.Seconds())
+	start := from.Unix() - from.Unix()%step
+	end := to.Unix()
+	if (end-start)/step >= exportMaxRows {
+		errorMsg := fmt.Sprintf("More than %d rows, use a coarser resolution or a shorter range", exportMaxRows)
+		log.Errorf("exportMaxRows in exportPlantMetricsHandler %q - from: %d to: %d step: %d", errorMsg, start, end, step)
+		http.Error(w, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	columns := s.columns()
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Column
+	}
+
+	var ew exportWriter
+	filename := fmt.Sprintf("plant-%s-%s.%s", s.Plant.ID.UUID, from.UTC().Format("20060102"), format)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		ew = &csvExportWriter{w: csv.NewWriter(w)}
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		ew = &jsonExportWriter{w: w}
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	flusher, _ := w.(http.Flusher)
+
+	// the status is sent with the header row, errors past this point can only
+	// truncate the export
+	if err := ew.header(names); err != nil {
+		log.Errorf("ew.header in exportPlantMetricsHandler %q", err)
+		return
+	}
+	chunk := step * exportChunkRows
+	for cs := start; cs <= end; cs += chunk {
+		ce := cs + chunk - step
+		if ce > end {
+			ce = end
+		}
+		values, err := loadExportChunk(s, columns, cs, ce, step)
+		if err != nil {
+			log.Errorf("loadExportChunk in exportPlantMetricsHandler %q - plant: %+v from: %d to: %d", err, s.Plant, cs, ce)
+			return
+		}
+		for t := cs; t <= ce; t += step {
+			row := make([]*float64, len(columns))
+			for i := range columns {
+				if v, ok := values[i][t]; ok {
+					row[i] = &v
+				}
+			}
+			if err := ew.row(t, row); err != nil {
+				log.Errorf("ew.row in exportPlantMetricsHandler %q", err)
+				return
+			}
+		}
+		if err := ew.flush(); err != nil {
+			log.Errorf("ew.flush in exportPlantMetricsHandler %q", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if err := ew.close(); err != nil {
+		log.Errorf("ew.close in exportPlantMetricsHandler %q", err)
+	}
+}
@@ -20,14 +20,17 @@ package metrics
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"math"
 	"net/http"
 	"regexp"
 	"strconv"
 	"time"
 
-	"github.com/SuperGreenLab/AppBackend/internal/data/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/gofrs/uuid"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
 )
@@ -37,6 +40,25 @@ var (
 	cidFilter   = regexp.MustCompile("[^a-f0-9]*")
 )
 
+// checkControllerAccess - writes the error response and returns false when the
+// caller can't read the controller's metrics
+func checkControllerAccess(w http.ResponseWriter, r *http.Request, cid, caller string) bool {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+	ok, err := db.CanReadControllerMetrics(uid, cid)
+	if err != nil {
+		log.Errorf("db.CanReadControllerMetrics in %s %q - uid: %s cid: %s", caller, err, uid, cid)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		errorMsg := "Unknown controller"
+		log.Errorf("db.CanReadControllerMetrics in %s %q - uid: %s cid: %s", caller, errorMsg, uid, cid)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
 // ServeMetricsHandler -
 func ServeMetricsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	var err error
@@ -112,22 +134,24 @@ func ServeMetricsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 	cid = cidFilter.ReplaceAllString(cid, "")
-
-	rr, err := prometheus.QueryProm(fmt.Sprintf("g_%s{id=\"%s\"}", q, cid), timeFrom, timeTo, n)
-
-	if err != nil {
-		log.Errorf("prometheus query failed: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if !checkControllerAccess(w, r, cid, "ServeMetricsHandler") {
 		return
 	}
 
-	if rr.Status != "success" {
-		log.Errorf("cid parameter error: %s\n", rr.Status)
+	series := timeseries.Series{ControllerID: cid, Name: q}
+	ts, err := timeseries.Store.QueryRange(series, timeFrom, timeTo, (timeTo-timeFrom)/int64(n))
+	var queryErr timeseries.QueryError
+	if errors.As(err, &queryErr) {
+		log.Errorf("cid parameter error: %s\n", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
+	} else if err != nil {
+		log.Errorf("timeseries query failed: %s\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	sr := newServedResult(rr, float64(min), float64(max))
+	sr := newServedResult(ts, float64(min), float64(max))
 
 	js, err := json.Marshal(sr)
 	if err != nil {
@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// errPlantWithoutController - the plant's box isn't linked to a controller
+var errPlantWithoutController = errors.New("Plant has no controller")
+
+// plantSource - a plant with the box and controller its metrics come from,
+// Device and DeviceBox are only set when HasController is
+type plantSource struct {
+	Plant         appbackend.Plant
+	Box           appbackend.Box
+	Device        appbackend.Device
+	DeviceBox     int
+	HasController bool
+}
+
+// boxColumn - one of the plant's box series, Column is its name in the exports
+type boxColumn struct {
+	Column string
+	Name   string
+}
+
+// columns - environment of the box, with its LED channels' dimming
+func (s plantSource) columns() []boxColumn {
+	columns := []boxColumn{}
+	for _, c := range []struct{ column, metric string }{
+		{"temperature", "TEMP"},
+		{"humidity", "HUMI"},
+		{"vpd", "VPD"},
+		{"timer", "TIMER_OUTPUT"},
+		{"blower", "BLOWER_DUTY"},
+	} {
+		columns = append(columns, boxColumn{Column: c.column, Name: fmt.Sprintf("BOX_%d_%s", s.DeviceBox, c.metric)})
+	}
+	topology, err := devices.GetTopology(s.Device.Identifier)
+	if err != nil {
+		log.Errorf("devices.GetTopology in plantSource.columns %q - device: %+v", err, s.Device)
+		return columns
+	}
+	if box, ok := topology.Box(s.DeviceBox); ok {
+		for _, led := range box.Leds {
+			columns = append(columns, boxColumn{Column: fmt.Sprintf("led_%d_dim", led), Name: fmt.Sprintf("LED_%d_DIM", led)})
+		}
+	}
+	return columns
+}
+
+// publicPlantSelector - plants p, routes allowing public plants wrap their
+// handler with explorer.PublicPlantsOnly to restrict it to the explorer's ones
+var publicPlantSelector = middlewares.Selector(func(sess sqlbuilder.Database) sqlbuilder.Selector {
+	return sess.Select("p.id").From("plants p")
+})
+
+// isPublicPlant - the plant is in the selector explorer.PublicPlantsOnly
+// restricted, false when the route doesn't allow public plants
+func isPublicPlant(r *http.Request, id uuid.UUID) (bool, error) {
+	selector, ok := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+	if !ok {
+		return false, nil
+	}
+	plants := []struct {
+		ID uuid.UUID `db:"id"`
+	}{}
+	if err := selector.And("p.id = ?", id).All(&plants); err != nil {
+		return false, err
+	}
+	return len(plants) != 0, nil
+}
+
+// canReadPlant - owners and users the plant was shared with can read it, and
+// anyone when allowPublic is set and the explorer shows the plant
+func canReadPlant(r *http.Request, plant appbackend.Plant, allowPublic bool) (bool, error) {
+	if uid, ok := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID); ok {
+		if plant.UserID == uid {
+			return true, nil
+		}
+		if shared, err := db.IsPlantSharedWith(plant.ID.UUID, uid); err != nil || shared {
+			return shared, err
+		}
+	}
+	if !allowPublic {
+		return false, nil
+	}
+	return isPublicPlant(r, plant.ID.UUID)
+}
+
+// isPlantOwner - the caller owns the plant, others only get trimmed stats
+func isPlantOwner(r *http.Request, plant appbackend.Plant) bool {
+	uid, ok := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+	return ok && plant.UserID == uid
+}
+
+// loadPlantSource - writes the error response and returns false when the plant
+// doesn't exist, isn't owned by or shared with the caller, or has no
+// controller while requireController is set
+func loadPlantSource(w http.ResponseWriter, r *http.Request, p httprouter.Params, requireController bool, caller string) (plantSource, bool) {
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		log.Errorf("uuid.FromString in %s %q", caller, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return plantSource{}, false
+	}
+	return loadReadablePlantSource(w, r, id, false, requireController, caller)
+}
+
+func loadReadablePlantSource(w http.ResponseWriter, r *http.Request, id uuid.UUID, allowPublic, requireController bool, caller string) (plantSource, bool) {
+	s := plantSource{}
+	var err error
+	s.Plant, err = db.GetPlant(id)
+	if err == udb.ErrNoMoreRows || (err == nil && s.Plant.Deleted) {
+		log.Errorf("db.GetPlant in %s %q - id: %s", caller, "Unknown plant", id)
+		http.Error(w, "Unknown plant", http.StatusNotFound)
+		return s, false
+	} else if err != nil {
+		log.Errorf("db.GetPlant in %s %q - id: %s", caller, err, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return s, false
+	}
+
+	ok, err := canReadPlant(r, s.Plant, allowPublic)
+	if err != nil {
+		log.Errorf("canReadPlant in %s %q - id: %s", caller, err, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return s, false
+	}
+	if !ok {
+		errorMsg := "Plant.UserID mismatch"
+		log.Errorf("canReadPlant in %s %q - id: %s", caller, errorMsg, id)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return s, false
+	}
+
+	if s, err = loadPlantDevice(s); err == errPlantWithoutController && !requireController {
+		return s, true
+	} else if err != nil {
+		log.Errorf("loadPlantDevice in %s %q - plant: %+v", caller, err, s.Plant)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return s, false
+	}
+	return s, true
+}
+
+func loadPlantDevice(s plantSource) (plantSource, error) {
+	var err error
+	s.Box, err = db.GetBox(s.Plant.BoxID)
+	if err != nil {
+		return s, err
+	}
+	if !s.Box.DeviceID.Valid || s.Box.DeviceBox == nil {
+		return s, errPlantWithoutController
+	}
+	// the controller's metrics belong to its owner, other registrants' plants have none
+	s.Device, err = db.GetControllerOwnerDevice(s.Box.DeviceID.UUID)
+	if err == udb.ErrNoMoreRows {
+		return s, errPlantWithoutController
+	} else if err != nil {
+		return s, err
+	}
+	s.DeviceBox = int(*s.Box.DeviceBox)
+	s.HasController = true
+	return s, nil
+}
+
+// clampToLifetime - restricts the from/to query parameters, unix timestamps,
+// to the plant's lifetime
+func (s plantSource) clampToLifetime(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	from, to := s.Plant.Lifetime(now)
+	if v := r.URL.Query().Get("from"); v != "" {
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return from, to, errors.New("Invalid from parameter")
+		}
+		if t := time.Unix(ts, 0); t.After(from) {
+			from = t
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return from, to, errors.New("Invalid to parameter")
+		}
+		if t := time.Unix(ts, 0); t.Before(to) {
+			to = t
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("Range is outside of the plant's lifetime")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	udb "upper.io/db.v3"
+)
+
+const (
+	publicMetricsMaxPoints = 48
+	publicMetricsMinStep   = time.Hour
+	publicMetricsMaxRange  = 30 * 24 * time.Hour
+)
+
+// publicMetrics - box metrics a public plant exposes
+var publicMetrics = map[string]bool{
+	"TEMP":         true,
+	"HUMI":         true,
+	"VPD":          true,
+	"TIMER_OUTPUT": true,
+	"BLOWER_DUTY":  true,
+}
+
+// servePublicPlantMetricsHandler - coarse averages of a public plant's box
+// metrics, at most publicMetricsMaxPoints points of at least an hour each
+func servePublicPlantMetricsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		log.Errorf("uuid.FromString in servePublicPlantMetricsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metrics := []string{"TEMP", "HUMI"}
+	if m := r.URL.Query().Get("metrics"); m != "" {
+		metrics = strings.Split(m, ",")
+	}
+	for _, m := range metrics {
+		if !publicMetrics[m] {
+			errorMsg := fmt.Sprintf("Unknown metric %s", m)
+			log.Errorf("publicMetrics in servePublicPlantMetricsHandler %q", errorMsg)
+			http.Error(w, errorMsg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	hours := 72
+	if t := r.URL.Query().Get("t"); t != "" {
+		hours, err = strconv.Atoi(t)
+		if err != nil || hours <= 0 {
+			log.Errorf("strconv.Atoi in servePublicPlantMetricsHandler %q - t: %s", err, t)
+			http.Error(w, "Invalid t parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	timeRange := time.Duration(hours) * time.Hour
+	if timeRange > publicMetricsMaxRange {
+		timeRange = publicMetricsMaxRange
+	}
+
+	public, err := isPublicPlant(r, id)
+	if err != nil {
+		log.Errorf("isPublicPlant in servePublicPlantMetricsHandler %q - id: %s", err, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	plant, err := db.GetPlant(id)
+	if err != nil || !public {
+		log.Errorf("db.GetPlant in servePublicPlantMetricsHandler %q - id: %s", err, id)
+		http.Error(w, "Unknown plant", http.StatusNotFound)
+		return
+	}
+	box, err := db.GetBox(plant.BoxID)
+	if err != nil {
+		log.Errorf("db.GetBox in servePublicPlantMetricsHandler %q - plant: %+v", err, plant)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !box.DeviceID.Valid || box.DeviceBox == nil {
+		errorMsg := "Plant has no controller"
+		log.Errorf("box.DeviceID.Valid in servePublicPlantMetricsHandler %q - box: %+v", errorMsg, box)
+		http.Error(w, errorMsg, http.StatusNotFound)
+		return
+	}
+	device, err := db.GetControllerOwnerDevice(box.DeviceID.UUID)
+	if err == udb.ErrNoMoreRows {
+		errorMsg := "Plant has no controller"
+		log.Errorf("db.GetControllerOwnerDevice in servePublicPlantMetricsHandler %q - box: %+v", errorMsg, box)
+		http.Error(w, errorMsg, http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Errorf("db.GetControllerOwnerDevice in servePublicPlantMetricsHandler %q - box: %+v", err, box)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	step := timeRange / publicMetricsMaxPoints
+	if step < publicMetricsMinStep {
+		step = publicMetricsMinStep
+	}
+	to := time.Now().Unix()
+	params := QueryMetricsParams{
+		From: to - int64(timeRange.Seconds()),
+		To:   to,
+		N:    int(timeRange / step),
+	}
+	for _, m := range metrics {
+		params.Series = append(params.Series, QuerySeries{
+			ID:           m,
+			ControllerID: device.Identifier,
+			Module:       "BOX",
+			Index:        int(*box.DeviceBox),
+			Metric:       m,
+			Aggregation:  AggregationAvg,
+			Window:       fmt.Sprintf("%ds", int64(step.Seconds())),
+		})
+	}
+
+	res, err := queryMetrics(params)
+	if err != nil {
+		log.Errorf("queryMetrics in servePublicPlantMetricsHandler %q - %+v", err, params)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("json.NewEncoder in servePublicPlantMetricsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,364 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
+	"github.com/SuperGreenLab/AppBackend/internal/server/tools"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxQuerySeries = 20
+	maxQueryPoints = 1000
+	maxQueryWindow = 30 * 24 * time.Hour
+
+	AggregationRaw       = "raw"
+	AggregationAvg       = "avg"
+	AggregationMin       = "min"
+	AggregationMax       = "max"
+	AggregationRate      = "rate"
+	AggregationDailyMean = "daily_mean"
+	AggregationDay       = "day"
+	AggregationNight     = "night"
+)
+
+var (
+	controllerIDRegexp = regexp.MustCompile("^[a-f0-9]+$")
+	moduleRegexp       = regexp.MustCompile("^[A-Z0-9]+$")
+	metricRegexp       = regexp.MustCompile("^[A-Z0-9_]+$")
+	windowRegexp       = regexp.MustCompile("^([0-9]+)([smhd])$")
+
+	windowUnits = map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+	}
+)
+
+// QuerySeries - one series of a metrics query, ie. BOX_0_TEMP on a controller,
+// Window is required by the avg, min, max and rate aggregations, and averages
+// the day and night series when set.
+type QuerySeries struct {
+	ID           string `json:"id"`
+	ControllerID string `json:"controllerID"`
+	Module       string `json:"module"`
+	Index        int    `json:"index"`
+	Metric       string `json:"metric"`
+	Aggregation  string `json:"aggregation"`
+	Window       string `json:"window"`
+}
+
+type QueryMetricsParams struct {
+	From   int64         `json:"from"`
+	To     int64         `json:"to"`
+	N      int           `json:"n"`
+	Series []QuerySeries `json:"series"`
+}
+
+type QueryMetricsSeriesResult struct {
+	ID     string     `json:"id"`
+	Values []*float64 `json:"values"`
+}
+
+// QueryMetricsResult - all series share the same timestamps, missing values are null
+type QueryMetricsResult struct {
+	Timestamps []int64                    `json:"timestamps"`
+	Series     []QueryMetricsSeriesResult `json:"series"`
+}
+
+func parseWindow(window string) (time.Duration, error) {
+	m := windowRegexp.FindStringSubmatch(window)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid window %q", window)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, err
+	}
+	d := time.Duration(n) * windowUnits[m[2]]
+	if d <= 0 || d > maxQueryWindow {
+		return 0, fmt.Errorf("Window %q should be between 1s and 30d", window)
+	}
+	return d, nil
+}
+
+func metricSelector(controllerID, module string, i int, metric string) string {
+	return fmt.Sprintf("g_%s_%d_%s{id=\"%s\"}", module, i, metric, controllerID)
+}
+
+// promQL - builds the query from validated parts only, nothing from the
+// request is pasted as is in the query
+func (s QuerySeries) promQL(step int64) (string, error) {
+	if !controllerIDRegexp.MatchString(s.ControllerID) {
+		return "", fmt.Errorf("Invalid controllerID %q", s.ControllerID)
+	}
+	if !moduleRegexp.MatchString(s.Module) {
+		return "", fmt.Errorf("Invalid module %q", s.Module)
+	}
+	if !metricRegexp.MatchString(s.Metric) {
+		return "", fmt.Errorf("Invalid metric %q", s.Metric)
+	}
+	if s.Index < 0 {
+		return "", fmt.Errorf("Invalid index %d", s.Index)
+	}
+	selector := metricSelector(s.ControllerID, s.Module, s.Index, s.Metric)
+
+	var window string
+	if s.Window != "" {
+		d, err := parseWindow(s.Window)
+		if err != nil {
+			return "", err
+		}
+		window = fmt.Sprintf("%ds", int64(d.Seconds()))
+	}
+
+	switch s.Aggregation {
+	case "", AggregationRaw:
+		return selector, nil
+	case AggregationAvg, AggregationMin, AggregationMax:
+		if window == "" {
+			return "", fmt.Errorf("Aggregation %s requires a window", s.Aggregation)
+		}
+		return fmt.Sprintf("%s_over_time(%s[%s])", s.Aggregation, selector, window), nil
+	case AggregationRate:
+		if window == "" {
+			return "", fmt.Errorf("Aggregation %s requires a window", s.Aggregation)
+		}
+		return fmt.Sprintf("rate(%s[%s])", selector, window), nil
+	case AggregationDailyMean:
+		return fmt.Sprintf("avg_over_time(%s[1d])", selector), nil
+	case AggregationDay, AggregationNight:
+		if s.Module != "BOX" {
+			return "", fmt.Errorf("Aggregation %s is only available for BOX metrics", s.Aggregation)
+		}
+		comparison := "> 0"
+		if s.Aggregation == AggregationNight {
+			comparison = "== 0"
+		}
+		timer := metricSelector(s.ControllerID, "BOX", s.Index, "TIMER_OUTPUT")
+		q := fmt.Sprintf("(%s and on(id) (%s %s))", selector, timer, comparison)
+		if window != "" {
+			q = fmt.Sprintf("avg_over_time(%s[%s:%ds])", q, window, step)
+		}
+		return q, nil
+	}
+	return "", fmt.Errorf("Unknown aggregation %q", s.Aggregation)
+}
+
+// load - plain and downsampled series go through the store's methods, other
+// aggregations need a store that evaluates expressions
+func (s QuerySeries) load(expr string, start, end, step int64) (appbackend.TimeSeries, error) {
+	series := timeseries.Series{ControllerID: s.ControllerID, Name: fmt.Sprintf("%s_%d_%s", s.Module, s.Index, s.Metric)}
+	switch s.Aggregation {
+	case "", AggregationRaw:
+		return timeseries.Store.QueryRange(series, start, end, step)
+	case AggregationAvg, AggregationMin, AggregationMax:
+		if d, err := parseWindow(s.Window); err == nil && int64(d.Seconds()) == step {
+			return timeseries.Store.Downsample(series, start, end, step, s.Aggregation)
+		}
+	}
+	q, err := s.archiveQuery(series, step)
+	if err != nil {
+		return nil, err
+	}
+	return timeseries.QueryExprRangeArchive(expr, start, end, step, q)
+}
+
+// archiveQuery - computes the expression from the hourly archive, for the part
+// of the range older than the time-series backend's retention
+func (s QuerySeries) archiveQuery(series timeseries.Series, step int64) (timeseries.ArchiveQuery, error) {
+	q := timeseries.ArchiveQuery{Series: series, Fn: timeseries.AggregationAvg, Window: step}
+	if s.Window != "" {
+		d, err := parseWindow(s.Window)
+		if err != nil {
+			return q, err
+		}
+		q.Window = int64(d.Seconds())
+	}
+	switch s.Aggregation {
+	case AggregationAvg, AggregationMin, AggregationMax:
+		q.Fn = s.Aggregation
+	case AggregationRate:
+		q.Fn = timeseries.AggregationRate
+	case AggregationDailyMean:
+		q.Window = int64((24 * time.Hour).Seconds())
+	case AggregationDay, AggregationNight:
+		q.Timer = &timeseries.Series{ControllerID: s.ControllerID, Name: fmt.Sprintf("BOX_%d_TIMER_OUTPUT", s.Index)}
+		q.Day = s.Aggregation == AggregationDay
+	}
+	return q, nil
+}
+
+func (p *QueryMetricsParams) validate() error {
+	now := time.Now()
+	if p.To == 0 {
+		p.To = now.Unix()
+	}
+	if p.From == 0 {
+		p.From = p.To - 60*60*72
+	}
+	if p.From >= p.To {
+		return errors.New("from should be before to")
+	}
+	if p.N <= 0 {
+		p.N = 200
+	}
+	if p.N > maxQueryPoints {
+		return fmt.Errorf("n should be at most %d", maxQueryPoints)
+	}
+	if len(p.Series) == 0 {
+		return errors.New("Missing series")
+	}
+	if len(p.Series) > maxQuerySeries {
+		return fmt.Errorf("At most %d series per query", maxQuerySeries)
+	}
+	return nil
+}
+
+func queryMetrics(params QueryMetricsParams) (QueryMetricsResult, error) {
+	step := (params.To - params.From) / int64(params.N)
+	if step < 1 {
+		step = 1
+	}
+	// aligns start on the step so all series, and successive queries, share their timestamps
+	start := params.From - params.From%step
+
+	queries := make([]string, len(params.Series))
+	for i, s := range params.Series {
+		q, err := s.promQL(step)
+		if err != nil {
+			return QueryMetricsResult{}, badRequestError{err}
+		}
+		queries[i] = q
+	}
+
+	res := QueryMetricsResult{Timestamps: []int64{}, Series: make([]QueryMetricsSeriesResult, len(queries))}
+	for t := start; t <= params.To; t += step {
+		res.Timestamps = append(res.Timestamps, t)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(queries))
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			ts, err := params.Series[i].load(q, start, params.To, step)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			values := map[int64]float64{}
+			for _, v := range ts {
+				if !math.IsNaN(v[1]) {
+					values[int64(v[0])] = v[1]
+				}
+			}
+			series := QueryMetricsSeriesResult{ID: params.Series[i].ID, Values: make([]*float64, len(res.Timestamps))}
+			if series.ID == "" {
+				series.ID = strconv.Itoa(i)
+			}
+			for j, t := range res.Timestamps {
+				if v, ok := values[t]; ok {
+					series.Values[j] = &v
+				}
+			}
+			res.Series[i] = series
+		}(i, q)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return res, fmt.Errorf("%s: %s", queries[i], err)
+		}
+	}
+	return res, nil
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string {
+	return e.err.Error()
+}
+
+// queryMetricsHandler - queries several series in one call, with whitelisted aggregations
+func queryMetricsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	params := QueryMetricsParams{}
+	if err := tools.DecodeJSONBody(w, r, &params); err != nil {
+		log.Errorf("tools.DecodeJSONBody in queryMetricsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := params.validate(); err != nil {
+		log.Errorf("params.validate in queryMetricsHandler %q - %+v", err, params)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	checked := map[string]bool{}
+	for _, s := range params.Series {
+		if checked[s.ControllerID] {
+			continue
+		}
+		if !controllerIDRegexp.MatchString(s.ControllerID) {
+			log.Errorf("controllerIDRegexp.MatchString in queryMetricsHandler %q - %+v", "Invalid controllerID", s)
+			http.Error(w, "Invalid controllerID", http.StatusBadRequest)
+			return
+		}
+		if !checkControllerAccess(w, r, s.ControllerID, "queryMetricsHandler") {
+			return
+		}
+		checked[s.ControllerID] = true
+	}
+
+	res, err := queryMetrics(params)
+	if err != nil {
+		log.Errorf("queryMetrics in queryMetricsHandler %q - %+v", err, params)
+		status := http.StatusInternalServerError
+		if _, ok := err.(badRequestError); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("json.NewEncoder in queryMetricsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
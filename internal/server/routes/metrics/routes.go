@@ -1,19 +1,22 @@
 package metrics
 
 import (
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/server/routes/feeds/explorer"
 	"github.com/julienschmidt/httprouter"
-	"github.com/rileyr/middleware"
-	"github.com/rileyr/middleware/wares"
-	"github.com/spf13/viper"
 )
 
 // Init -
 func Init(router *httprouter.Router) {
-	s := middleware.NewStack()
+	anon := middlewares.AnonStack()
+	auth := middlewares.AuthStack()
+	optionalAuth := middlewares.OptionalAuthStack()
 
-	if viper.GetString("LogRequests") == "true" {
-		s.Use(wares.Logging)
-	}
+	router.GET("/metrics", auth.Wrap(ServeMetricsHandler))
+	router.POST("/metrics/query", auth.Wrap(queryMetricsHandler))
+	router.GET("/plant/:id/metrics/export", auth.Wrap(exportPlantMetricsHandler))
+	router.GET("/plant/:id/stats", auth.Wrap(selectPlantStatsHandler))
+	router.GET("/plants/compare", optionalAuth.Wrap(publicPlantSelector(explorer.PublicPlantsOnly(comparePlantsHandler))))
 
-	router.GET("/metrics", s.Wrap(ServeMetricsHandler))
+	router.GET("/public/plant/:id/metrics", anon.Wrap(publicPlantSelector(explorer.PublicPlantsOnly(servePublicPlantMetricsHandler))))
 }
@@ -0,0 +1,442 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	// statsStep - stats are computed from hourly averages, which the archive
+	// also has for ranges past the time-series retention
+	statsStep = 3600
+
+	feedEntryWater       = "FE_WATER"
+	feedEntryNutrientMix = "FE_NUTRIENT_MIX"
+	feedEntryLifeEvent   = "FE_LIFE_EVENT"
+)
+
+var (
+	_ = pflag.Float64("dlimaxppfd", 1000, "PPFD (µmol/m²/s) of the LEDs at full power, used to estimate the DLI")
+
+	// keyEventTypes - feed entries shown on the stats timeline
+	keyEventTypes = []string{"FE_TOPPING", "FE_FIMMING", "FE_BENDING", "FE_DEFOLATION", "FE_TRANSPLANT", feedEntryLifeEvent}
+)
+
+func init() {
+	viper.SetDefault("DLIMaxPPFD", 1000)
+}
+
+// mean - running average, Value is nil without samples
+type mean struct {
+	sum float64
+	n   int
+}
+
+func (m *mean) add(v *float64) {
+	if v == nil {
+		return
+	}
+	m.sum += *v
+	m.n++
+}
+
+func (m mean) Value() *float64 {
+	if m.n == 0 {
+		return nil
+	}
+	v := m.sum / float64(m.n)
+	return &v
+}
+
+// plantHour - hourly averages of the plant's box, Dim is the mean of its LED channels
+type plantHour struct {
+	Time  int64
+	Temp  *float64
+	Humi  *float64
+	Timer *float64
+	Dim   *float64
+}
+
+func (h plantHour) isDay() bool {
+	return h.Timer != nil && *h.Timer > 0
+}
+
+// lightMol - estimated light received during the hour, in mol/m², from the
+// timer and dimming, LEDs without a dimming value count as full power
+func (h plantHour) lightMol(maxPPFD float64) (float64, bool) {
+	if h.Timer == nil {
+		return 0, false
+	}
+	dim := 100.0
+	if h.Dim != nil {
+		dim = *h.Dim
+	}
+	return maxPPFD * *h.Timer / 100 * dim / 100 * statsStep / 1e6, true
+}
+
+// loadPlantHours - hourly averages of the plant's box metrics between from and
+// to, none when the plant has no controller
+func loadPlantHours(s plantSource, from, to time.Time) ([]plantHour, error) {
+	hours := []plantHour{}
+	if !s.HasController {
+		return hours, nil
+	}
+	columns := []boxColumn{}
+	for _, c := range s.columns() {
+		if c.Column == "temperature" || c.Column == "humidity" || c.Column == "timer" || strings.HasPrefix(c.Column, "led_") {
+			columns = append(columns, c)
+		}
+	}
+
+	start := from.Unix() - from.Unix()%statsStep
+	end := to.Unix()
+	chunk := int64(statsStep * exportChunkRows)
+	for cs := start; cs <= end; cs += chunk {
+		ce := cs + chunk - statsStep
+		if ce > end {
+			ce = end
+		}
+		values, err := loadExportChunk(s, columns, cs, ce, statsStep)
+		if err != nil {
+			return hours, err
+		}
+		for t := cs; t <= ce; t += statsStep {
+			h := plantHour{Time: t}
+			dim := mean{}
+			for i, c := range columns {
+				v, ok := values[i][t]
+				if !ok {
+					continue
+				}
+				switch c.Column {
+				case "temperature":
+					h.Temp = &v
+				case "humidity":
+					h.Humi = &v
+				case "timer":
+					h.Timer = &v
+				default:
+					dim.add(&v)
+				}
+			}
+			h.Dim = dim.Value()
+			hours = append(hours, h)
+		}
+	}
+	return hours, nil
+}
+
+// StageStats - averages over the stage, DLI is estimated from the timer and
+// dimming with --dlimaxppfd
+type StageStats struct {
+	Stage string    `json:"stage"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Days  float64   `json:"days"`
+
+	DayTemp   *float64 `json:"dayTemp"`
+	NightTemp *float64 `json:"nightTemp"`
+	DayHumi   *float64 `json:"dayHumi"`
+	NightHumi *float64 `json:"nightHumi"`
+	DLI       *float64 `json:"dli"`
+
+	Waterings       int   `json:"waterings"`
+	NutrientEntries int   `json:"nutrientEntries"`
+	AlertSeconds    int64 `json:"alertSeconds"`
+}
+
+// PlantEvent - a key event of the grow, Day counts from the plant's lifetime start
+type PlantEvent struct {
+	Date        time.Time     `json:"date"`
+	Day         int           `json:"day"`
+	Type        string        `json:"type"`
+	FeedEntryID uuid.NullUUID `json:"feedEntryID"`
+	Params      string        `json:"params,omitempty"`
+}
+
+type PlantStats struct {
+	PlantID uuid.UUID `json:"plantID"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Days    float64   `json:"days"`
+
+	Stages []StageStats `json:"stages"`
+
+	Waterings       int              `json:"waterings"`
+	NutrientEntries int              `json:"nutrientEntries"`
+	AlertIncidents  int              `json:"alertIncidents"`
+	AlertSeconds    int64            `json:"alertSeconds"`
+	AlertsByMetric  map[string]int64 `json:"alertsByMetric"`
+
+	Events []PlantEvent `json:"events"`
+}
+
+// trimmed - stats shown to callers who don't own the plant, without the
+// events' params nor the alerted metrics
+func (s PlantStats) trimmed() PlantStats {
+	s.AlertsByMetric = nil
+	events := make([]PlantEvent, len(s.Events))
+	for i, e := range s.Events {
+		e.Params = ""
+		events[i] = e
+	}
+	s.Events = events
+	return s
+}
+
+// plantStagePeriods - the stages the plant went through during its lifetime,
+// the time before the first stage date counts as veg, like the alerts do
+func plantStagePeriods(plant appbackend.Plant, from, to time.Time) []StageStats {
+	stages := []StageStats{}
+	for _, ps := range appbackend.PlantStages {
+		date, ok := plant.SettingDate(ps.Setting)
+		if !ok {
+			continue
+		}
+		if date.Before(from) {
+			date = from
+		}
+		if !date.Before(to) {
+			continue
+		}
+		if n := len(stages); n != 0 && !date.After(stages[n-1].From) {
+			stages[n-1].Stage = ps.Name
+			continue
+		}
+		stages = append(stages, StageStats{Stage: ps.Name, From: date})
+	}
+	if len(stages) == 0 || stages[0].From.After(from) {
+		stages = append([]StageStats{{Stage: appbackend.PlantStageDefault, From: from}}, stages...)
+	}
+	for i := range stages {
+		stages[i].To = to
+		if i+1 < len(stages) {
+			stages[i].To = stages[i+1].From
+		}
+		stages[i].Days = stages[i].To.Sub(stages[i].From).Hours() / 24
+	}
+	return stages
+}
+
+func stageAt(stages []StageStats, t time.Time) int {
+	for i, s := range stages {
+		if !t.Before(s.From) && t.Before(s.To) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isNutrientEntry - nutrient mixes, and waterings done with nutrients
+func isNutrientEntry(fe appbackend.FeedEntry) bool {
+	if fe.Type == feedEntryNutrientMix {
+		return true
+	}
+	params := struct {
+		Nutrient bool `json:"nutrient"`
+	}{}
+	if err := json.Unmarshal([]byte(fe.Params), &params); err != nil {
+		return false
+	}
+	return params.Nutrient
+}
+
+// interval - a period an alert incident was open
+type interval struct {
+	start, end time.Time
+}
+
+// mergedSeconds - seconds between from and to covered by at least one of the
+// intervals, overlapping incidents only count once
+func mergedSeconds(intervals []interval, from, to time.Time) int64 {
+	clipped := []interval{}
+	for _, in := range intervals {
+		if in.start.Before(from) {
+			in.start = from
+		}
+		if in.end.After(to) {
+			in.end = to
+		}
+		if in.end.After(in.start) {
+			clipped = append(clipped, in)
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].start.Before(clipped[j].start) })
+
+	var seconds int64
+	for i := 0; i < len(clipped); {
+		start, end := clipped[i].start, clipped[i].end
+		for i++; i < len(clipped) && !clipped[i].start.After(end); i++ {
+			if clipped[i].end.After(end) {
+				end = clipped[i].end
+			}
+		}
+		seconds += int64(end.Sub(start).Seconds())
+	}
+	return seconds
+}
+
+// computePlantStats - aggregates the plant's box metrics, feed entries and
+// alert incidents between from and to, its lifetime
+func computePlantStats(s plantSource, hours []plantHour, from, to time.Time) (PlantStats, error) {
+	stats := PlantStats{
+		PlantID:        s.Plant.ID.UUID,
+		From:           from,
+		To:             to,
+		Days:           to.Sub(from).Hours() / 24,
+		Stages:         plantStagePeriods(s.Plant, from, to),
+		AlertsByMetric: map[string]int64{},
+		Events:         []PlantEvent{},
+	}
+
+	maxPPFD := viper.GetFloat64("DLIMaxPPFD")
+	type stageMeans struct {
+		dayTemp, nightTemp, dayHumi, nightHumi mean
+		mol                                    float64
+		lightHours                             int
+	}
+	means := make([]stageMeans, len(stats.Stages))
+	for _, h := range hours {
+		i := stageAt(stats.Stages, time.Unix(h.Time, 0))
+		if i < 0 {
+			continue
+		}
+		if h.isDay() {
+			means[i].dayTemp.add(h.Temp)
+			means[i].dayHumi.add(h.Humi)
+		} else if h.Timer != nil {
+			means[i].nightTemp.add(h.Temp)
+			means[i].nightHumi.add(h.Humi)
+		}
+		if mol, ok := h.lightMol(maxPPFD); ok {
+			means[i].mol += mol
+			means[i].lightHours++
+		}
+	}
+	for i, m := range means {
+		stats.Stages[i].DayTemp = m.dayTemp.Value()
+		stats.Stages[i].NightTemp = m.nightTemp.Value()
+		stats.Stages[i].DayHumi = m.dayHumi.Value()
+		stats.Stages[i].NightHumi = m.nightHumi.Value()
+		if m.lightHours != 0 {
+			dli := m.mol / (float64(m.lightHours) / 24)
+			stats.Stages[i].DLI = &dli
+		}
+	}
+
+	feedEntries, err := db.GetFeedEntriesOfTypes(s.Plant.FeedID, append([]string{feedEntryWater, feedEntryNutrientMix}, keyEventTypes...))
+	if err != nil {
+		return stats, err
+	}
+	for _, fe := range feedEntries {
+		i := stageAt(stats.Stages, fe.Date)
+		switch fe.Type {
+		case feedEntryWater, feedEntryNutrientMix:
+			water, nutrient := fe.Type == feedEntryWater, isNutrientEntry(fe)
+			if water {
+				stats.Waterings++
+			}
+			if nutrient {
+				stats.NutrientEntries++
+			}
+			if i >= 0 && water {
+				stats.Stages[i].Waterings++
+			}
+			if i >= 0 && nutrient {
+				stats.Stages[i].NutrientEntries++
+			}
+		default:
+			stats.Events = append(stats.Events, PlantEvent{
+				Date:        fe.Date,
+				Day:         int(fe.Date.Sub(from).Hours() / 24),
+				Type:        fe.Type,
+				FeedEntryID: fe.ID,
+				Params:      fe.Params,
+			})
+		}
+	}
+
+	incidents, err := db.GetPlantAlertIncidentsBetween(s.Plant.ID.UUID, from, to)
+	if err != nil {
+		return stats, err
+	}
+	stats.AlertIncidents = len(incidents)
+	all := []interval{}
+	byMetric := map[string][]interval{}
+	for _, incident := range incidents {
+		in := interval{start: incident.StartedAt, end: to}
+		if incident.EndedAt.Valid {
+			in.end = incident.EndedAt.Time
+		}
+		all = append(all, in)
+		byMetric[incident.Metric] = append(byMetric[incident.Metric], in)
+	}
+	stats.AlertSeconds = mergedSeconds(all, from, to)
+	for i, stage := range stats.Stages {
+		stats.Stages[i].AlertSeconds = mergedSeconds(all, stage.From, stage.To)
+	}
+	for metric, intervals := range byMetric {
+		if seconds := mergedSeconds(intervals, from, to); seconds > 0 {
+			stats.AlertsByMetric[metric] = seconds
+		}
+	}
+	return stats, nil
+}
+
+// selectPlantStatsHandler - grow analytics of the plant over its lifetime
+func selectPlantStatsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	s, ok := loadPlantSource(w, r, p, false, "selectPlantStatsHandler")
+	if !ok {
+		return
+	}
+
+	from, to := s.Plant.Lifetime(time.Now())
+	hours, err := loadPlantHours(s, from, to)
+	if err != nil {
+		log.Errorf("loadPlantHours in selectPlantStatsHandler %q - plant: %+v", err, s.Plant)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats, err := computePlantStats(s, hours, from, to)
+	if err != nil {
+		log.Errorf("computePlantStats in selectPlantStatsHandler %q - plant: %+v", err, s.Plant)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Errorf("json.NewEncoder in selectPlantStatsHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
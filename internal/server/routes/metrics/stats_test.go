@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+func TestMergedSeconds(t *testing.T) {
+	base := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	tests := []struct {
+		name      string
+		intervals []interval
+		from, to  int
+		want      int64
+	}{
+		{"none", nil, 0, 60, 0},
+		{"single", []interval{{at(10), at(20)}}, 0, 60, 600},
+		{"overlapping", []interval{{at(10), at(30)}, {at(20), at(40)}}, 0, 60, 1800},
+		{"nested", []interval{{at(10), at(40)}, {at(20), at(30)}}, 0, 60, 1800},
+		{"touching", []interval{{at(10), at(20)}, {at(20), at(30)}}, 0, 60, 1200},
+		{"disjoint unsorted", []interval{{at(40), at(50)}, {at(10), at(20)}}, 0, 60, 1200},
+		{"clipped to the range", []interval{{at(-10), at(10)}, {at(50), at(70)}}, 0, 60, 1200},
+		{"spanning the range", []interval{{at(-10), at(70)}}, 20, 40, 1200},
+		{"outside the range", []interval{{at(-20), at(-10)}, {at(70), at(80)}}, 0, 60, 0},
+		{"empty interval", []interval{{at(30), at(30)}}, 0, 60, 0},
+	}
+	for _, tt := range tests {
+		if got := mergedSeconds(tt.intervals, at(tt.from), at(tt.to)); got != tt.want {
+			t.Errorf("%s: mergedSeconds() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlantStagePeriods(t *testing.T) {
+	from := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(100 * 24 * time.Hour)
+	day := func(d int) string { return from.Add(time.Duration(d) * 24 * time.Hour).Format(time.RFC3339) }
+	tests := []struct {
+		name     string
+		settings string
+		want     []string
+		days     []float64
+	}{
+		{"no stage date", `{}`, []string{"veg"}, []float64{100}},
+		{"germination", `{"germinationDate":"` + day(0) + `"}`, []string{"germination"}, []float64{100}},
+		{"veg then bloom", `{"veggingStart":"` + day(10) + `","bloomingStart":"` + day(40) + `"}`, []string{"veg", "veg", "bloom"}, []float64{10, 30, 60}},
+		{"same day stages", `{"germinationDate":"` + day(0) + `","veggingStart":"` + day(0) + `"}`, []string{"veg"}, []float64{100}},
+		{"before the lifetime", `{"veggingStart":"` + day(-5) + `","bloomingStart":"` + day(20) + `"}`, []string{"veg", "bloom"}, []float64{20, 80}},
+		{"after the lifetime", `{"bloomingStart":"` + day(120) + `"}`, []string{"veg"}, []float64{100}},
+	}
+	for _, tt := range tests {
+		stages := plantStagePeriods(appbackend.Plant{Settings: tt.settings}, from, to)
+		if len(stages) != len(tt.want) {
+			t.Errorf("%s: plantStagePeriods() = %+v, want %v", tt.name, stages, tt.want)
+			continue
+		}
+		for i, s := range stages {
+			if s.Stage != tt.want[i] || s.Days != tt.days[i] {
+				t.Errorf("%s: stage %d = %s %.1f days, want %s %.1f days", tt.name, i, s.Stage, s.Days, tt.want[i], tt.days[i])
+			}
+		}
+	}
+}
+
+func TestPlantStatsTrimmed(t *testing.T) {
+	stats := PlantStats{
+		AlertsByMetric: map[string]int64{"TEMP": 600},
+		Events:         []PlantEvent{{Type: "FE_TOPPING", Params: `{"message":"private"}`}},
+	}
+	trimmed := stats.trimmed()
+	if trimmed.AlertsByMetric != nil {
+		t.Errorf("trimmed().AlertsByMetric = %v, want nil", trimmed.AlertsByMetric)
+	}
+	if len(trimmed.Events) != 1 || trimmed.Events[0].Type != "FE_TOPPING" || trimmed.Events[0].Params != "" {
+		t.Errorf("trimmed().Events = %+v, want the events without params", trimmed.Events)
+	}
+	if stats.Events[0].Params == "" {
+		t.Errorf("trimmed() changed the original events")
+	}
+}
@@ -19,7 +19,7 @@
 package metrics
 
 import (
-	"github.com/SuperGreenLab/AppBackend/internal/data/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
 )
 
@@ -27,6 +27,7 @@ type servedResult struct {
 	Metrics appbackend.TimeSeries `json:"metrics"`
 }
 
-func newServedResult(rr prometheus.RangeResult, min, max float64) servedResult {
-	return servedResult{Metrics: rr.ToFloat64(min, max)}
+// newServedResult - NaN values and values outside of [min, max] are replaced by the previous value
+func newServedResult(ts appbackend.TimeSeries, min, max float64) servedResult {
+	return servedResult{Metrics: timeseries.CarryForward(ts, min, max)}
 }
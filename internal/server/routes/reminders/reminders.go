@@ -0,0 +1,248 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/server/tools"
+	"github.com/SuperGreenLab/AppBackend/internal/services/reminders"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rileyr/middleware"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func setReminderNextAt(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		reminder := r.Context().Value(middlewares.ObjectContextKey{}).(*db.Reminder)
+
+		if err := reminders.ValidateReminder(*reminder); err != nil {
+			logrus.Errorf("reminders.ValidateReminder in setReminderNextAt %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plant, err := db.GetPlant(reminder.PlantID)
+		if err != nil {
+			logrus.Errorf("db.GetPlant in setReminderNextAt %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		reminder.NextAt, err = reminders.NextReminderDate(*reminder, plant, now)
+		if err != nil {
+			logrus.Errorf("reminders.NextReminderDate in setReminderNextAt %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// relative reminders whose date already passed are never sent
+		if reminder.Type == db.ReminderTypeRelative && reminder.NextAt.Valid && reminder.NextAt.Time.Before(now) {
+			reminder.NextAt = null.Time{}
+		}
+
+		ctx := context.WithValue(r.Context(), middlewares.ObjectContextKey{}, reminder)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var createReminderHandler = middlewares.InsertEndpoint(
+	"reminders",
+	func() interface{} { return &db.Reminder{} },
+	[]middleware.Middleware{
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("plants", "PlantID", false, func() appbackend.UserObject { return &appbackend.Plant{} }),
+		setReminderNextAt,
+	},
+	[]middleware.Middleware{},
+)
+
+// keepReminderState - the send and completion dates are the server's, a
+// client editing a relative reminder that already fired would re-arm it
+func keepReminderState(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		reminder := r.Context().Value(middlewares.ObjectContextKey{}).(*db.Reminder)
+
+		stored, err := db.GetReminder(reminder.ID.UUID)
+		if err != nil {
+			logrus.Errorf("db.GetReminder in keepReminderState %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reminder.LastSentAt = stored.LastSentAt
+		reminder.CompletedAt = stored.CompletedAt
+		reminder.CreatedAt = stored.CreatedAt
+
+		ctx := context.WithValue(r.Context(), middlewares.ObjectContextKey{}, reminder)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var updateReminderHandler = middlewares.UpdateEndpoint(
+	"reminders",
+	func() interface{} { return &db.Reminder{} },
+	[]middleware.Middleware{
+		middlewares.ObjectIDRequired,
+		middlewares.SetUserID,
+		middlewares.CheckAccessRight("reminders", "ID", false, func() appbackend.UserObject { return &db.Reminder{} }),
+		middlewares.CheckAccessRight("plants", "PlantID", false, func() appbackend.UserObject { return &appbackend.Plant{} }),
+		keepReminderState,
+		setReminderNextAt,
+	},
+	[]middleware.Middleware{},
+)
+
+type SelectPlantRemindersParams struct {
+	middlewares.SelectParamsOffsetLimit
+}
+
+func filterPlantReminders(fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		selector := r.Context().Value(middlewares.SelectorContextKey{}).(sqlbuilder.Selector)
+		uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+		selector = selector.Where("t.userid = ?", uid).And("t.plantid = ?", p.ByName("id")).And("t.deleted = false")
+		ctx := context.WithValue(r.Context(), middlewares.SelectorContextKey{}, selector)
+		fn(w, r.WithContext(ctx), p)
+	}
+}
+
+var selectPlantReminders = middlewares.SelectEndpoint(
+	"reminders",
+	func() interface{} { return &[]db.Reminder{} },
+	func() interface{} { return &SelectPlantRemindersParams{} },
+	[]middleware.Middleware{
+		filterPlantReminders,
+	},
+	[]middleware.Middleware{},
+)
+
+func loadUserReminder(w http.ResponseWriter, r *http.Request, p httprouter.Params, caller string) (db.Reminder, bool) {
+	uid := r.Context().Value(middlewares.UserIDContextKey{}).(uuid.UUID)
+
+	id, err := uuid.FromString(p.ByName("id"))
+	if err != nil {
+		logrus.Errorf("uuid.FromString in %s %q - uid: %s", caller, err, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return db.Reminder{}, false
+	}
+	reminder, err := db.GetReminder(id)
+	if err != nil {
+		logrus.Errorf("db.GetReminder in %s %q - id: %s uid: %s", caller, err, id, uid)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return reminder, false
+	}
+	if reminder.UserID != uid {
+		errorMsg := "Reminder is owned by another user"
+		logrus.Errorf("reminder.UserID != uid in %s %q - uid: %s reminder: %+v", caller, errorMsg, uid, reminder)
+		http.Error(w, errorMsg, http.StatusUnauthorized)
+		return reminder, false
+	}
+	return reminder, true
+}
+
+type snoozeReminderRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+func snoozeReminderHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	srr := snoozeReminderRequest{}
+	if err := tools.DecodeJSONBody(w, r, &srr); err != nil {
+		logrus.Errorf("tools.DecodeJSONBody in snoozeReminderHandler %q", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if srr.Minutes <= 0 {
+		http.Error(w, "Invalid snooze duration", http.StatusBadRequest)
+		return
+	}
+
+	reminder, ok := loadUserReminder(w, r, p, "snoozeReminderHandler")
+	if !ok {
+		return
+	}
+	if reminder.Done || reminder.Deleted {
+		errorMsg := "Reminder is done"
+		logrus.Errorf("reminder.Done in snoozeReminderHandler %q - %+v", errorMsg, reminder)
+		http.Error(w, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	reminder.NextAt = null.TimeFrom(time.Now().Add(time.Duration(srr.Minutes) * time.Minute))
+	if err := db.SetReminderNextAt(reminder.ID.UUID, reminder.NextAt); err != nil {
+		logrus.Errorf("db.SetReminderNextAt in snoozeReminderHandler %q - %+v", err, reminder)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(reminder); err != nil {
+		logrus.Errorf("json.NewEncoder in snoozeReminderHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func completeReminderHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	reminder, ok := loadUserReminder(w, r, p, "completeReminderHandler")
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	reminder.CompletedAt = null.TimeFrom(now)
+	if reminder.Type == db.ReminderTypeRelative {
+		reminder.Done = true
+		reminder.NextAt = null.Time{}
+	} else if reminder.Type == db.ReminderTypeInterval {
+		// interval reminders restart from the completion date
+		reminder.NextAt = null.Time{}
+		plant, err := db.GetPlant(reminder.PlantID)
+		if err != nil {
+			logrus.Errorf("db.GetPlant in completeReminderHandler %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reminder.NextAt, err = reminders.NextReminderDate(reminder, plant, now)
+		if err != nil {
+			logrus.Errorf("reminders.NextReminderDate in completeReminderHandler %q - %+v", err, reminder)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := db.CompleteReminder(reminder.ID.UUID, reminder.NextAt, reminder.Done, now); err != nil {
+		logrus.Errorf("db.CompleteReminder in completeReminderHandler %q - %+v", err, reminder)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(reminder); err != nil {
+		logrus.Errorf("json.NewEncoder in completeReminderHandler %q", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
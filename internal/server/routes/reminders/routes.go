@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package reminders
+
+import (
+	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Init -
+func Init(router *httprouter.Router) {
+	auth := middlewares.AuthStack()
+
+	router.POST("/reminder", auth.Wrap(createReminderHandler))
+	router.PUT("/reminder", auth.Wrap(updateReminderHandler))
+	router.POST("/reminder/:id/snooze", auth.Wrap(snoozeReminderHandler))
+	router.POST("/reminder/:id/complete", auth.Wrap(completeReminderHandler))
+
+	router.GET("/plant/:id/reminders", auth.Wrap(selectPlantReminders))
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/mail"
 	"strings"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/julienschmidt/httprouter"
@@ -42,6 +44,12 @@ import (
 	"upper.io/db.v3/lib/sqlbuilder"
 )
 
+var digestTypes = map[string]bool{
+	"none":   true,
+	"daily":  true,
+	"weekly": true,
+}
+
 type loginParams struct {
 	Handle   string `json:"handle"`
 	Password string `json:"password"`
@@ -157,6 +165,42 @@ var updateUserHandler = middlewares.UpdateEndpoint(
 					return
 				}
 				user.Pic = u.Pic
+				if u.Email.Valid && u.Email.String != "" {
+					if _, err := mail.ParseAddress(u.Email.String); err != nil {
+						errorMsg := "Invalid email"
+						logrus.Errorf("mail.ParseAddress in updateUserHandler %q - %+v", err, u.Email.String)
+						http.Error(w, errorMsg, http.StatusBadRequest)
+						return
+					}
+					user.Email = u.Email
+				}
+				if u.Digest != "" {
+					if _, ok := digestTypes[u.Digest]; !ok {
+						errorMsg := "Unknown digest type"
+						logrus.Errorf("%q - %+v", errorMsg, u.Digest)
+						http.Error(w, errorMsg, http.StatusBadRequest)
+						return
+					}
+					user.Digest = u.Digest
+				}
+				if u.Locale != "" {
+					if !notifications.IsKnownLocale(u.Locale) {
+						errorMsg := "Unknown locale"
+						logrus.Errorf("%q - %+v", errorMsg, u.Locale)
+						http.Error(w, errorMsg, http.StatusBadRequest)
+						return
+					}
+					user.Locale = notifications.NormalizeLocale(u.Locale)
+				}
+				if u.Units != "" {
+					if !notifications.IsKnownUnits(u.Units) {
+						errorMsg := "Unknown units"
+						logrus.Errorf("%q - %+v", errorMsg, u.Units)
+						http.Error(w, errorMsg, http.StatusBadRequest)
+						return
+					}
+					user.Units = u.Units
+				}
 
 				ctx := context.WithValue(r.Context(), middlewares.ObjectContextKey{}, user)
 				fn(w, r.WithContext(ctx), p)
@@ -29,8 +29,11 @@ import (
 
 	"github.com/SuperGreenLab/AppBackend/internal/data/storage"
 
+	"github.com/SuperGreenLab/AppBackend/internal/server/routes/alerts"
 	"github.com/SuperGreenLab/AppBackend/internal/server/routes/feeds"
+	"github.com/SuperGreenLab/AppBackend/internal/server/routes/harvests"
 	"github.com/SuperGreenLab/AppBackend/internal/server/routes/metrics"
+	"github.com/SuperGreenLab/AppBackend/internal/server/routes/reminders"
 	"github.com/SuperGreenLab/AppBackend/internal/server/routes/users"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
@@ -56,6 +59,9 @@ func Start() {
 	metrics.Init(router)
 	feeds.Init(router)
 	products.Init(router)
+	reminders.Init(router)
+	alerts.Init(router)
+	harvests.Init(router)
 
 	go func() {
 		if viper.GetString("AddCORS") == "true" {
@@ -20,16 +20,17 @@ package alerts
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
-	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
 	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
 	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
 	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
-	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -43,55 +44,224 @@ var (
 	alertTypeTooLow  = "TOO_LOW"
 )
 
-type getMinMaxFunc func(controllerID string, boxID int, timerPower float64) (float64, float64, error)
+// alertContentKey - catalog key for the builtin alerts, ie. alert_temperature_too_high_day
+func alertContentKey(name, alertType string, timerPower float64) string {
+	period := "day"
+	if timerPower == 0 {
+		period = "night"
+	}
+	return fmt.Sprintf("alert_%s_%s_%s", name, strings.ToLower(alertType), period)
+}
+
+// getMinMaxFunc - t are the box's thresholds, only used by the builtin rules
+type getMinMaxFunc func(t Thresholds, timerPower float64) (float64, float64)
+
+type getAlertContentFunc func(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (*notifications.LocalizedText, *notifications.LocalizedText)
+
+// alertRule - evaluated for each metric published on Pattern
+type alertRule struct {
+	// Key - suffix of the redis keys storing the alert state, ie. TEMP for <cid>.ALERT.BOX_<n>_TEMP
+	Key string
+	// MetricName - label used in logs and prometheus
+	MetricName string
+	// Pattern - metric key pattern, ie. BOX_*_TEMP
+	Pattern    string
+	Comparison string
+
+	// Hysteresis - the alert ends once the value is back Hysteresis inside the bounds
+	Hysteresis float64
+	// HysteresisFactor - multiplicative hysteresis of the builtin rules, the alert
+	// ends above min*HysteresisFactor or below max/HysteresisFactor
+	HysteresisFactor float64
+	MinDuration      time.Duration
+	Cooldown         time.Duration
+	// Renotify - the plants are notified again every Renotify while the alert is open
+	Renotify time.Duration
+
+	GetMinMax       getMinMaxFunc
+	GetAlertContent getAlertContentFunc
+	// SensorRequired - the box's temperature/humidity sensor must be present
+	SensorRequired bool
+
+	// BoxID - only plants from this box are notified, any plant of the box slot if invalid
+	BoxID uuid.NullUUID
+	// DeviceBox - box slot used for metrics that are not prefixed by BOX_<n>_
+	DeviceBox int
+}
+
+func (ar alertRule) boxNum(metric pubsub.ControllerIntMetric) (int, error) {
+	if strings.HasPrefix(metric.Key, "BOX_") {
+		return boxIDNumFromMetric(metric.Key)
+	}
+	if ar.BoxID.Valid {
+		return ar.DeviceBox, nil
+	}
+	return 0, errors.New("Metric is not bound to a box")
+}
+
+func (ar alertRule) outOfBounds(value, minValue, maxValue float64) (bool, bool) {
+	tooLow := ar.Comparison != db.AlertComparisonAbove && value < minValue
+	tooHigh := ar.Comparison != db.AlertComparisonBelow && value > maxValue
+	return tooLow, tooHigh
+}
+
+// endBounds - bounds the value must be back within to end the alert
+func (ar alertRule) endBounds(minValue, maxValue float64) (float64, float64) {
+	if ar.HysteresisFactor > 0 {
+		return minValue * ar.HysteresisFactor, maxValue / ar.HysteresisFactor
+	}
+	return minValue + ar.Hysteresis, maxValue - ar.Hysteresis
+}
 
-type getAlertContentFunc func(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (string, string)
+// lastNotificationTTL - the last notification is kept for the cooldown and the renotify interval
+func (ar alertRule) lastNotificationTTL() time.Duration {
+	if ar.Cooldown > ar.Renotify {
+		return ar.Cooldown
+	}
+	return ar.Renotify
+}
 
-func checkMetric(metricName string, getAlertContent getAlertContentFunc, metric pubsub.ControllerIntMetric, getMinMax getMinMaxFunc, getSensorPresentForBox kv.GetSensorPresentForBoxFunc, getAlertStatus kv.GetAlertStatusFunc, setAlertStatus kv.SetAlertStatusFunc, getAlertType kv.GetAlertTypeFunc, setAlertType kv.SetAlertTypeFunc) {
-	boxID, err := boxIDNumFromMetric(metric.Key)
+// rulePlants - active plants of the box slot, restricted to the rule's box
+func (e Evaluator) rulePlants(ar alertRule, controllerID string, boxID int) ([]appbackend.Plant, error) {
+	plants, err := e.Plants.GetActivePlants(controllerID, boxID)
 	if err != nil {
-		logrus.Errorf("boxIDNumFromMetric in checkMetric %q - %+v", err, metric)
-		return
+		return nil, err
 	}
-	if enabled, err := kv.GetBoxEnabled(metric.ControllerID, boxID); !enabled || err != nil {
-		if err != nil {
-			logrus.Errorf("kv.GetBoxEnabled in checkMetric %q - %d", err, boxID)
+	if !ar.BoxID.Valid {
+		return plants, nil
+	}
+	boxPlants := []appbackend.Plant{}
+	for _, plant := range plants {
+		if plant.BoxID == ar.BoxID.UUID {
+			boxPlants = append(boxPlants, plant)
 		}
+	}
+	return boxPlants, nil
+}
+
+// renotify - notifies the plants again once the open alert's last notification
+// is older than the rule's Renotify
+func (e Evaluator) renotify(ar alertRule, alert Alert) {
+	metric, boxID := alert.Metric, alert.Box
+	last, err := e.State.GetAlertLastNotification(metric.ControllerID, boxID, ar.Key)
+	if err != nil {
+		logrus.Errorf("e.State.GetAlertLastNotification in renotify %q - metric: %+v boxID: %d", err, metric, boxID)
+		return
+	}
+	if last.IsZero() {
+		last = alert.StartedAt
+	}
+	if alert.Time.Sub(last) < ar.Renotify {
 		return
 	}
-	if sht21Present, err := getSensorPresentForBox(metric.ControllerID, boxID); !sht21Present || err != nil {
+	plants, err := e.rulePlants(ar, metric.ControllerID, boxID)
+	if err != nil {
+		logrus.Errorf("e.rulePlants in renotify %q - metric: %+v boxID: %d", err, metric, boxID)
+		return
+	}
+	if err := e.State.SetAlertLastNotification(metric.ControllerID, boxID, ar.Key, alert.Time, ar.lastNotificationTTL()); err != nil {
+		logrus.Errorf("e.State.SetAlertLastNotification in renotify %q - metric: %+v boxID: %d", err, metric, boxID)
+		return
+	}
+	e.Notifier.AlertRepeated(alert, plants)
+}
+
+func (e Evaluator) checkMetric(ar alertRule, metric pubsub.ControllerIntMetric) {
+	boxID, err := ar.boxNum(metric)
+	if err != nil {
+		logrus.Errorf("ar.boxNum in checkMetric %q - %+v", err, metric)
+		return
+	}
+	if ar.BoxID.Valid && boxID != ar.DeviceBox {
+		return
+	}
+	if enabled, err := e.Controllers.GetBoxEnabled(metric.ControllerID, boxID); !enabled || err != nil {
 		if err != nil {
-			logrus.Errorf("getSensorPresentForBox in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+			logrus.Errorf("e.Controllers.GetBoxEnabled in checkMetric %q - %d", err, boxID)
 		}
 		return
 	}
-	timerPower, err := kv.GetTimerPower(metric.ControllerID, boxID)
+	if ar.SensorRequired {
+		if present, err := e.Controllers.GetSHT21PresentForBox(metric.ControllerID, boxID); !present || err != nil {
+			if err != nil {
+				logrus.Errorf("e.Controllers.GetSHT21PresentForBox in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+			}
+			return
+		}
+	}
+	timerPower, err := e.Controllers.GetTimerPower(metric.ControllerID, boxID)
 	if err != nil {
-		logrus.Errorf("kv.GetTimerPower in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+		logrus.Errorf("e.Controllers.GetTimerPower in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
 		return
 	}
-	minValue, maxValue, err := getMinMax(metric.ControllerID, boxID, timerPower)
+	thresholds, err := e.Thresholds.GetBoxThresholds(metric.ControllerID, boxID)
 	if err != nil {
-		logrus.Errorf("getMinMax in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+		logrus.Errorf("e.Thresholds.GetBoxThresholds in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
 		return
 	}
+	minValue, maxValue := ar.GetMinMax(thresholds, timerPower)
 
-	alertStatus, err := getAlertStatus(metric.ControllerID, boxID)
+	open, isOpen, err := e.Incidents.GetOpenIncident(metric.ControllerID, boxID, ar.Key)
 	if err != nil {
-		logrus.Errorf("getAlertStatus in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+		logrus.Errorf("e.Incidents.GetOpenIncident in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
 		return
 	}
 
-	tooLow := metric.Value < minValue
-	tooHigh := metric.Value > maxValue
+	now := e.Now()
+	alert := Alert{
+		RuleKey:    ar.Key,
+		MetricName: ar.MetricName,
+		Comparison: ar.Comparison,
+		Metric:     metric,
+		Box:        boxID,
+		TimerPower: timerPower,
+		MinValue:   minValue,
+		MaxValue:   maxValue,
+		Time:       now,
+		content:    ar.GetAlertContent,
+	}
+	tooLow, tooHigh := ar.outOfBounds(metric.Value, minValue, maxValue)
 	if tooLow || tooHigh {
-		if alertStatus {
+		if isOpen {
+			alert.Type, alert.StartedAt = open.Type, open.StartedAt
+			if err := e.Incidents.UpdateIncident(alert); err != nil {
+				logrus.Errorf("e.Incidents.UpdateIncident in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+			}
+			e.Notifier.AlertUpdated(alert)
+			if ar.Renotify > 0 {
+				e.renotify(ar, alert)
+			}
 			return
 		}
-		err = setAlertStatus(metric.ControllerID, boxID, true)
-		if err != nil {
-			logrus.Errorf("setAlertStatus in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
-			return
+
+		startedAt := now
+		if ar.MinDuration > 0 {
+			since, err := e.State.GetAlertSince(metric.ControllerID, boxID, ar.Key)
+			if err != nil {
+				logrus.Errorf("e.State.GetAlertSince in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+				return
+			}
+			if since.IsZero() {
+				if err := e.State.SetAlertSince(metric.ControllerID, boxID, ar.Key, now, ar.MinDuration+15*time.Minute); err != nil {
+					logrus.Errorf("e.State.SetAlertSince in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+				}
+				return
+			}
+			if now.Sub(since) < ar.MinDuration {
+				return
+			}
+			startedAt = since
+		}
+
+		if ar.Cooldown > 0 {
+			last, err := e.State.GetAlertLastNotification(metric.ControllerID, boxID, ar.Key)
+			if err != nil {
+				logrus.Errorf("e.State.GetAlertLastNotification in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+				return
+			}
+			if !last.IsZero() && now.Sub(last) < ar.Cooldown {
+				return
+			}
 		}
 
 		alertType := ""
@@ -100,51 +270,51 @@ func checkMetric(metricName string, getAlertContent getAlertContentFunc, metric
 		} else if tooHigh {
 			alertType = alertTypeTooHigh
 		}
-		err = setAlertType(metric.ControllerID, boxID, alertType)
+		plants, err := e.rulePlants(ar, metric.ControllerID, boxID)
 		if err != nil {
-			logrus.Errorf("setAlertType in checkMetric %q - metric: %+v boxID: %d alertType: %s", err, metric, boxID, alertType)
+			logrus.Errorf("e.rulePlants in checkMetric %q - metric: %+v boxID: %d alertType: %s", err, metric, boxID, alertType)
 			return
 		}
-		plants, err := db.GetActivePlantsForControllerIdentifier(metric.ControllerID, boxID)
-		if err != nil {
-			logrus.Errorf("db.GetPlantsForController in checkMetric %q - metric: %+v boxID: %d alertType: %s", err, metric, boxID, alertType)
+		// incidents are recorded per plant, a box without plants has nothing to alert
+		if len(plants) == 0 {
+			return
+		}
+
+		alert.Type, alert.StartedAt = alertType, startedAt
+		if err := e.Incidents.StartIncident(alert, plants); err != nil {
+			logrus.Errorf("e.Incidents.StartIncident in checkMetric %q - metric: %+v boxID: %d alertType: %s", err, metric, boxID, alertType)
 			return
 		}
-		logrus.Infof("%s alert %s: %s{id=%s}=%f (timerPower: %f)", metricName, alertType, metric.Key, metric.ControllerID, metric.Value, timerPower)
-		for _, plant := range plants {
-			if plant.AlertsEnabled == false {
-				continue
+		if ar.Cooldown > 0 || ar.Renotify > 0 {
+			if err := e.State.SetAlertLastNotification(metric.ControllerID, boxID, ar.Key, now, ar.lastNotificationTTL()); err != nil {
+				logrus.Errorf("e.State.SetAlertLastNotification in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
 			}
-			title, body := getAlertContent(plant, alertType, timerPower, metric.Value, minValue, maxValue)
-			data, notif := NewNotificationDataAlert(title, body, "", plant.ID.UUID)
-			notifications.SendNotificationToUser(plant.UserID, data, &notif)
-			logrus.Infof("Sending notification %q %q %+v plant: %s feed: %s box: %s", title, body, metric, plant.ID.UUID, plant.FeedID, plant.BoxID)
-			prometheus.AlertTriggered(metricName, alertType)
 		}
+		e.Notifier.AlertStarted(alert, plants)
 	} else {
-		if !alertStatus {
-			return
+		if ar.MinDuration > 0 {
+			if err := e.State.DelAlertSince(metric.ControllerID, boxID, ar.Key); err != nil {
+				logrus.Errorf("e.State.DelAlertSince in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
+			}
 		}
-
-		alertType, err := getAlertType(metric.ControllerID, boxID)
-		if err != nil && !errors.Is(err, redis.Nil) {
-			logrus.Errorf("%q", err)
+		if !isOpen {
 			return
 		}
 
-		if alertType == alertTypeTooLow && metric.Value < minValue*1.15 {
+		alert.Type, alert.StartedAt = open.Type, open.StartedAt
+		endMin, endMax := ar.endBounds(minValue, maxValue)
+		if alert.Type == alertTypeTooLow && metric.Value < endMin {
 			return
 		}
-		if alertType == alertTypeTooHigh && metric.Value > maxValue/1.15 {
+		if alert.Type == alertTypeTooHigh && metric.Value > endMax {
 			return
 		}
 
-		err = setAlertStatus(metric.ControllerID, boxID, false)
-		if err != nil {
-			logrus.Errorf("%q", err)
+		if err := e.Incidents.EndIncident(alert); err != nil {
+			logrus.Errorf("e.Incidents.EndIncident in checkMetric %q - metric: %+v boxID: %d", err, metric, boxID)
 			return
 		}
-		logrus.Infof("End %s alert: %s{id=%s}=%f", metricName, metric.Key, metric.ControllerID, metric.Value)
+		e.Notifier.AlertEnded(alert)
 	}
 }
 
@@ -152,6 +322,6 @@ func Init() {
 	prometheus.InitNotificationSent(NotificationTypeReminder)
 	prometheus.InitNotificationSent(NotificationTypeAlert)
 
-	initTemperature()
-	initHumidity()
+	initRules()
+	initWatchdog()
 }
@@ -0,0 +1,194 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"path"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+// Controllers - controllers' values read while evaluating the alerts
+type Controllers interface {
+	GetBoxEnabled(controllerID string, box int) (bool, error)
+	GetSHT21PresentForBox(controllerID string, box int) (bool, error)
+	GetTimerPower(controllerID string, box int) (float64, error)
+	GetTemperature(controllerID string, box int) (float64, error)
+	GetHumidity(controllerID string, box int) (float64, error)
+}
+
+// State - alerts' short lived state kept between two metrics, rule is the alert's key
+type State interface {
+	GetAlertSince(controllerID string, box int, rule string) (time.Time, error)
+	SetAlertSince(controllerID string, box int, rule string, since time.Time, expiration time.Duration) error
+	DelAlertSince(controllerID string, box int, rule string) error
+	GetAlertLastNotification(controllerID string, box int, rule string) (time.Time, error)
+	SetAlertLastNotification(controllerID string, box int, rule string, date time.Time, expiration time.Duration) error
+}
+
+// OpenIncident - ongoing alert of a box's rule
+type OpenIncident struct {
+	Type      string
+	StartedAt time.Time
+}
+
+// Incidents - an alert is ongoing while its incident is open, incidents don't
+// expire, they're only ended when the value is back within bounds
+type Incidents interface {
+	GetOpenIncident(controllerID string, box int, rule string) (OpenIncident, bool, error)
+	StartIncident(a Alert, plants []appbackend.Plant) error
+	// UpdateIncident - the metric is still out of bounds
+	UpdateIncident(a Alert) error
+	EndIncident(a Alert) error
+}
+
+// ThresholdsSource -
+type ThresholdsSource interface {
+	GetBoxThresholds(controllerID string, box int) (Thresholds, error)
+}
+
+// PlantsSource -
+type PlantsSource interface {
+	GetActivePlants(controllerID string, box int) ([]appbackend.Plant, error)
+}
+
+// Alert - alert transition produced by the evaluator
+type Alert struct {
+	RuleKey    string
+	MetricName string
+	Comparison string
+	Metric     pubsub.ControllerIntMetric
+	Box        int
+	// Type - TOO_HIGH or TOO_LOW
+	Type       string
+	TimerPower float64
+	MinValue   float64
+	MaxValue   float64
+	Time       time.Time
+	// StartedAt - first out of bounds sample
+	StartedAt time.Time
+
+	content getAlertContentFunc
+}
+
+// Content - notification title and body of the alert for the plant
+func (a Alert) Content(plant appbackend.Plant) (*notifications.LocalizedText, *notifications.LocalizedText) {
+	return a.content(plant, a.Type, a.TimerPower, a.Metric.Value, a.MinValue, a.MaxValue)
+}
+
+// Notifier - receives the alerts' transitions
+type Notifier interface {
+	AlertStarted(a Alert, plants []appbackend.Plant)
+	// AlertUpdated - the metric is still out of bounds
+	AlertUpdated(a Alert)
+	// AlertRepeated - the alert is still open after the rule's renotify interval
+	AlertRepeated(a Alert, plants []appbackend.Plant)
+	AlertEnded(a Alert)
+}
+
+// RuleOptions - overrides a builtin rule's settings, used to tune them offline,
+// nil fields keep the rule's setting
+type RuleOptions struct {
+	// Hysteresis - absolute hysteresis, replaces the rule's HysteresisFactor
+	Hysteresis  *float64
+	MinDuration *time.Duration
+	Cooldown    *time.Duration
+	Renotify    *time.Duration
+}
+
+// Evaluator - evaluates the alert rules, the live evaluator reads and writes
+// redis and postgres, replays use in-memory implementations.
+type Evaluator struct {
+	Controllers Controllers
+	State       State
+	Incidents   Incidents
+	Thresholds  ThresholdsSource
+	Plants      PlantsSource
+	Notifier    Notifier
+
+	// Now - evaluation clock, replays use the metrics' timestamps
+	Now func() time.Time
+
+	// Overrides - builtin rules' settings by rule key, ie. TEMP
+	Overrides map[string]RuleOptions
+}
+
+func (e Evaluator) override(ar alertRule) alertRule {
+	o, ok := e.Overrides[ar.Key]
+	if !ok {
+		return ar
+	}
+	if o.Hysteresis != nil {
+		ar.Hysteresis, ar.HysteresisFactor = *o.Hysteresis, 0
+	}
+	if o.MinDuration != nil {
+		ar.MinDuration = *o.MinDuration
+	}
+	if o.Cooldown != nil {
+		ar.Cooldown = *o.Cooldown
+	}
+	if o.Renotify != nil {
+		ar.Renotify = *o.Renotify
+	}
+	return ar
+}
+
+// ruleMatches - pattern is the subscription the metric was received on, a
+// metric received on several patterns is only checked against each pattern's
+// rules, an empty pattern matches the rule against the metric's key.
+func ruleMatches(ar alertRule, pattern string, metric pubsub.ControllerIntMetric) bool {
+	if pattern != "" {
+		return ar.Pattern == pattern
+	}
+	ok, _ := path.Match(ar.Pattern, metric.Key)
+	return ok
+}
+
+// rulesForMetric - builtin rules and the controller's user rules matching the metric
+func rulesForMetric(pattern string, metric pubsub.ControllerIntMetric) []alertRule {
+	rules := []alertRule{}
+	for _, ar := range builtinRules {
+		if ruleMatches(ar, pattern, metric) {
+			rules = append(rules, ar)
+		}
+	}
+	userRulesMutex.RLock()
+	for _, ar := range userRules[metric.ControllerID] {
+		if ruleMatches(ar, pattern, metric) {
+			rules = append(rules, ar)
+		}
+	}
+	userRulesMutex.RUnlock()
+	return rules
+}
+
+// Evaluate - checks the rules matching the metric, and the VPD when the metric
+// is a box's temperature or humidity. pattern is the subscription the metric
+// was received on, replays pass an empty pattern.
+func (e Evaluator) Evaluate(pattern string, metric pubsub.ControllerIntMetric) {
+	for _, ar := range rulesForMetric(pattern, metric) {
+		e.checkMetric(e.override(ar), metric)
+	}
+	if ruleMatches(temperatureRule, pattern, metric) || ruleMatches(humidityRule, pattern, metric) {
+		e.checkVPD(metric)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+type recordingNotifier struct {
+	events []string
+}
+
+func (n *recordingNotifier) AlertStarted(a Alert, plants []appbackend.Plant) {
+	n.events = append(n.events, fmt.Sprintf("%s START %s %s", a.Time.Format("15:04"), a.RuleKey, a.Type))
+}
+
+func (n *recordingNotifier) AlertUpdated(a Alert) {
+	n.events = append(n.events, fmt.Sprintf("%s UPDATE %s %s", a.Time.Format("15:04"), a.RuleKey, a.Type))
+}
+
+func (n *recordingNotifier) AlertRepeated(a Alert, plants []appbackend.Plant) {
+	n.events = append(n.events, fmt.Sprintf("%s REPEAT %s %s since %s", a.Time.Format("15:04"), a.RuleKey, a.Type, a.StartedAt.Format("15:04")))
+}
+
+func (n *recordingNotifier) AlertEnded(a Alert) {
+	n.events = append(n.events, fmt.Sprintf("%s END %s %s since %s", a.Time.Format("15:04"), a.RuleKey, a.Type, a.StartedAt.Format("15:04")))
+}
+
+// sample - metric received minute minutes after midnight
+type sample struct {
+	minute int
+	key    string
+	value  float64
+}
+
+func minutes(m int) *time.Duration {
+	d := time.Duration(m) * time.Minute
+	return &d
+}
+
+func TestEvaluator(t *testing.T) {
+	boxID := uuid.Must(uuid.NewV4())
+	plant := appbackend.Plant{Name: "test", BoxID: boxID, AlertsEnabled: true}
+	userRule := newUserAlertRule(db.DeviceAlertRule{
+		AlertRule: db.AlertRule{
+			ID:         uuid.NullUUID{UUID: uuid.Must(uuid.NewV4()), Valid: true},
+			BoxID:      boxID,
+			Name:       "co2",
+			Metric:     "CO2",
+			Comparison: db.AlertComparisonAbove,
+			MaxDay:     null.FloatFrom(1500),
+		},
+		ControllerID: "c1",
+		DeviceBox:    0,
+	})
+	userRules = map[string][]alertRule{"c1": {userRule}}
+	defer func() { userRules = map[string][]alertRule{} }()
+
+	tests := []struct {
+		name      string
+		plants    []appbackend.Plant
+		overrides map[string]RuleOptions
+		samples   []sample
+		want      []string
+	}{
+		{
+			name:    "temperature within bounds",
+			samples: []sample{{0, "BOX_0_TEMP", 25}, {1, "BOX_0_TEMP", 31}},
+		},
+		{
+			name:    "temperature too high until back within hysteresis",
+			samples: []sample{{0, "BOX_0_TEMP", 33}, {1, "BOX_0_TEMP", 34}, {2, "BOX_0_TEMP", 30}, {3, "BOX_0_TEMP", 27}},
+			want:    []string{"00:00 START TEMP TOO_HIGH", "00:01 UPDATE TEMP TOO_HIGH", "00:03 END TEMP TOO_HIGH since 00:00"},
+		},
+		{
+			name:    "humidity too low",
+			samples: []sample{{0, "BOX_0_HUMI", 20}, {1, "BOX_0_HUMI", 40}},
+			want:    []string{"00:00 START HUMI TOO_LOW", "00:01 END HUMI TOO_LOW since 00:00"},
+		},
+		{
+			name:    "disabled box",
+			samples: []sample{{0, "BOX_0_ENABLED", 0}, {1, "BOX_0_TEMP", 40}},
+		},
+		{
+			name:    "box without plants",
+			plants:  []appbackend.Plant{},
+			samples: []sample{{0, "BOX_0_TEMP", 40}},
+		},
+		{
+			name: "vpd starts at the first out of bounds sample after its min duration",
+			samples: []sample{
+				{0, "BOX_0_TEMP", 25}, {0, "BOX_0_HUMI", 50},
+				{5, "BOX_0_HUMI", 50}, {11, "BOX_0_HUMI", 50},
+				{12, "BOX_0_HUMI", 70},
+			},
+			want: []string{"00:11 START VPD TOO_HIGH", "00:12 END VPD TOO_HIGH since 00:00"},
+		},
+		{
+			name: "vpd back in bounds before its min duration",
+			samples: []sample{
+				{0, "BOX_0_TEMP", 25}, {0, "BOX_0_HUMI", 50},
+				{5, "BOX_0_HUMI", 65}, {11, "BOX_0_HUMI", 50},
+			},
+		},
+		{
+			name:      "overridden min duration only",
+			overrides: map[string]RuleOptions{"VPD": {MinDuration: minutes(0)}},
+			samples:   []sample{{0, "BOX_0_TEMP", 25}, {0, "BOX_0_HUMI", 50}},
+			want:      []string{"00:00 START VPD TOO_HIGH"},
+		},
+		{
+			name:      "overridden hysteresis keeps the min duration",
+			overrides: map[string]RuleOptions{"VPD": {Hysteresis: func() *float64 { h := 0.0; return &h }()}},
+			samples:   []sample{{0, "BOX_0_TEMP", 25}, {0, "BOX_0_HUMI", 50}},
+		},
+		{
+			name:      "overridden cooldown",
+			overrides: map[string]RuleOptions{"TEMP": {Cooldown: minutes(30)}},
+			samples: []sample{
+				{0, "BOX_0_TEMP", 33}, {1, "BOX_0_TEMP", 25},
+				{10, "BOX_0_TEMP", 33}, {11, "BOX_0_TEMP", 25},
+				{40, "BOX_0_TEMP", 33},
+			},
+			want: []string{"00:00 START TEMP TOO_HIGH", "00:01 END TEMP TOO_HIGH since 00:00", "00:40 START TEMP TOO_HIGH"},
+		},
+		{
+			name:    "temperature still too high after the renotify interval",
+			samples: []sample{{0, "BOX_0_TEMP", 33}, {29, "BOX_0_TEMP", 33}, {30, "BOX_0_TEMP", 33}, {45, "BOX_0_TEMP", 33}, {60, "BOX_0_TEMP", 33}, {61, "BOX_0_TEMP", 25}},
+			want: []string{
+				"00:00 START TEMP TOO_HIGH", "00:29 UPDATE TEMP TOO_HIGH",
+				"00:30 UPDATE TEMP TOO_HIGH", "00:30 REPEAT TEMP TOO_HIGH since 00:00",
+				"00:45 UPDATE TEMP TOO_HIGH",
+				"01:00 UPDATE TEMP TOO_HIGH", "01:00 REPEAT TEMP TOO_HIGH since 00:00",
+				"01:01 END TEMP TOO_HIGH since 00:00",
+			},
+		},
+		{
+			name:      "overridden renotify",
+			overrides: map[string]RuleOptions{"TEMP": {Renotify: minutes(0)}},
+			samples:   []sample{{0, "BOX_0_TEMP", 33}, {30, "BOX_0_TEMP", 33}},
+			want:      []string{"00:00 START TEMP TOO_HIGH", "00:30 UPDATE TEMP TOO_HIGH"},
+		},
+		{
+			name:    "user rule",
+			samples: []sample{{0, "CO2", 1400}, {1, "CO2", 1600}, {2, "CO2", 1400}},
+			want:    []string{"00:01 START " + userRule.Key + " TOO_HIGH", "00:02 END " + userRule.Key + " TOO_HIGH since 00:01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plants := tt.plants
+			if plants == nil {
+				plants = []appbackend.Plant{plant}
+			}
+			var now time.Time
+			clock := func() time.Time { return now }
+			controllers := NewMemoryControllers()
+			notifier := &recordingNotifier{}
+			e := Evaluator{
+				Controllers: controllers,
+				State:       NewMemoryState(clock),
+				Incidents:   NewMemoryIncidents(),
+				Thresholds:  StaticThresholds(StagePresets[db.AlertStageVeg]),
+				Plants:      StaticPlants(plants),
+				Notifier:    notifier,
+				Now:         clock,
+				Overrides:   tt.overrides,
+			}
+			for _, s := range tt.samples {
+				now = time.Date(2021, 1, 1, 0, s.minute, 0, 0, time.UTC)
+				metric := pubsub.ControllerIntMetric{ControllerID: "c1", Key: s.key, Value: s.value}
+				controllers.Observe(metric)
+				e.Evaluate("", metric)
+			}
+			if !reflect.DeepEqual(notifier.events, tt.want) {
+				t.Errorf("events = %q, want %q", notifier.events, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesForMetric(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    []string
+	}{
+		{"", "BOX_0_TEMP", []string{"TEMP"}},
+		{"BOX_*_TEMP", "BOX_0_TEMP", []string{"TEMP"}},
+		// received on another subscription, evaluated there
+		{"BOX_0_TEMP", "BOX_0_TEMP", []string{}},
+		{"", "BOX_1_HUMI", []string{"HUMI"}},
+		{"", "BOX_1_TIMER_OUTPUT", []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, ar := range rulesForMetric(tt.pattern, pubsub.ControllerIntMetric{ControllerID: "c1", Key: tt.key}) {
+			got = append(got, ar.Key)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rulesForMetric(%q, %q) = %q, want %q", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -20,71 +20,35 @@ package alerts
 
 import (
 	"fmt"
+	"time"
 
-	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
-	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
-	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
 )
 
-const (
-	minHumiDay   = 25
-	maxHumiDay   = 75
-	minHumiNight = 35
-	maxHumiNight = 85
-)
-
-func getHumidityMinMax(controllerID string, boxID int, timerPower float64) (float64, float64, error) {
-	minNight, err := kv.GetAlertMinHumidityNight(controllerID, boxID, minHumiNight)
-	if err != nil {
-		return 0, 0, err
-	}
-	minDay, err := kv.GetAlertMinHumidityDay(controllerID, boxID, minHumiDay)
-	if err != nil {
-		return 0, 0, err
-	}
-	maxNight, err := kv.GetAlertMaxHumidityNight(controllerID, boxID, maxHumiNight)
-	if err != nil {
-		return 0, 0, err
-	}
-	maxDay, err := kv.GetAlertMaxHumidityDay(controllerID, boxID, maxHumiDay)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	return minNight + (minDay-minNight)*timerPower/100, maxNight + (maxDay-maxNight)*timerPower/100, nil
+func getHumidityMinMax(t Thresholds, timerPower float64) (float64, float64) {
+	return interpolateMinMax(t.MinHumiNight, t.MinHumiDay, t.MaxHumiNight, t.MaxHumiDay, timerPower)
 }
 
-func getHumidityAlertContent(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (string, string) {
-	alertTypesToText := map[string]string{
-		alertTypeTooHigh: "too humid",
-		alertTypeTooLow:  "too dry",
-	}
-
-	title := fmt.Sprintf("Humidity alert")
-	body := fmt.Sprintf("Your plant %s is %s\nIt's currently at %d%%", plant.Name, alertTypesToText[alertType], int(value))
+func getHumidityAlertContent(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (*notifications.LocalizedText, *notifications.LocalizedText) {
+	limit := minValue
 	if alertType == alertTypeTooHigh {
-		body = fmt.Sprintf("%s, try to keep it below %d%%", body, int(maxValue))
-	} else {
-		body = fmt.Sprintf("%s, try to keep it above %d%%", body, int(minValue))
-	}
-	if timerPower == 0 {
-		body = fmt.Sprintf("%s during the night.", body)
-	} else {
-		body = fmt.Sprintf("%s during the day.", body)
+		limit = maxValue
 	}
+	title := notifications.Loc("alert_humidity_title")
+	body := notifications.Loc(alertContentKey("humidity", alertType, timerPower), plant.Name, fmt.Sprintf("%d%%", int(value)), fmt.Sprintf("%d%%", int(limit)))
 	return title, body
 }
 
-func listenHumidityMetrics() {
-	prometheus.InitAlertTriggered("HUMI", alertTypeTooLow)
-	prometheus.InitAlertTriggered("HUMI", alertTypeTooHigh)
-	ch := pubsub.SubscribeControllerIntMetric("*.BOX_*_HUMI")
-	for metric := range ch {
-		checkMetric("HUMI", getHumidityAlertContent, metric, getHumidityMinMax, kv.GetSHT21PresentForBox, kv.GetHumidityAlertStatus, kv.SetHumidityAlertStatus, kv.GetHumidityAlertType, kv.SetHumidityAlertType)
-	}
-}
-
-func initHumidity() {
-	go listenHumidityMetrics()
+var humidityRule = alertRule{
+	Key:              "HUMI",
+	MetricName:       "HUMI",
+	Pattern:          "BOX_*_HUMI",
+	Comparison:       db.AlertComparisonOutside,
+	HysteresisFactor: 1.15,
+	Renotify:         30 * time.Minute,
+	GetMinMax:        getHumidityMinMax,
+	GetAlertContent:  getHumidityAlertContent,
+	SensorRequired:   true,
 }
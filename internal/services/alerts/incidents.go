@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+)
+
+const feedEntryTypeAlert = "FE_ALERT"
+
+type alertFeedEntryParams struct {
+	IncidentID string     `json:"incidentID"`
+	Metric     string     `json:"metric"`
+	Type       string     `json:"type"`
+	MinValue   null.Float `json:"minValue"`
+	MaxValue   null.Float `json:"maxValue"`
+	PeakValue  float64    `json:"peakValue"`
+	StartedAt  time.Time  `json:"startedAt"`
+	EndedAt    time.Time  `json:"endedAt"`
+	Duration   int64      `json:"duration"`
+}
+
+// startIncidents - opens an incident for each plant of the box
+func startIncidents(a Alert, plants []appbackend.Plant) error {
+	for _, plant := range plants {
+		incident := db.AlertIncident{
+			UserID:       plant.UserID,
+			PlantID:      plant.ID.UUID,
+			BoxID:        plant.BoxID,
+			ControllerID: a.Metric.ControllerID,
+			DeviceBox:    a.Box,
+			RuleKey:      a.RuleKey,
+			Metric:       a.Metric.Key,
+			Type:         a.Type,
+			PeakValue:    a.Metric.Value,
+			StartedAt:    a.StartedAt,
+		}
+		if a.Comparison != db.AlertComparisonAbove {
+			incident.MinValue = null.FloatFrom(a.MinValue)
+		}
+		if a.Comparison != db.AlertComparisonBelow {
+			incident.MaxValue = null.FloatFrom(a.MaxValue)
+		}
+		if _, err := db.CreateAlertIncident(incident); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func endIncidents(a Alert) error {
+	incidents, err := db.EndOpenAlertIncidents(a.Metric.ControllerID, a.Box, a.RuleKey, a.Time)
+	if err != nil {
+		return err
+	}
+	for _, incident := range incidents {
+		if err := createIncidentFeedEntry(incident); err != nil {
+			logrus.Errorf("createIncidentFeedEntry in endIncidents %q - %+v", err, incident)
+		}
+	}
+	return nil
+}
+
+// createIncidentFeedEntry - adds the incident to the plant's diary, if enabled by the alertsFeedEntries plant setting
+func createIncidentFeedEntry(incident db.AlertIncident) error {
+	plant, err := db.GetPlant(incident.PlantID)
+	if err != nil {
+		return err
+	}
+	plantSettings := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(plant.Settings), &plantSettings); err != nil {
+		return err
+	}
+	if enabled, ok := plantSettings["alertsFeedEntries"].(bool); !ok || !enabled {
+		return nil
+	}
+
+	params, err := json.Marshal(alertFeedEntryParams{
+		IncidentID: incident.ID.UUID.String(),
+		Metric:     incident.Metric,
+		Type:       incident.Type,
+		MinValue:   incident.MinValue,
+		MaxValue:   incident.MaxValue,
+		PeakValue:  incident.PeakValue,
+		StartedAt:  incident.StartedAt,
+		EndedAt:    incident.EndedAt.Time,
+		Duration:   incident.Duration.Int64,
+	})
+	if err != nil {
+		return err
+	}
+	fe := appbackend.FeedEntry{
+		UserID: plant.UserID,
+		FeedID: plant.FeedID,
+		Date:   incident.StartedAt,
+		Type:   feedEntryTypeAlert,
+		Params: string(params),
+	}
+	feID, err := db.CreateFeedEntry(fe)
+	if err != nil {
+		return err
+	}
+	return db.SetAlertIncidentFeedEntryID(incident.ID.UUID, feID)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+type kvControllers struct{}
+
+func (kvControllers) GetBoxEnabled(controllerID string, box int) (bool, error) {
+	return kv.GetBoxEnabled(controllerID, box)
+}
+
+func (kvControllers) GetSHT21PresentForBox(controllerID string, box int) (bool, error) {
+	topology, err := devices.GetTopology(controllerID)
+	if err != nil {
+		return false, err
+	}
+	boxTopology, ok := topology.Box(box)
+	return ok && boxTopology.SensorPresent, nil
+}
+
+func (kvControllers) GetTimerPower(controllerID string, box int) (float64, error) {
+	return kv.GetTimerPower(controllerID, box)
+}
+
+func (kvControllers) GetTemperature(controllerID string, box int) (float64, error) {
+	return kv.GetTemperature(controllerID, box)
+}
+
+func (kvControllers) GetHumidity(controllerID string, box int) (float64, error) {
+	return kv.GetHumidity(controllerID, box)
+}
+
+type kvState struct{}
+
+func (kvState) GetAlertSince(controllerID string, box int, rule string) (time.Time, error) {
+	return kv.GetAlertSince(controllerID, box, rule)
+}
+
+func (kvState) SetAlertSince(controllerID string, box int, rule string, since time.Time, expiration time.Duration) error {
+	return kv.SetAlertSince(controllerID, box, rule, since, expiration)
+}
+
+func (kvState) DelAlertSince(controllerID string, box int, rule string) error {
+	return kv.DelAlertSince(controllerID, box, rule)
+}
+
+func (kvState) GetAlertLastNotification(controllerID string, box int, rule string) (time.Time, error) {
+	return kv.GetAlertLastNotification(controllerID, box, rule)
+}
+
+func (kvState) SetAlertLastNotification(controllerID string, box int, rule string, date time.Time, expiration time.Duration) error {
+	return kv.SetAlertLastNotification(controllerID, box, rule, date, expiration)
+}
+
+// dbIncidents - incidents are stored in the alertincidents table
+type dbIncidents struct{}
+
+func (dbIncidents) GetOpenIncident(controllerID string, box int, rule string) (OpenIncident, bool, error) {
+	incident, ok, err := db.GetOpenAlertIncident(controllerID, box, rule)
+	return OpenIncident{Type: incident.Type, StartedAt: incident.StartedAt}, ok, err
+}
+
+func (dbIncidents) StartIncident(a Alert, plants []appbackend.Plant) error {
+	return startIncidents(a, plants)
+}
+
+func (dbIncidents) UpdateIncident(a Alert) error {
+	return db.UpdateOpenAlertIncidentsPeak(a.Metric.ControllerID, a.Box, a.RuleKey, a.Type, a.Metric.Value)
+}
+
+func (dbIncidents) EndIncident(a Alert) error {
+	return endIncidents(a)
+}
+
+type cachedThresholds struct{}
+
+func (cachedThresholds) GetBoxThresholds(controllerID string, box int) (Thresholds, error) {
+	return getBoxThresholds(controllerID, box)
+}
+
+type dbPlants struct{}
+
+func (dbPlants) GetActivePlants(controllerID string, box int) ([]appbackend.Plant, error) {
+	return db.GetActivePlantsForControllerIdentifier(controllerID, box)
+}
+
+// liveNotifier - notifies the plants' owners
+type liveNotifier struct{}
+
+func (n liveNotifier) AlertStarted(a Alert, plants []appbackend.Plant) {
+	logrus.Infof("%s alert %s: %s{id=%s}=%f (timerPower: %f)", a.MetricName, a.Type, a.Metric.Key, a.Metric.ControllerID, a.Metric.Value, a.TimerPower)
+	n.notifyPlants(a, plants)
+}
+
+func (liveNotifier) AlertUpdated(a Alert) {}
+
+func (n liveNotifier) AlertRepeated(a Alert, plants []appbackend.Plant) {
+	logrus.Infof("%s alert %s still open since %s: %s{id=%s}=%f (timerPower: %f)", a.MetricName, a.Type, a.StartedAt, a.Metric.Key, a.Metric.ControllerID, a.Metric.Value, a.TimerPower)
+	n.notifyPlants(a, plants)
+}
+
+func (liveNotifier) notifyPlants(a Alert, plants []appbackend.Plant) {
+	for _, plant := range plants {
+		if plant.AlertsEnabled == false {
+			continue
+		}
+		title, body := a.Content(plant)
+		data, notif := NewNotificationDataAlert(title, body, "", plant.ID.UUID)
+		notifications.SendNotificationToUser(plant.UserID, data, &notif)
+		logrus.Infof("Sending notification %q %q %+v plant: %s feed: %s box: %s", title.Key, body.Key, a.Metric, plant.ID.UUID, plant.FeedID, plant.BoxID)
+		prometheus.AlertTriggered(a.MetricName, a.Type)
+	}
+}
+
+func (liveNotifier) AlertEnded(a Alert) {
+	logrus.Infof("End %s alert: %s{id=%s}=%f", a.MetricName, a.Metric.Key, a.Metric.ControllerID, a.Metric.Value)
+}
+
+var liveEvaluator = Evaluator{
+	Controllers: kvControllers{},
+	State:       kvState{},
+	Incidents:   dbIncidents{},
+	Thresholds:  cachedThresholds{},
+	Plants:      dbPlants{},
+	Notifier:    liveNotifier{},
+	Now:         time.Now,
+}
@@ -42,17 +42,14 @@ func (n NotificationDataAlert) ToMap() map[string]string {
 	})
 }
 
-func NewNotificationDataAlert(title, body, imageUrl string, plantID uuid.UUID) (NotificationDataAlert, messaging.Notification) {
+func NewNotificationDataAlert(title, body *notifications.LocalizedText, imageUrl string, plantID uuid.UUID) (NotificationDataAlert, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypeAlert, title, body)
 	return NotificationDataAlert{
-			NotificationBaseData: notifications.NotificationBaseData{
-				Type:  NotificationTypeAlert,
-				Title: title,
-				Body:  body,
-			},
-			PlantID: plantID,
-		}, messaging.Notification{
-			Title:    title,
-			Body:     body,
-			ImageURL: imageUrl,
-		}
+		NotificationBaseData: base,
+		PlantID:              plantID,
+	}, messaging.Notification{
+		Title:    base.Title,
+		Body:     base.Body,
+		ImageURL: imageUrl,
+	}
 }
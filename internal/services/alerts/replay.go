@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+type memoryValue struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// MemoryState - State kept in memory, expirations follow the evaluator's clock
+type MemoryState struct {
+	now    func() time.Time
+	values map[string]memoryValue
+}
+
+// NewMemoryState -
+func NewMemoryState(now func() time.Time) *MemoryState {
+	return &MemoryState{now: now, values: map[string]memoryValue{}}
+}
+
+func (s *MemoryState) get(controllerID string, box int, rule, suffix string) (interface{}, bool) {
+	key := fmt.Sprintf("%s.%d.%s%s", controllerID, box, rule, suffix)
+	v, ok := s.values[key]
+	if !ok || (!v.expiresAt.IsZero() && !s.now().Before(v.expiresAt)) {
+		delete(s.values, key)
+		return nil, false
+	}
+	return v.value, true
+}
+
+func (s *MemoryState) set(controllerID string, box int, rule, suffix string, value interface{}, expiration time.Duration) {
+	key := fmt.Sprintf("%s.%d.%s%s", controllerID, box, rule, suffix)
+	v := memoryValue{value: value}
+	if expiration > 0 {
+		v.expiresAt = s.now().Add(expiration)
+	}
+	s.values[key] = v
+}
+
+func (s *MemoryState) getTime(controllerID string, box int, rule, suffix string) (time.Time, error) {
+	v, ok := s.get(controllerID, box, rule, suffix)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return v.(time.Time), nil
+}
+
+func (s *MemoryState) GetAlertSince(controllerID string, box int, rule string) (time.Time, error) {
+	return s.getTime(controllerID, box, rule, "_SINCE")
+}
+
+func (s *MemoryState) SetAlertSince(controllerID string, box int, rule string, since time.Time, expiration time.Duration) error {
+	s.set(controllerID, box, rule, "_SINCE", since, expiration)
+	return nil
+}
+
+func (s *MemoryState) DelAlertSince(controllerID string, box int, rule string) error {
+	delete(s.values, fmt.Sprintf("%s.%d.%s_SINCE", controllerID, box, rule))
+	return nil
+}
+
+func (s *MemoryState) GetAlertLastNotification(controllerID string, box int, rule string) (time.Time, error) {
+	return s.getTime(controllerID, box, rule, "_LAST")
+}
+
+func (s *MemoryState) SetAlertLastNotification(controllerID string, box int, rule string, date time.Time, expiration time.Duration) error {
+	s.set(controllerID, box, rule, "_LAST", date, expiration)
+	return nil
+}
+
+// MemoryIncidents - Incidents kept in memory, one per box's rule
+type MemoryIncidents struct {
+	open map[string]OpenIncident
+}
+
+// NewMemoryIncidents -
+func NewMemoryIncidents() *MemoryIncidents {
+	return &MemoryIncidents{open: map[string]OpenIncident{}}
+}
+
+func memoryIncidentKey(controllerID string, box int, rule string) string {
+	return fmt.Sprintf("%s.%d.%s", controllerID, box, rule)
+}
+
+func (m *MemoryIncidents) GetOpenIncident(controllerID string, box int, rule string) (OpenIncident, bool, error) {
+	incident, ok := m.open[memoryIncidentKey(controllerID, box, rule)]
+	return incident, ok, nil
+}
+
+func (m *MemoryIncidents) StartIncident(a Alert, plants []appbackend.Plant) error {
+	m.open[memoryIncidentKey(a.Metric.ControllerID, a.Box, a.RuleKey)] = OpenIncident{Type: a.Type, StartedAt: a.StartedAt}
+	return nil
+}
+
+func (m *MemoryIncidents) UpdateIncident(a Alert) error {
+	return nil
+}
+
+func (m *MemoryIncidents) EndIncident(a Alert) error {
+	delete(m.open, memoryIncidentKey(a.Metric.ControllerID, a.Box, a.RuleKey))
+	return nil
+}
+
+// MemoryControllers - Controllers fed with the replayed metrics, boxes are
+// enabled with their sensor present, and at full timer power until a
+// BOX_<n>_TIMER_OUTPUT metric says otherwise.
+type MemoryControllers struct {
+	values map[string]float64
+}
+
+// NewMemoryControllers -
+func NewMemoryControllers() *MemoryControllers {
+	return &MemoryControllers{values: map[string]float64{}}
+}
+
+// Observe - keeps the metric's value for the next evaluations
+func (c *MemoryControllers) Observe(metric pubsub.ControllerIntMetric) {
+	c.values[fmt.Sprintf("%s.%s", metric.ControllerID, metric.Key)] = metric.Value
+}
+
+func (c *MemoryControllers) get(controllerID string, box int, key string, def float64) float64 {
+	if v, ok := c.values[fmt.Sprintf("%s.BOX_%d_%s", controllerID, box, key)]; ok {
+		return v
+	}
+	return def
+}
+
+func (c *MemoryControllers) GetBoxEnabled(controllerID string, box int) (bool, error) {
+	return c.get(controllerID, box, "ENABLED", 1) != 0, nil
+}
+
+func (c *MemoryControllers) GetSHT21PresentForBox(controllerID string, box int) (bool, error) {
+	return true, nil
+}
+
+func (c *MemoryControllers) GetTimerPower(controllerID string, box int) (float64, error) {
+	return c.get(controllerID, box, "TIMER_OUTPUT", 100), nil
+}
+
+func (c *MemoryControllers) GetTemperature(controllerID string, box int) (float64, error) {
+	return c.get(controllerID, box, "TEMP", 0), nil
+}
+
+func (c *MemoryControllers) GetHumidity(controllerID string, box int) (float64, error) {
+	return c.get(controllerID, box, "HUMI", 0), nil
+}
+
+// StaticThresholds - same thresholds for all boxes
+type StaticThresholds Thresholds
+
+func (t StaticThresholds) GetBoxThresholds(controllerID string, box int) (Thresholds, error) {
+	return Thresholds(t), nil
+}
+
+// StaticPlants - same plants for all boxes
+type StaticPlants []appbackend.Plant
+
+func (p StaticPlants) GetActivePlants(controllerID string, box int) ([]appbackend.Plant, error) {
+	return p, nil
+}
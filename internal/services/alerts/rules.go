@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// metricPatternRegexp - patterns need a name part, "*" would match every metric
+	metricPatternRegexp = regexp.MustCompile(`^[A-Z0-9_*]*[A-Z][A-Z0-9_*]*$`)
+
+	builtinRules = []alertRule{temperatureRule, humidityRule}
+
+	// userRules - user defined rules indexed by controller identifier
+	userRules      = map[string][]alertRule{}
+	userRulesMutex sync.RWMutex
+
+	metricsCh               = make(chan patternMetric, 100)
+	subscribedPatterns      = map[string]bool{}
+	subscribedPatternsMutex sync.Mutex
+)
+
+type patternMetric struct {
+	pattern string
+	metric  pubsub.ControllerIntMetric
+}
+
+// ValidateAlertRule - checks that the rule can be evaluated
+func ValidateAlertRule(r db.AlertRule) error {
+	if r.Name == "" {
+		return errors.New("Missing name")
+	}
+	if !metricPatternRegexp.MatchString(r.Metric) {
+		return errors.New("Invalid metric pattern")
+	}
+	switch r.Comparison {
+	case db.AlertComparisonAbove:
+		if !r.MaxDay.Valid {
+			return errors.New("Missing maxDay")
+		}
+	case db.AlertComparisonBelow:
+		if !r.MinDay.Valid {
+			return errors.New("Missing minDay")
+		}
+	case db.AlertComparisonOutside:
+		if !r.MinDay.Valid || !r.MaxDay.Valid {
+			return errors.New("Missing minDay or maxDay")
+		}
+		if r.MinDay.Float64 > r.MaxDay.Float64 || (r.MinNight.Valid && r.MaxNight.Valid && r.MinNight.Float64 > r.MaxNight.Float64) {
+			return errors.New("Min threshold is above max threshold")
+		}
+	default:
+		return fmt.Errorf("Unknown comparison %s", r.Comparison)
+	}
+	if r.Hysteresis < 0 || r.MinDuration < 0 || r.Cooldown < 0 || r.Renotify < 0 {
+		return errors.New("Hysteresis, minDuration, cooldown and renotify can't be negative")
+	}
+	return nil
+}
+
+func ruleMinMax(r db.AlertRule) getMinMaxFunc {
+	return func(t Thresholds, timerPower float64) (float64, float64) {
+		minNight, maxNight := r.MinNight, r.MaxNight
+		if !minNight.Valid {
+			minNight = r.MinDay
+		}
+		if !maxNight.Valid {
+			maxNight = r.MaxDay
+		}
+		minValue := minNight.Float64 + (r.MinDay.Float64-minNight.Float64)*timerPower/100
+		maxValue := maxNight.Float64 + (r.MaxDay.Float64-maxNight.Float64)*timerPower/100
+		return minValue, maxValue
+	}
+}
+
+func ruleAlertContent(r db.AlertRule) getAlertContentFunc {
+	return func(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (*notifications.LocalizedText, *notifications.LocalizedText) {
+		title := notifications.Loc("alert_rule_title", r.Name)
+		key, limit := "alert_rule_too_low", minValue
+		if alertType == alertTypeTooHigh {
+			key, limit = "alert_rule_too_high", maxValue
+		}
+		body := notifications.Loc(key, plant.Name, r.Metric, fmt.Sprintf("%.2f", value), fmt.Sprintf("%.2f", limit))
+		return title, body
+	}
+}
+
+func newUserAlertRule(r db.DeviceAlertRule) alertRule {
+	return alertRule{
+		Key:             fmt.Sprintf("RULE_%s", r.ID.UUID),
+		MetricName:      "RULE",
+		Pattern:         r.Metric,
+		Comparison:      r.Comparison,
+		Hysteresis:      r.Hysteresis,
+		MinDuration:     time.Duration(r.MinDuration) * time.Second,
+		Cooldown:        time.Duration(r.Cooldown) * time.Second,
+		Renotify:        time.Duration(r.Renotify) * time.Second,
+		GetMinMax:       ruleMinMax(r.AlertRule),
+		GetAlertContent: ruleAlertContent(r.AlertRule),
+		BoxID:           uuid.NullUUID{UUID: r.BoxID, Valid: true},
+		DeviceBox:       r.DeviceBox,
+	}
+}
+
+// subscribePattern - subscribes to a metric pattern once, patterns stay subscribed
+// when their rules are removed, they're just not matched anymore.
+func subscribePattern(pattern string) {
+	subscribedPatternsMutex.Lock()
+	defer subscribedPatternsMutex.Unlock()
+	if subscribedPatterns[pattern] {
+		return
+	}
+	subscribedPatterns[pattern] = true
+
+	ch := pubsub.SubscribeControllerIntMetric(fmt.Sprintf("*.%s", pattern))
+	go func() {
+		for metric := range ch {
+			metricsCh <- patternMetric{pattern: pattern, metric: metric}
+		}
+	}()
+}
+
+// ReloadRules - loads the user defined rules from the database
+func ReloadRules() {
+	rules, err := db.GetEnabledDeviceAlertRules()
+	if err != nil {
+		logrus.Errorf("db.GetEnabledDeviceAlertRules in ReloadRules %q", err)
+		return
+	}
+	byController := map[string][]alertRule{}
+	for _, r := range rules {
+		byController[r.ControllerID] = append(byController[r.ControllerID], newUserAlertRule(r))
+		subscribePattern(r.Metric)
+	}
+
+	userRulesMutex.Lock()
+	userRules = byController
+	userRulesMutex.Unlock()
+}
+
+func isSensorPattern(pattern string) bool {
+	for _, ar := range builtinRules {
+		if ar.Pattern == pattern && ar.SensorRequired {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateMetrics() {
+	for pm := range metricsCh {
+		watchdogSeen(pm.metric, isSensorPattern(pm.pattern))
+		liveEvaluator.Evaluate(pm.pattern, pm.metric)
+	}
+}
+
+func listenAlertRulesChanges() {
+	inserts := pubsub.SubscribeOject("insert.alertrules")
+	updates := pubsub.SubscribeOject("update.alertrules")
+	for {
+		select {
+		case <-inserts:
+		case <-updates:
+		}
+		ReloadRules()
+	}
+}
+
+func initRules() {
+	for _, ar := range builtinRules {
+		prometheus.InitAlertTriggered(ar.MetricName, alertTypeTooLow)
+		prometheus.InitAlertTriggered(ar.MetricName, alertTypeTooHigh)
+		subscribePattern(ar.Pattern)
+	}
+	prometheus.InitAlertTriggered(vpdRule.MetricName, alertTypeTooLow)
+	prometheus.InitAlertTriggered(vpdRule.MetricName, alertTypeTooHigh)
+	prometheus.InitAlertTriggered("RULE", alertTypeTooLow)
+	prometheus.InitAlertTriggered("RULE", alertTypeTooHigh)
+
+	ReloadRules()
+	cron.SetJob("alert_rules", "* * * * *", ReloadRules)
+
+	go listenAlertRulesChanges()
+	go evaluateMetrics()
+}
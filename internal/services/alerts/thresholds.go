@@ -0,0 +1,327 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+	udb "upper.io/db.v3"
+)
+
+const (
+	// the cache expires so auto stages follow the plants' dates
+	thresholdsCacheExpiration = 5 * time.Minute
+	seedlingDays              = 21
+)
+
+// Thresholds - day and night temperature, humidity and VPD (in kPa) ranges of a box
+type Thresholds struct {
+	MinTempDay   float64 `json:"minTempDay"`
+	MaxTempDay   float64 `json:"maxTempDay"`
+	MinTempNight float64 `json:"minTempNight"`
+	MaxTempNight float64 `json:"maxTempNight"`
+	MinHumiDay   float64 `json:"minHumiDay"`
+	MaxHumiDay   float64 `json:"maxHumiDay"`
+	MinHumiNight float64 `json:"minHumiNight"`
+	MaxHumiNight float64 `json:"maxHumiNight"`
+	MinVPDDay    float64 `json:"minVPDDay"`
+	MaxVPDDay    float64 `json:"maxVPDDay"`
+	MinVPDNight  float64 `json:"minVPDNight"`
+	MaxVPDNight  float64 `json:"maxVPDNight"`
+}
+
+func (t *Thresholds) keys() map[string]*float64 {
+	return map[string]*float64{
+		"MIN_TEMP_DAY":   &t.MinTempDay,
+		"MAX_TEMP_DAY":   &t.MaxTempDay,
+		"MIN_TEMP_NIGHT": &t.MinTempNight,
+		"MAX_TEMP_NIGHT": &t.MaxTempNight,
+		"MIN_HUMI_DAY":   &t.MinHumiDay,
+		"MAX_HUMI_DAY":   &t.MaxHumiDay,
+		"MIN_HUMI_NIGHT": &t.MinHumiNight,
+		"MAX_HUMI_NIGHT": &t.MaxHumiNight,
+		"MIN_VPD_DAY":    &t.MinVPDDay,
+		"MAX_VPD_DAY":    &t.MaxVPDDay,
+		"MIN_VPD_NIGHT":  &t.MinVPDNight,
+		"MAX_VPD_NIGHT":  &t.MaxVPDNight,
+	}
+}
+
+var (
+	// StagePresets - default thresholds for each grow stage
+	StagePresets = map[string]Thresholds{
+		db.AlertStageSeedling: {
+			MinTempDay: 20, MaxTempDay: 30, MinTempNight: 18, MaxTempNight: 26,
+			MinHumiDay: 55, MaxHumiDay: 85, MinHumiNight: 60, MaxHumiNight: 90,
+			MinVPDDay: 0.4, MaxVPDDay: 0.8, MinVPDNight: 0.3, MaxVPDNight: 0.7,
+		},
+		db.AlertStageVeg: {
+			MinTempDay: 18, MaxTempDay: 32, MinTempNight: 15, MaxTempNight: 25,
+			MinHumiDay: 25, MaxHumiDay: 75, MinHumiNight: 35, MaxHumiNight: 85,
+			MinVPDDay: 0.8, MaxVPDDay: 1.2, MinVPDNight: 0.6, MaxVPDNight: 1,
+		},
+		db.AlertStageBloom: {
+			MinTempDay: 18, MaxTempDay: 30, MinTempNight: 15, MaxTempNight: 24,
+			MinHumiDay: 25, MaxHumiDay: 60, MinHumiNight: 30, MaxHumiNight: 65,
+			MinVPDDay: 1, MaxVPDDay: 1.5, MinVPDNight: 0.8, MaxVPDNight: 1.2,
+		},
+		db.AlertStageDrying: {
+			MinTempDay: 15, MaxTempDay: 24, MinTempNight: 15, MaxTempNight: 24,
+			MinHumiDay: 45, MaxHumiDay: 65, MinHumiNight: 45, MaxHumiNight: 65,
+			MinVPDDay: 0.6, MaxVPDDay: 1, MinVPDNight: 0.6, MaxVPDNight: 1,
+		},
+	}
+
+	// stages in growing order, a box is in the most advanced stage of its plants
+	stageOrder = []string{db.AlertStageSeedling, db.AlertStageVeg, db.AlertStageBloom, db.AlertStageDrying}
+)
+
+func plantSettingDate(settings map[string]interface{}, setting string) (time.Time, bool) {
+	dateStr, ok := settings[setting].(string)
+	if !ok || dateStr == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(time.RFC3339, dateStr)
+	return date, err == nil
+}
+
+// PlantStage - grow stage of the plant from its settings dates
+func PlantStage(plant appbackend.Plant, now time.Time) string {
+	settings := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(plant.Settings), &settings); err != nil {
+		return db.AlertStageVeg
+	}
+	if date, ok := plantSettingDate(settings, "dryingStart"); ok && date.Before(now) {
+		return db.AlertStageDrying
+	}
+	if date, ok := plantSettingDate(settings, "bloomingStart"); ok && date.Before(now) {
+		return db.AlertStageBloom
+	}
+	if _, ok := plantSettingDate(settings, "veggingStart"); ok {
+		return db.AlertStageVeg
+	}
+	if date, ok := plantSettingDate(settings, "germinationDate"); ok && now.Sub(date) < seedlingDays*24*time.Hour {
+		return db.AlertStageSeedling
+	}
+	return db.AlertStageVeg
+}
+
+// BoxStage - most advanced grow stage of the box's plants, veg when empty
+func BoxStage(plants []appbackend.Plant, now time.Time) string {
+	if len(plants) == 0 {
+		return db.AlertStageVeg
+	}
+	stage := 0
+	for _, plant := range plants {
+		ps := PlantStage(plant, now)
+		for i, s := range stageOrder {
+			if s == ps && i > stage {
+				stage = i
+			}
+		}
+	}
+	return stageOrder[stage]
+}
+
+// ResolveThresholds - returns the stage and thresholds that currently apply
+func ResolveThresholds(t db.AlertThresholds, plants []appbackend.Plant) (string, Thresholds) {
+	switch t.Stage {
+	case db.AlertStageCustom:
+		// VPD targets aren't customizable, they follow the plants' stage
+		vpd := StagePresets[BoxStage(plants, time.Now())]
+		return t.Stage, Thresholds{
+			MinTempDay: t.MinTempDay.Float64, MaxTempDay: t.MaxTempDay.Float64,
+			MinTempNight: t.MinTempNight.Float64, MaxTempNight: t.MaxTempNight.Float64,
+			MinHumiDay: t.MinHumiDay.Float64, MaxHumiDay: t.MaxHumiDay.Float64,
+			MinHumiNight: t.MinHumiNight.Float64, MaxHumiNight: t.MaxHumiNight.Float64,
+			MinVPDDay: vpd.MinVPDDay, MaxVPDDay: vpd.MaxVPDDay,
+			MinVPDNight: vpd.MinVPDNight, MaxVPDNight: vpd.MaxVPDNight,
+		}
+	case db.AlertStageSeedling, db.AlertStageVeg, db.AlertStageBloom, db.AlertStageDrying:
+		return t.Stage, StagePresets[t.Stage]
+	}
+	stage := BoxStage(plants, time.Now())
+	return stage, StagePresets[stage]
+}
+
+func validateRange(name string, min, max, lower, upper float64) error {
+	if min < lower || max > upper {
+		return fmt.Errorf("%s must be between %g and %g", name, lower, upper)
+	}
+	if min >= max {
+		return fmt.Errorf("%s min must be below max", name)
+	}
+	return nil
+}
+
+// ValidateAlertThresholds - checks the stage, and the values when the stage is custom
+func ValidateAlertThresholds(t db.AlertThresholds) error {
+	switch t.Stage {
+	case db.AlertStageAuto, db.AlertStageSeedling, db.AlertStageVeg, db.AlertStageBloom, db.AlertStageDrying:
+		return nil
+	case db.AlertStageCustom:
+	default:
+		return fmt.Errorf("Unknown stage %s", t.Stage)
+	}
+	values := []struct {
+		name           string
+		min, max       float64
+		lower, upper   float64
+		minSet, maxSet bool
+	}{
+		{"Day temperature", t.MinTempDay.Float64, t.MaxTempDay.Float64, -20, 60, t.MinTempDay.Valid, t.MaxTempDay.Valid},
+		{"Night temperature", t.MinTempNight.Float64, t.MaxTempNight.Float64, -20, 60, t.MinTempNight.Valid, t.MaxTempNight.Valid},
+		{"Day humidity", t.MinHumiDay.Float64, t.MaxHumiDay.Float64, 0, 100, t.MinHumiDay.Valid, t.MaxHumiDay.Valid},
+		{"Night humidity", t.MinHumiNight.Float64, t.MaxHumiNight.Float64, 0, 100, t.MinHumiNight.Valid, t.MaxHumiNight.Valid},
+	}
+	for _, v := range values {
+		if !v.minSet || !v.maxSet {
+			return errors.New("Custom stage requires all thresholds")
+		}
+		if err := validateRange(v.name, v.min, v.max, v.lower, v.upper); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyThresholds - thresholds that used to be set directly in redis, as
+// <cid>.ALERT.BOX_<n>_<key>, they're moved to postgres when first read
+var legacyThresholds = []string{
+	"MIN_TEMP_DAY", "MAX_TEMP_DAY", "MIN_TEMP_NIGHT", "MAX_TEMP_NIGHT",
+	"MIN_HUMI_DAY", "MAX_HUMI_DAY", "MIN_HUMI_NIGHT", "MAX_HUMI_NIGHT",
+}
+
+// migrateLegacyThresholds - saves the legacy redis thresholds as the box's
+// custom thresholds and deletes them, returns false when there are none
+func migrateLegacyThresholds(controllerID string, box int, b appbackend.Box) (db.AlertThresholds, bool, error) {
+	preset := StagePresets[db.AlertStageVeg]
+	values := preset.keys()
+	found := false
+	for _, name := range legacyThresholds {
+		ok, err := kv.HasAlertThreshold(controllerID, box, name)
+		if err != nil {
+			return db.AlertThresholds{}, false, err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		if *values[name], err = kv.GetAlertThreshold(controllerID, box, name, *values[name]); err != nil {
+			return db.AlertThresholds{}, false, err
+		}
+	}
+	if !found {
+		return db.AlertThresholds{}, false, nil
+	}
+
+	t := db.AlertThresholds{
+		UserID:       b.UserID,
+		BoxID:        b.ID.UUID,
+		Stage:        db.AlertStageCustom,
+		MinTempDay:   null.FloatFrom(preset.MinTempDay),
+		MaxTempDay:   null.FloatFrom(preset.MaxTempDay),
+		MinTempNight: null.FloatFrom(preset.MinTempNight),
+		MaxTempNight: null.FloatFrom(preset.MaxTempNight),
+		MinHumiDay:   null.FloatFrom(preset.MinHumiDay),
+		MaxHumiDay:   null.FloatFrom(preset.MaxHumiDay),
+		MinHumiNight: null.FloatFrom(preset.MinHumiNight),
+		MaxHumiNight: null.FloatFrom(preset.MaxHumiNight),
+	}
+	if err := ValidateAlertThresholds(t); err != nil {
+		return t, false, err
+	}
+	t, err := db.SetAlertThresholds(t)
+	if err != nil {
+		return t, false, err
+	}
+	for _, name := range legacyThresholds {
+		if err := kv.DelAlertThreshold(controllerID, box, name); err != nil {
+			logrus.Errorf("kv.DelAlertThreshold in migrateLegacyThresholds %q - %s %d %s", err, controllerID, box, name)
+		}
+	}
+	logrus.Infof("Migrated legacy alert thresholds of %s box %d to box %s", controllerID, box, b.ID.UUID)
+	return t, true, nil
+}
+
+// loadBoxThresholds - reads the thresholds of the box on the controller's owner device
+func loadBoxThresholds(controllerID string, box int) (Thresholds, error) {
+	plants, err := db.GetActivePlantsForControllerIdentifier(controllerID, box)
+	if err != nil {
+		return Thresholds{}, err
+	}
+	t := db.AlertThresholds{Stage: db.AlertStageAuto}
+	b, err := db.GetControllerOwnerBox(controllerID, box)
+	if err != nil && err != udb.ErrNoMoreRows {
+		return Thresholds{}, err
+	} else if err == nil {
+		if t, err = db.GetAlertThresholdsForBox(b.ID.UUID); err == udb.ErrNoMoreRows {
+			var migrated bool
+			t, migrated, err = migrateLegacyThresholds(controllerID, box, b)
+			if err != nil {
+				logrus.Errorf("migrateLegacyThresholds in loadBoxThresholds %q - %s %d", err, controllerID, box)
+			}
+			if !migrated {
+				t = db.AlertThresholds{Stage: db.AlertStageAuto}
+			}
+		} else if err != nil {
+			return Thresholds{}, err
+		}
+	}
+	_, thresholds := ResolveThresholds(t, plants)
+	return thresholds, nil
+}
+
+// getBoxThresholds - reads the thresholds from postgres, cached in redis for
+// thresholdsCacheExpiration
+func getBoxThresholds(controllerID string, box int) (Thresholds, error) {
+	if cached, err := kv.GetAlertThresholds(controllerID, box); err != nil {
+		logrus.Errorf("kv.GetAlertThresholds in getBoxThresholds %q - %s %d", err, controllerID, box)
+	} else if cached != "" {
+		t := Thresholds{}
+		err := json.Unmarshal([]byte(cached), &t)
+		if err == nil {
+			return t, nil
+		}
+		logrus.Errorf("json.Unmarshal in getBoxThresholds %q - %s %d", err, controllerID, box)
+	}
+
+	t, err := loadBoxThresholds(controllerID, box)
+	if err != nil {
+		return t, err
+	}
+	if b, err := json.Marshal(t); err != nil {
+		logrus.Errorf("json.Marshal in getBoxThresholds %q - %s %d", err, controllerID, box)
+	} else if err := kv.SetAlertThresholds(controllerID, box, string(b), thresholdsCacheExpiration); err != nil {
+		logrus.Errorf("kv.SetAlertThresholds in getBoxThresholds %q - %s %d", err, controllerID, box)
+	}
+	return t, nil
+}
+
+func interpolateMinMax(minNight, minDay, maxNight, maxDay, timerPower float64) (float64, float64) {
+	return minNight + (minDay-minNight)*timerPower/100, maxNight + (maxDay-maxNight)*timerPower/100
+}
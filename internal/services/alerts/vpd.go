@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// vpd - vapour pressure deficit in kPa, from the air temperature in °C and the relative humidity
+func vpd(temp, humi float64) float64 {
+	svp := 0.61078 * math.Exp(17.27*temp/(temp+237.3))
+	return svp * (1 - humi/100)
+}
+
+func getVPDMinMax(t Thresholds, timerPower float64) (float64, float64) {
+	return interpolateMinMax(t.MinVPDNight, t.MinVPDDay, t.MaxVPDNight, t.MaxVPDDay, timerPower)
+}
+
+// position - how far the value is in its band, 0 at min and 1 at max
+func position(value, min, max float64) float64 {
+	if max <= min {
+		return 0.5
+	}
+	return (value - min) / (max - min)
+}
+
+// vpdAdvice - returns whether temperature or humidity should be adjusted to bring
+// the VPD back in its target. The one that's out of its own band is picked, or the
+// one that's the closest to the edge pushing the VPD in the wrong direction.
+func vpdAdvice(alertType string, temp, humi float64, t Thresholds, timerPower float64) string {
+	minTemp, maxTemp := interpolateMinMax(t.MinTempNight, t.MinTempDay, t.MaxTempNight, t.MaxTempDay, timerPower)
+	minHumi, maxHumi := interpolateMinMax(t.MinHumiNight, t.MinHumiDay, t.MaxHumiNight, t.MaxHumiDay, timerPower)
+	tempPos, humiPos := position(temp, minTemp, maxTemp), position(humi, minHumi, maxHumi)
+	if alertType == alertTypeTooHigh {
+		// too hot or too dry
+		if tempPos > 1 || tempPos >= 1-humiPos {
+			return "temperature"
+		}
+		return "humidity"
+	}
+	// too cold or too humid
+	if tempPos < 0 || 1-tempPos >= humiPos {
+		return "temperature"
+	}
+	return "humidity"
+}
+
+func vpdAlertContent(temp, humi float64, t Thresholds) getAlertContentFunc {
+	return func(plant appbackend.Plant, alertType string, timerPower, value, minValue, maxValue float64) (*notifications.LocalizedText, *notifications.LocalizedText) {
+		limit := minValue
+		if alertType == alertTypeTooHigh {
+			limit = maxValue
+		}
+		advice := vpdAdvice(alertType, temp, humi, t, timerPower)
+		var current interface{} = fmt.Sprintf("%d%%", int(humi))
+		if advice == "temperature" {
+			current = notifications.Temperature(temp)
+		}
+		title := notifications.Loc("alert_vpd_title")
+		body := notifications.Loc(fmt.Sprintf("alert_vpd_%s_%s", strings.ToLower(alertType), advice), plant.Name, fmt.Sprintf("%.2f", value), fmt.Sprintf("%.2f", limit), current)
+		return title, body
+	}
+}
+
+// vpdRule - VPD isn't subscribed, it's computed from the box's temperature and
+// humidity each time one of them is received.
+var vpdRule = alertRule{
+	Key:            "VPD",
+	MetricName:     "VPD",
+	Pattern:        "BOX_*_VPD",
+	Comparison:     db.AlertComparisonOutside,
+	Hysteresis:     0.1,
+	MinDuration:    10 * time.Minute,
+	GetMinMax:      getVPDMinMax,
+	SensorRequired: true,
+}
+
+// checkVPD - metric is the box's temperature or humidity, the other one is read from redis
+func (e Evaluator) checkVPD(metric pubsub.ControllerIntMetric) {
+	boxID, err := boxIDNumFromMetric(metric.Key)
+	if err != nil {
+		logrus.Errorf("boxIDNumFromMetric in checkVPD %q - %+v", err, metric)
+		return
+	}
+	var temp, humi float64
+	if metric.Key == fmt.Sprintf("BOX_%d_TEMP", boxID) {
+		temp = metric.Value
+		humi, err = e.Controllers.GetHumidity(metric.ControllerID, boxID)
+	} else {
+		humi = metric.Value
+		temp, err = e.Controllers.GetTemperature(metric.ControllerID, boxID)
+	}
+	if err != nil {
+		logrus.Errorf("e.Controllers.GetHumidity/GetTemperature in checkVPD %q - %+v", err, metric)
+		return
+	}
+	if temp == 0 || humi == 0 {
+		return
+	}
+	t, err := e.Thresholds.GetBoxThresholds(metric.ControllerID, boxID)
+	if err != nil {
+		logrus.Errorf("e.Thresholds.GetBoxThresholds in checkVPD %q - %+v", err, metric)
+		return
+	}
+
+	ar := e.override(vpdRule)
+	ar.GetAlertContent = vpdAlertContent(temp, humi, t)
+	e.checkMetric(ar, pubsub.ControllerIntMetric{
+		ControllerID: metric.ControllerID,
+		Key:          fmt.Sprintf("BOX_%d_VPD", boxID),
+		Value:        vpd(temp, humi),
+	})
+}
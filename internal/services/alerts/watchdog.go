@@ -0,0 +1,264 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	_ = pflag.String("alertswatchdogtimeout", "15", "Minutes without data before a controller or sensor is reported offline")
+)
+
+func init() {
+	viper.SetDefault("AlertsWatchdogTimeout", "15")
+}
+
+const (
+	alertTypeOffline   = "OFFLINE"
+	alertTypeStale     = "STALE"
+	alertTypeRecovered = "RECOVERED"
+
+	// watchdogHeartbeatPattern - published by every controller, with or without sensor
+	watchdogHeartbeatPattern = "BOX_*_TIMER_OUTPUT"
+	// watchdogWriteInterval - last seen dates are written to redis at most once per interval
+	watchdogWriteInterval = time.Minute
+	watchdogLock          = "alerts_watchdog"
+)
+
+var (
+	watchdogWritten      = map[string]time.Time{}
+	watchdogWrittenMutex sync.Mutex
+)
+
+// watchdogShouldWrite - metrics are published every few seconds, the last seen
+// dates are only needed at the minute
+func watchdogShouldWrite(key string, now time.Time) bool {
+	watchdogWrittenMutex.Lock()
+	defer watchdogWrittenMutex.Unlock()
+	if now.Sub(watchdogWritten[key]) < watchdogWriteInterval {
+		return false
+	}
+	watchdogWritten[key] = now
+	return true
+}
+
+// watchdogSeen - records that the controller published a metric, sensorMetric is true
+// for metrics coming from the box's sensors (BOX_<n>_TEMP, BOX_<n>_HUMI).
+func watchdogSeen(metric pubsub.ControllerIntMetric, sensorMetric bool) {
+	now := time.Now()
+	controllerID := metric.ControllerID
+
+	if watchdogShouldWrite(controllerID, now) {
+		if err := kv.SetControllerSeen(controllerID, now); err != nil {
+			logrus.Errorf("kv.SetControllerSeen in watchdogSeen %q - controllerID: %s", err, controllerID)
+		}
+		recovered, err := kv.SetControllerOnline(controllerID)
+		if err != nil {
+			logrus.Errorf("kv.SetControllerOnline in watchdogSeen %q - controllerID: %s", err, controllerID)
+		}
+		if recovered {
+			boxes := watchdogBoxes(controllerID)
+			// the sensors' timeout restarts with the controller
+			for _, box := range boxes {
+				if err := kv.SetSensorSeen(controllerID, box, now); err != nil {
+					logrus.Errorf("kv.SetSensorSeen in watchdogSeen %q - controllerID: %s box: %d", err, controllerID, box)
+				}
+				if _, err := kv.SetSensorFresh(controllerID, box); err != nil {
+					logrus.Errorf("kv.SetSensorFresh in watchdogSeen %q - controllerID: %s box: %d", err, controllerID, box)
+				}
+			}
+			notifyWatchdog(controllerID, boxes, "CONTROLLER", alertTypeRecovered, "alert_controller_recovered")
+		}
+	}
+
+	if !sensorMetric || !strings.HasPrefix(metric.Key, "BOX_") {
+		return
+	}
+	box, err := boxIDNumFromMetric(metric.Key)
+	if err != nil || !watchdogShouldWrite(fmt.Sprintf("%s.%d", controllerID, box), now) {
+		return
+	}
+	if err := kv.SetSensorSeen(controllerID, box, now); err != nil {
+		logrus.Errorf("kv.SetSensorSeen in watchdogSeen %q - controllerID: %s box: %d", err, controllerID, box)
+	}
+	recovered, err := kv.SetSensorFresh(controllerID, box)
+	if err != nil {
+		logrus.Errorf("kv.SetSensorFresh in watchdogSeen %q - controllerID: %s box: %d", err, controllerID, box)
+	}
+	if recovered {
+		notifyWatchdog(controllerID, []int{box}, "SENSOR", alertTypeRecovered, "alert_sensor_recovered")
+	}
+}
+
+// watchdogBoxes - the controller's enabled boxes, and the boxes whose sensor was seen
+func watchdogBoxes(controllerID string) []int {
+	boxes := map[int]bool{}
+	if topology, err := devices.GetTopology(controllerID); err != nil {
+		logrus.Errorf("devices.GetTopology in watchdogBoxes %q - controllerID: %s", err, controllerID)
+	} else {
+		for _, b := range topology.Boxes {
+			if b.Enabled {
+				boxes[b.Index] = true
+			}
+		}
+	}
+	if seen, err := kv.GetSensorsSeen(controllerID); err != nil {
+		logrus.Errorf("kv.GetSensorsSeen in watchdogBoxes %q - controllerID: %s", err, controllerID)
+	} else {
+		for box := range seen {
+			boxes[box] = true
+		}
+	}
+	res := []int{}
+	for box := range boxes {
+		res = append(res, box)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// notifyWatchdog - contentKey is the catalog key prefix of the notification's title and body
+func notifyWatchdog(controllerID string, boxes []int, metricName, alertType, contentKey string) {
+	logrus.Infof("Watchdog %s %s: controller %s boxes %v", metricName, alertType, controllerID, boxes)
+	prometheus.AlertTriggered(metricName, alertType)
+	for _, box := range boxes {
+		plants, err := db.GetActivePlantsForControllerIdentifier(controllerID, box)
+		if err != nil {
+			logrus.Errorf("db.GetActivePlantsForControllerIdentifier in notifyWatchdog %q - controllerID: %s box: %d", err, controllerID, box)
+			continue
+		}
+		for _, plant := range plants {
+			if plant.AlertsEnabled == false {
+				continue
+			}
+			title := notifications.Loc(contentKey + "_title")
+			body := notifications.Loc(contentKey+"_body", plant.Name)
+			data, notif := NewNotificationDataAlert(title, body, "", plant.ID.UUID)
+			notifications.SendNotificationToUser(plant.UserID, data, &notif)
+		}
+	}
+}
+
+// checkStaleSensors - boxes of an online controller whose sensor stopped publishing
+func checkStaleSensors(controllerID string, now time.Time, timeout time.Duration) {
+	seen, err := kv.GetSensorsSeen(controllerID)
+	if err != nil {
+		logrus.Errorf("kv.GetSensorsSeen in checkStaleSensors %q - controllerID: %s", err, controllerID)
+		return
+	}
+	boxes := []int{}
+	for box, lastSeen := range seen {
+		if now.Sub(lastSeen) <= timeout {
+			continue
+		}
+		// a sensor that was unplugged on purpose or a disabled box are not stale
+		if enabled, err := kv.GetBoxEnabled(controllerID, box); err != nil || !enabled {
+			continue
+		}
+		if present, err := kv.GetSHT21PresentForBox(controllerID, box); err != nil || !present {
+			continue
+		}
+		stale, err := kv.SetSensorStale(controllerID, box)
+		if err != nil {
+			logrus.Errorf("kv.SetSensorStale in checkStaleSensors %q - controllerID: %s box: %d", err, controllerID, box)
+			continue
+		}
+		if stale {
+			boxes = append(boxes, box)
+		}
+	}
+	if len(boxes) == 0 {
+		return
+	}
+	sort.Ints(boxes)
+	notifyWatchdog(controllerID, boxes, "SENSOR", alertTypeStale, "alert_sensor_stale")
+}
+
+// checkWatchdog - last seen dates are kept in redis, so silent controllers are
+// still detected after a restart, and only the instance holding the lock checks them
+func checkWatchdog() {
+	timeout := time.Duration(viper.GetInt("AlertsWatchdogTimeout")) * time.Minute
+	now := time.Now()
+
+	locked, err := kv.TryLock(watchdogLock, 50*time.Second)
+	if err != nil {
+		logrus.Errorf("kv.TryLock in checkWatchdog %q", err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	if err := kv.PruneControllersSeen(now); err != nil {
+		logrus.Errorf("kv.PruneControllersSeen in checkWatchdog %q", err)
+	}
+
+	silent, err := kv.GetControllersSeenBetween(time.Unix(0, 0), now.Add(-timeout))
+	if err != nil {
+		logrus.Errorf("kv.GetControllersSeenBetween in checkWatchdog %q", err)
+		return
+	}
+	for _, controllerID := range silent {
+		offline, err := kv.SetControllerOffline(controllerID)
+		if err != nil {
+			logrus.Errorf("kv.SetControllerOffline in checkWatchdog %q - controllerID: %s", err, controllerID)
+			continue
+		}
+		if offline {
+			notifyWatchdog(controllerID, watchdogBoxes(controllerID), "CONTROLLER", alertTypeOffline, "alert_controller_offline")
+		}
+	}
+
+	online, err := kv.GetControllersSeenBetween(now.Add(-timeout), now)
+	if err != nil {
+		logrus.Errorf("kv.GetControllersSeenBetween in checkWatchdog %q", err)
+		return
+	}
+	for _, controllerID := range online {
+		checkStaleSensors(controllerID, now, timeout)
+	}
+}
+
+func initWatchdog() {
+	for _, metricName := range []string{"CONTROLLER", "SENSOR"} {
+		prometheus.InitAlertTriggered(metricName, alertTypeRecovered)
+	}
+	prometheus.InitAlertTriggered("CONTROLLER", alertTypeOffline)
+	prometheus.InitAlertTriggered("SENSOR", alertTypeStale)
+
+	subscribePattern(watchdogHeartbeatPattern)
+
+	cron.SetJob("alerts_watchdog", "* * * * *", checkWatchdog)
+}
@@ -23,8 +23,8 @@ import (
 	"time"
 
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
-	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
-	"github.com/SuperGreenLab/AppBackend/internal/data/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
 	"github.com/sirupsen/logrus"
 )
@@ -58,10 +58,13 @@ func cardMetricsProcess() {
 				continue
 			}
 
-			if sht21Present, err := kv.GetSHT21PresentForBox(device.Identifier, int(*box.DeviceBox)); !sht21Present || err != nil {
-				if err != nil {
-					logrus.Errorf("getSensorPresentForBox in cardMetricsProcess %q - box: %+v device: %+v", err, box, device)
-				}
+			topology, err := devices.GetTopology(device.Identifier)
+			if err != nil {
+				logrus.Errorf("devices.GetTopology in cardMetricsProcess %q - box: %+v device: %+v", err, box, device)
+				time.Sleep(1 * time.Second)
+				continue
+			}
+			if boxTopology, ok := topology.Box(int(*box.DeviceBox)); !ok || !boxTopology.SensorPresent {
 				time.Sleep(1 * time.Second)
 				continue
 			}
@@ -69,9 +72,7 @@ func cardMetricsProcess() {
 			from := t.Add(-36 * time.Hour)
 			to := t.Add(36 * time.Hour)
 			meta := appbackend.FeedEntryMeta{
-				MetricsMeta: appbackend.LoadMetricsMeta(device, box, from, to, prometheus.LoadTimeSeries, func(i int) (int, error) {
-					return kv.GetLedBox(device.Identifier, i)
-				}),
+				MetricsMeta: appbackend.LoadMetricsMeta(device, box, from, to, timeseries.LoadTimeSeries, topology),
 			}
 
 			j, err := json.Marshal(meta)
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import (
+	"encoding/json"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CommandAck - sent back by the controller once the command was applied or refused
+type CommandAck struct {
+	ID           uuid.UUID `json:"id"`
+	ControllerID string    `json:"-"`
+	OK           bool      `json:"ok"`
+	Error        string    `json:"error"`
+}
+
+// CommandChannel - delivers commands to the controllers and reports their acks
+type CommandChannel interface {
+	// Send - returns false when the controller isn't connected to receive it
+	Send(cmd db.DeviceCommand) (bool, error)
+	Acks() <-chan CommandAck
+}
+
+type redisCommand struct {
+	ID    uuid.UUID `json:"id"`
+	Key   string    `json:"key"`
+	Value string    `json:"value"`
+}
+
+// redisChannel - publishes {id, key, value} on cmd.<controllerID>, controllers
+// answer with {id, ok, error} on ack.<controllerID>
+type redisChannel struct{}
+
+func (redisChannel) Send(cmd db.DeviceCommand) (bool, error) {
+	b, err := json.Marshal(redisCommand{ID: cmd.ID.UUID, Key: cmd.Key, Value: cmd.Value})
+	if err != nil {
+		return false, err
+	}
+	n, err := pubsub.PublishControllerCommand(cmd.ControllerID, b)
+	return n != 0, err
+}
+
+func (redisChannel) Acks() <-chan CommandAck {
+	ch := make(chan CommandAck, 100)
+	go func() {
+		for msg := range pubsub.SubscribeControllerCommandAcks() {
+			ack := CommandAck{}
+			if err := json.Unmarshal(msg.Payload, &ack); err != nil {
+				logrus.Errorf("json.Unmarshal in redisChannel.Acks %q - %s", err, string(msg.Payload))
+				continue
+			}
+			ack.ControllerID = msg.ControllerID
+			ch <- ack
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+var channels = map[string]func() CommandChannel{
+	"redis": func() CommandChannel { return redisChannel{} },
+}
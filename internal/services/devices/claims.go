@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	claimCodeLength     = 8
+	claimCodeExpiration = 10 * time.Minute
+	// no 0/O or 1/I, the code is read from the controller's screen or local API
+	claimCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var ErrControllerNotConnected = errors.New("Controller is not connected")
+
+func newClaimCode() (string, error) {
+	code := make([]byte, claimCodeLength)
+	max := big.NewInt(int64(len(claimCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = claimCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// IssueClaimCode - the code is never returned to the caller, it's only sent to
+// the controller, so only someone with access to the controller can claim it.
+func IssueClaimCode(controllerID string) error {
+	code, err := newClaimCode()
+	if err != nil {
+		return err
+	}
+	if err := kv.SetClaimCode(controllerID, code, claimCodeExpiration); err != nil {
+		return err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	cmd := db.DeviceCommand{
+		ID:           uuid.NullUUID{UUID: id, Valid: true},
+		ControllerID: controllerID,
+		Key:          "CLAIM_CODE",
+		Value:        code,
+	}
+	delivered, err := channel.Send(cmd)
+	if err != nil {
+		return err
+	}
+	if !delivered {
+		return ErrControllerNotConnected
+	}
+	return nil
+}
+
+// CheckClaimCode - the code is consumed by the first attempt, right or wrong
+func CheckClaimCode(controllerID, code string) (bool, error) {
+	expected, err := kv.TakeClaimCode(controllerID)
+	if err != nil || expected == "" {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1, nil
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	commandAckTimeout = 5 * time.Minute
+	// commandPendingTimeout - how long commands wait for their controller to reconnect
+	commandPendingTimeout = 24 * time.Hour
+)
+
+var (
+	channel CommandChannel
+
+	ErrUnknownParam = errors.New("Unknown or read-only param")
+
+	_ = pflag.String("commandchannel", "redis", "Channel used to deliver commands to the controllers (redis)")
+)
+
+func init() {
+	viper.SetDefault("CommandChannel", "redis")
+}
+
+// InvalidParamError - the value doesn't match the param's schema
+type InvalidParamError struct {
+	err error
+}
+
+func (e InvalidParamError) Error() string {
+	return e.err.Error()
+}
+
+// NewCommand - validates the key and value against the device's firmware schema
+func NewCommand(device appbackend.Device, userID uuid.UUID, key string, value interface{}) (db.DeviceCommand, error) {
+	cmd := db.DeviceCommand{
+		UserID:       userID,
+		DeviceID:     device.ID.UUID,
+		ControllerID: device.Identifier,
+		Key:          key,
+		Status:       db.DeviceCommandStatusPending,
+	}
+	firmware, err := kv.GetFirmwareTimestamp(device.Identifier)
+	if err != nil {
+		return cmd, err
+	}
+	cmd.Firmware = firmware
+	param, ok := SchemaForFirmware(firmware).Param(key)
+	if !ok {
+		return cmd, ErrUnknownParam
+	}
+	cmd.Value, err = param.Validate(value)
+	if err != nil {
+		return cmd, InvalidParamError{err}
+	}
+	return cmd, nil
+}
+
+// QueueCommand - stores the command and hands it to the command channel,
+// the command then waits for the controller's ack. Commands for controllers
+// that aren't connected stay pending until they're resent.
+func QueueCommand(cmd db.DeviceCommand) (db.DeviceCommand, error) {
+	id, err := db.CreateDeviceCommand(cmd)
+	if err != nil {
+		return cmd, err
+	}
+	cmd.ID = uuid.NullUUID{UUID: id, Valid: true}
+	return sendCommand(cmd)
+}
+
+// sendCommand - the command is marked sent before it's published so the
+// controller's ack always finds it waiting
+func sendCommand(cmd db.DeviceCommand) (db.DeviceCommand, error) {
+	now := time.Now()
+	ok, err := db.SetDeviceCommandSent(cmd.ID.UUID, now)
+	if err != nil || !ok {
+		return cmd, err
+	}
+
+	delivered, err := channel.Send(cmd)
+	if err != nil {
+		cmd.Status = db.DeviceCommandStatusFailed
+		cmd.Error = null.StringFrom(err.Error())
+		if err := db.SetDeviceCommandFailed(cmd.ID.UUID, err.Error()); err != nil {
+			logrus.Errorf("db.SetDeviceCommandFailed in sendCommand %q - %+v", err, cmd)
+		}
+		return cmd, err
+	}
+	if !delivered {
+		if err := db.SetDeviceCommandPending(cmd.ID.UUID); err != nil {
+			return cmd, err
+		}
+		cmd.Status = db.DeviceCommandStatusPending
+		return cmd, nil
+	}
+	cmd.Status = db.DeviceCommandStatusSent
+	cmd.SentAt = null.TimeFrom(now)
+	return cmd, nil
+}
+
+// resendPendingCommands - commands queued while their controller was
+// disconnected are delivered once it's listening again
+func resendPendingCommands() {
+	cmds, err := db.GetPendingDeviceCommands(time.Now().Add(-commandPendingTimeout))
+	if err != nil {
+		logrus.Errorf("db.GetPendingDeviceCommands in resendPendingCommands %q", err)
+		return
+	}
+	offline := map[string]bool{}
+	for _, cmd := range cmds {
+		// keeps the commands' order, the next ones wait for the controller
+		if offline[cmd.ControllerID] {
+			continue
+		}
+		cmd, err := sendCommand(cmd)
+		if err != nil {
+			logrus.Errorf("sendCommand in resendPendingCommands %q - %+v", err, cmd)
+			continue
+		}
+		if cmd.Status == db.DeviceCommandStatusPending {
+			offline[cmd.ControllerID] = true
+		}
+	}
+}
+
+func listenAcks() {
+	for ack := range channel.Acks() {
+		status := db.DeviceCommandStatusAcked
+		errorMsg := null.String{}
+		if !ack.OK {
+			status = db.DeviceCommandStatusFailed
+			errorMsg = null.StringFrom(ack.Error)
+		}
+		ok, err := db.AckDeviceCommand(ack.ID, ack.ControllerID, status, errorMsg, time.Now())
+		if err != nil {
+			logrus.Errorf("db.AckDeviceCommand in listenAcks %q - %+v", err, ack)
+			continue
+		}
+		if !ok {
+			logrus.Warningf("Ack for unknown or expired command - %+v", ack)
+		}
+	}
+}
+
+func expireCommands() {
+	now := time.Now()
+	if _, err := db.ExpireDeviceCommands(now.Add(-commandAckTimeout), now.Add(-commandPendingTimeout)); err != nil {
+		logrus.Errorf("db.ExpireDeviceCommands in expireCommands %q", err)
+	}
+}
+
+func Init() {
+	newChannel, ok := channels[viper.GetString("CommandChannel")]
+	if !ok {
+		logrus.Fatalf("Unknown command channel %s", viper.GetString("CommandChannel"))
+	}
+	channel = newChannel()
+	go listenAcks()
+	go listenTopologyChanges()
+
+	cron.SetJob("devicecommands_expire", "* * * * *", expireCommands)
+	cron.SetJob("devicecommands_resend", "* * * * *", resendPendingCommands)
+}
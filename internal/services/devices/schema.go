@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type ParamType string
+
+const (
+	ParamTypeInt    ParamType = "int"
+	ParamTypeString ParamType = "string"
+)
+
+// Param - a writable controller key, Values restricts int params to an enum
+type Param struct {
+	Type      ParamType `json:"type"`
+	Min       int       `json:"min,omitempty"`
+	Max       int       `json:"max,omitempty"`
+	Values    []int     `json:"values,omitempty"`
+	MaxLength int       `json:"maxLength,omitempty"`
+}
+
+// FirmwareSchema - writable params of the firmwares built after Since (OTA_TIMESTAMP),
+// keys are patterns where * is a box or led index.
+type FirmwareSchema struct {
+	Since  int64            `json:"since"`
+	Params map[string]Param `json:"params"`
+}
+
+var (
+	percent = Param{Type: ParamTypeInt, Min: 0, Max: 100}
+	hour    = Param{Type: ParamTypeInt, Min: 0, Max: 23}
+	minute  = Param{Type: ParamTypeInt, Min: 0, Max: 59}
+	onOff   = Param{Type: ParamTypeInt, Values: []int{0, 1}}
+
+	// firmwareSchemas - sorted by Since
+	firmwareSchemas = []FirmwareSchema{
+		{
+			Since: 0,
+			Params: map[string]Param{
+				"DEVICE_NAME": {Type: ParamTypeString, MaxLength: 64},
+
+				"BOX_*_ENABLED":      onOff,
+				"BOX_*_TIMER_TYPE":   {Type: ParamTypeInt, Values: []int{0, 1, 2}},
+				"BOX_*_ON_HOUR":      hour,
+				"BOX_*_ON_MIN":       minute,
+				"BOX_*_OFF_HOUR":     hour,
+				"BOX_*_OFF_MIN":      minute,
+				"BOX_*_STRETCH":      percent,
+				"BOX_*_BLOWER_DAY":   percent,
+				"BOX_*_BLOWER_NIGHT": percent,
+
+				"LED_*_DIM": percent,
+				"LED_*_BOX": {Type: ParamTypeInt, Min: 0, Max: 2},
+			},
+		},
+	}
+
+	paramPatterns = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	for _, s := range firmwareSchemas {
+		for k := range s.Params {
+			paramPatterns[k] = regexp.MustCompile(fmt.Sprintf("^%s$", strings.Replace(regexp.QuoteMeta(k), `\*`, "[0-9]+", -1)))
+		}
+	}
+}
+
+// SchemaForFirmware - latest schema the firmware was built after
+func SchemaForFirmware(timestamp int64) FirmwareSchema {
+	schema := firmwareSchemas[0]
+	for _, s := range firmwareSchemas {
+		if s.Since <= timestamp {
+			schema = s
+		}
+	}
+	return schema
+}
+
+// Param - returns the param matching the key
+func (s FirmwareSchema) Param(key string) (Param, bool) {
+	for k, p := range s.Params {
+		if paramPatterns[k].MatchString(key) {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Validate - returns the value as it will be sent to the controller, value
+// is either a JSON number or a string.
+func (p Param) Validate(value interface{}) (string, error) {
+	switch p.Type {
+	case ParamTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("Expected a string, got %v", value)
+		}
+		if p.MaxLength != 0 && len(s) > p.MaxLength {
+			return "", fmt.Errorf("Value is longer than %d characters", p.MaxLength)
+		}
+		return s, nil
+	case ParamTypeInt:
+		var n int
+		switch v := value.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				return "", fmt.Errorf("Expected an integer, got %v", v)
+			}
+			n = int(v)
+		case string:
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return "", fmt.Errorf("Expected an integer, got %q", v)
+			}
+			n = i
+		default:
+			return "", fmt.Errorf("Expected an integer, got %v", value)
+		}
+		if len(p.Values) != 0 {
+			for _, v := range p.Values {
+				if v == n {
+					return strconv.Itoa(n), nil
+				}
+			}
+			return "", fmt.Errorf("Value %d should be one of %v", n, p.Values)
+		}
+		if n < p.Min || n > p.Max {
+			return "", fmt.Errorf("Value %d should be between %d and %d", n, p.Min, p.Max)
+		}
+		return strconv.Itoa(n), nil
+	}
+	return "", fmt.Errorf("Unknown param type %s", p.Type)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import "testing"
+
+func TestSchemaParam(t *testing.T) {
+	schema := SchemaForFirmware(0)
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"DEVICE_NAME", true},
+		{"BOX_0_ENABLED", true},
+		{"BOX_2_ON_HOUR", true},
+		{"LED_3_DIM", true},
+		{"LED_10_DIM", true},
+		{"LED__DIM", false},
+		{"LED_A_DIM", false},
+		{"XLED_1_DIM", false},
+		{"LED_1_DIMX", false},
+		{"BOX_0_TEMP", false},
+		{"WIFI_PASSWORD", false},
+	}
+	for _, tt := range tests {
+		if _, ok := schema.Param(tt.key); ok != tt.want {
+			t.Errorf("Param(%q) = %v, want %v", tt.key, ok, tt.want)
+		}
+	}
+}
+
+func TestParamValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   Param
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"percent", percent, float64(42), "42", false},
+		{"percent as string", percent, "42", "42", false},
+		{"percent above max", percent, float64(101), "", true},
+		{"percent below min", percent, float64(-1), "", true},
+		{"percent not an integer", percent, 4.2, "", true},
+		{"percent not a number", percent, "abc", "", true},
+		{"percent wrong type", percent, true, "", true},
+		{"enum value", onOff, float64(1), "1", false},
+		{"enum unknown value", onOff, float64(2), "", true},
+		{"string", Param{Type: ParamTypeString, MaxLength: 5}, "hello", "hello", false},
+		{"string too long", Param{Type: ParamTypeString, MaxLength: 5}, "hello!", "", true},
+		{"string wrong type", Param{Type: ParamTypeString, MaxLength: 5}, float64(1), "", true},
+		{"unknown type", Param{Type: "float"}, float64(1), "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.param.Validate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate(%v) error = %v, wantErr %v", tt.name, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Validate(%v) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaForFirmware(t *testing.T) {
+	tests := []struct {
+		timestamp int64
+		want      int64
+	}{
+		{0, 0},
+		{1600000000, 0},
+	}
+	for _, tt := range tests {
+		if got := SchemaForFirmware(tt.timestamp).Since; got != tt.want {
+			t.Errorf("SchemaForFirmware(%d).Since = %d, want %d", tt.timestamp, got, tt.want)
+		}
+	}
+}
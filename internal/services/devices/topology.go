@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package devices
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
+	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+)
+
+// topologyTTL - the cache is invalidated when a topology key changes, this is
+// only a safety net for missed updates
+const topologyTTL = time.Hour
+
+var (
+	topologies      = map[string]appbackend.DeviceTopology{}
+	topologiesMutex sync.RWMutex
+
+	topologyKeyPatterns = []string{"BOX_*_ENABLED", "BOX_*_TEMP_SOURCE", "BOX_*_BLOWER_DAY", "LED_*_BOX", "SHT21_*_PRESENT"}
+)
+
+// GetTopology - returns the controller's cached topology, loads it from redis when missing or stale
+func GetTopology(controllerID string) (appbackend.DeviceTopology, error) {
+	now := time.Now()
+	topologiesMutex.RLock()
+	t, ok := topologies[controllerID]
+	topologiesMutex.RUnlock()
+	if ok && now.Sub(t.LoadedAt) < topologyTTL {
+		return t, nil
+	}
+
+	values, err := kv.GetControllerValues(controllerID, appbackend.TopologyKeys())
+	if err != nil {
+		return t, err
+	}
+	t = appbackend.NewDeviceTopology(controllerID, values, now)
+
+	topologiesMutex.Lock()
+	topologies[controllerID] = t
+	topologiesMutex.Unlock()
+	return t, nil
+}
+
+func listenTopologyChanges() {
+	ch := pubsub.SubscribeControllerKeys(context.Background(), "*", topologyKeyPatterns)
+	for kv := range ch {
+		topologiesMutex.Lock()
+		delete(topologies, kv.ControllerID)
+		topologiesMutex.Unlock()
+	}
+}
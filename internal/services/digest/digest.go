@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/mailer"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	digestTypeDaily  = "daily"
+	digestTypeWeekly = "weekly"
+)
+
+type digestContent struct {
+	Plants    []db.DigestPlantEntries
+	NEntries  int
+	NLikes    int
+	NComments int
+	NFollows  int
+}
+
+func (dc digestContent) empty() bool {
+	return dc.NEntries == 0 && dc.NLikes == 0 && dc.NComments == 0 && dc.NFollows == 0
+}
+
+func loadDigestContent(user db.User, from, to time.Time) (digestContent, error) {
+	var err error
+	dc := digestContent{}
+	dc.Plants, err = db.GetFollowedPlantsEntriesCount(user.ID.UUID, from, to)
+	if err != nil {
+		return dc, err
+	}
+	for _, p := range dc.Plants {
+		dc.NEntries += p.N
+	}
+	dc.NLikes, err = db.GetLikesReceivedCount(user.ID.UUID, from, to)
+	if err != nil {
+		return dc, err
+	}
+	dc.NComments, err = db.GetCommentsReceivedCount(user.ID.UUID, from, to)
+	if err != nil {
+		return dc, err
+	}
+	dc.NFollows, err = db.GetNewFollowersCount(user.ID.UUID, from, to)
+	return dc, err
+}
+
+// digestNotificationContent - the title's key is passed to the app, the body
+// is rendered in the user's locale as its parts depend on the digest's content
+func digestNotificationContent(user db.User, dtype string, dc digestContent) (*notifications.LocalizedText, *notifications.LocalizedText) {
+	title := notifications.Loc("digest_daily_title")
+	if dtype == digestTypeWeekly {
+		title = notifications.Loc("digest_weekly_title")
+	}
+	parts := []string{}
+	if dc.NEntries != 0 {
+		parts = append(parts, notifications.Loc("digest_entries", dc.NEntries, len(dc.Plants)).Render(user.Locale, user.Units))
+	}
+	if dc.NLikes != 0 {
+		parts = append(parts, notifications.Loc("digest_likes", dc.NLikes).Render(user.Locale, user.Units))
+	}
+	if dc.NComments != 0 {
+		parts = append(parts, notifications.Loc("digest_comments", dc.NComments).Render(user.Locale, user.Units))
+	}
+	if dc.NFollows != 0 {
+		parts = append(parts, notifications.Loc("digest_follows", dc.NFollows).Render(user.Locale, user.Units))
+	}
+	return title, notifications.Text(strings.Join(parts, ", "))
+}
+
+func digestMailContent(user db.User, title, body string, dc digestContent) string {
+	lines := []string{
+		notifications.Loc("digest_mail_greeting", user.Nickname).Render(user.Locale, user.Units),
+		"",
+		fmt.Sprintf("%s: %s.", title, body),
+	}
+	if len(dc.Plants) != 0 {
+		lines = append(lines, "", notifications.Loc("digest_mail_followed_plants").Render(user.Locale, user.Units))
+		for _, p := range dc.Plants {
+			lines = append(lines, notifications.Loc("digest_mail_plant_entries", p.PlantName, p.N).Render(user.Locale, user.Units))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sendDigest(user db.User, dtype string, from, to time.Time) error {
+	dc, err := loadDigestContent(user, from, to)
+	if err != nil {
+		return err
+	}
+	if dc.empty() {
+		return nil
+	}
+
+	created, err := db.CreateDigest(user.ID.UUID, dtype, from)
+	if err != nil {
+		return err
+	}
+	if !created {
+		logrus.Infof("Digest %s already sent for user %s period %s", dtype, user.ID.UUID, from)
+		return nil
+	}
+
+	titleLoc, bodyLoc := digestNotificationContent(user, dtype, dc)
+	title, body := titleLoc.Render(user.Locale, user.Units), bodyLoc.Render(user.Locale, user.Units)
+	if mailer.Enabled() && user.Email.Valid && user.Email.String != "" {
+		if err := mailer.SendMail(user.Email.String, title, digestMailContent(user, title, body, dc)); err != nil {
+			// the digest is retried on the job's next run
+			if err2 := db.DeleteDigest(user.ID.UUID, dtype, from); err2 != nil {
+				logrus.Errorf("db.DeleteDigest in sendDigest %q - user: %s", err2, user.ID.UUID)
+			}
+			return err
+		}
+	}
+
+	data, notif := NewNotificationDataDigest(titleLoc, bodyLoc, "", dtype, from.Unix(), to.Unix())
+	notifications.SendNotificationToUser(user.ID.UUID, data, &notif)
+	return nil
+}
+
+func digestJob(dtype string, period time.Duration) func() {
+	return func() {
+		users, err := db.GetUsersForDigest(dtype)
+		if err != nil {
+			logrus.Errorf("db.GetUsersForDigest in digestJob %q - dtype: %s", err, dtype)
+			return
+		}
+
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		from := to.Add(-period)
+
+		for _, user := range users {
+			if err := sendDigest(user, dtype, from, to); err != nil {
+				logrus.Errorf("sendDigest in digestJob %q - user: %s dtype: %s", err, user.ID.UUID, dtype)
+			}
+		}
+	}
+}
+
+func Init() {
+	prometheus.InitNotificationSent(NotificationTypeDigest)
+
+	// runs hourly over the day, digests already sent are skipped, failed ones
+	// are retried
+	cron.SetJob("digest_daily", "0 9-23 * * *", digestJob(digestTypeDaily, 24*time.Hour))
+	cron.SetJob("digest_weekly", "0 9-23 * * sun", digestJob(digestTypeWeekly, 7*24*time.Hour))
+}
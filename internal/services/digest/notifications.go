@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package digest
+
+import (
+	"fmt"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+)
+
+var (
+	NotificationTypeDigest = "DIGEST"
+)
+
+type NotificationDataDigest struct {
+	notifications.NotificationBaseData
+
+	DigestType string `json:"digestType"`
+	From       int64  `json:"from"`
+	To         int64  `json:"to"`
+}
+
+func (n NotificationDataDigest) ToMap() map[string]string {
+	m := n.NotificationBaseData.ToMap()
+	return n.Merge(m, map[string]string{
+		"digestType": n.DigestType,
+		"from":       fmt.Sprintf("%d", n.From),
+		"to":         fmt.Sprintf("%d", n.To),
+	})
+}
+
+func NewNotificationDataDigest(title, body *notifications.LocalizedText, imageUrl, digestType string, from, to int64) (NotificationDataDigest, messaging.Notification) {
+	data := NotificationDataDigest{
+		NotificationBaseData: notifications.NewLocalizedBaseData(NotificationTypeDigest, title, body),
+		DigestType:           digestType,
+		From:                 from,
+		To:                   to,
+	}
+	return data, messaging.Notification{
+		Title:    data.Title,
+		Body:     data.Body,
+		ImageURL: imageUrl,
+	}
+}
@@ -28,10 +28,10 @@ import (
 	"time"
 
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
-	"github.com/SuperGreenLab/AppBackend/internal/data/kv"
-	"github.com/SuperGreenLab/AppBackend/internal/data/prometheus"
 	"github.com/SuperGreenLab/AppBackend/internal/data/storage"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
 	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
 	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
 	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
 	"github.com/bwmarrin/discordgo"
@@ -158,11 +158,13 @@ func listenFeedMediasAdded() {
 			device = &d
 		}
 
+		var topology appbackend.DeviceTopology
 		if device != nil {
-			if sht21Present, err := kv.GetSHT21PresentForBox(device.Identifier, int(*box.DeviceBox)); !sht21Present || err != nil {
-				if err != nil {
-					logrus.Errorf("getSensorPresentForBox in listenFeedMediasAdded %q - device: %+v box: %+v", err, device, box)
-				}
+			topology, err = devices.GetTopology(device.Identifier)
+			if err != nil {
+				logrus.Errorf("devices.GetTopology in listenFeedMediasAdded %q - device: %+v box: %+v", err, device, box)
+				device = nil
+			} else if boxTopology, ok := topology.Box(int(*box.DeviceBox)); !ok || !boxTopology.SensorPresent {
 				device = nil
 			}
 		}
@@ -179,9 +181,7 @@ func listenFeedMediasAdded() {
 			t := time.Now()
 			from := t.Add(-24 * time.Hour)
 			to := t
-			meta = appbackend.LoadMetricsMeta(*device, box, from, to, prometheus.LoadTimeSeries, func(i int) (int, error) {
-				return kv.GetLedBox(device.Identifier, i)
-			})
+			meta = appbackend.LoadMetricsMeta(*device, box, from, to, timeseries.LoadTimeSeries, topology)
 		} else {
 			meta = appbackend.MetricsMeta{Date: fe.CreatedAt}
 		}
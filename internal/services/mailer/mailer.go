@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	_ = pflag.String("smtphost", "", "SMTP server host, emails are disabled when empty")
+	_ = pflag.String("smtpport", "587", "SMTP server port")
+	_ = pflag.String("smtpuser", "", "SMTP user")
+	_ = pflag.String("smtppassword", "", "SMTP password")
+	_ = pflag.String("mailfrom", "", "Sender address for outgoing emails")
+)
+
+func init() {
+	viper.SetDefault("SMTPHost", "")
+	viper.SetDefault("SMTPPort", "587")
+	viper.SetDefault("SMTPUser", "")
+	viper.SetDefault("SMTPPassword", "")
+	viper.SetDefault("MailFrom", "")
+}
+
+// Enabled - returns true when a SMTP server is configured
+func Enabled() bool {
+	return viper.GetString("SMTPHost") != "" && viper.GetString("MailFrom") != ""
+}
+
+// SendMail - sends a plain text email
+func SendMail(to, subject, body string) error {
+	if !Enabled() {
+		return fmt.Errorf("Mailer not configured")
+	}
+	host := viper.GetString("SMTPHost")
+	from := viper.GetString("MailFrom")
+
+	var auth smtp.Auth
+	if viper.GetString("SMTPUser") != "" {
+		auth = smtp.PlainAuth("", viper.GetString("SMTPUser"), viper.GetString("SMTPPassword"), host)
+	}
+
+	msg := strings.Join([]string{
+		fmt.Sprintf("From: %s", from),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(fmt.Sprintf("%s:%s", host, viper.GetString("SMTPPort")), auth, from, []string{to}, []byte(msg))
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package metricsarchive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/data/timeseries"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	"github.com/sirupsen/logrus"
+)
+
+// boxMetrics - names of the box's archived metrics, with its LED channels' dimming
+func boxMetrics(box db.ArchivedBox) ([]string, error) {
+	names := []string{}
+	for _, m := range []string{"TEMP", "HUMI", "VPD", "TIMER_OUTPUT", "BLOWER_DUTY"} {
+		names = append(names, fmt.Sprintf("BOX_%d_%s", box.DeviceBox, m))
+	}
+	topology, err := devices.GetTopology(box.ControllerID)
+	if err != nil {
+		return names, err
+	}
+	if boxTopology, ok := topology.Box(box.DeviceBox); ok {
+		for _, led := range boxTopology.Leds {
+			names = append(names, fmt.Sprintf("LED_%d_DIM", led))
+		}
+	}
+	return names, nil
+}
+
+// archiveMetric - archives the complete hours since the last archived one
+func archiveMetric(box db.ArchivedBox, name string, now time.Time) error {
+	end := now.Truncate(time.Hour)
+	// metrics that were never archived, or missed runs, are archived as far
+	// back as the time-series backend keeps them
+	start := end.Add(-timeseries.Retention)
+	latest, err := db.GetLatestMetricArchiveHour(box.ControllerID, name)
+	if err != nil {
+		return err
+	}
+	if latest.Add(time.Hour).After(start) {
+		start = latest.Add(time.Hour)
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	series := timeseries.Series{ControllerID: box.ControllerID, Name: name}
+	step := int64(time.Hour.Seconds())
+	// a downsampled point at t aggregates the hour ending at t
+	from, to := start.Add(time.Hour).Unix(), end.Unix()
+	aggregates := map[string]map[int64]float64{}
+	for _, fn := range []string{timeseries.AggregationAvg, timeseries.AggregationMin, timeseries.AggregationMax} {
+		ts, err := timeseries.Live.Downsample(series, from, to, step, fn)
+		if err != nil {
+			return err
+		}
+		aggregates[fn] = map[int64]float64{}
+		for _, v := range ts {
+			aggregates[fn][int64(v[0])] = v[1]
+		}
+	}
+
+	for t := from; t <= to; t += step {
+		avg, ok := aggregates[timeseries.AggregationAvg][t]
+		if !ok {
+			continue
+		}
+		a := db.MetricArchive{
+			ControllerID: box.ControllerID,
+			Name:         name,
+			Hour:         time.Unix(t-step, 0),
+			BoxID:        box.BoxID,
+			Avg:          avg,
+			Min:          aggregates[timeseries.AggregationMin][t],
+			Max:          aggregates[timeseries.AggregationMax][t],
+		}
+		if err := db.CreateMetricArchive(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archiveMetrics() {
+	now := time.Now()
+	boxes, err := db.GetBoxesForMetricsArchive()
+	if err != nil {
+		logrus.Errorf("db.GetBoxesForMetricsArchive in archiveMetrics %q", err)
+		return
+	}
+	for _, box := range boxes {
+		names, err := boxMetrics(box)
+		if err != nil {
+			logrus.Errorf("boxMetrics in archiveMetrics %q - %+v", err, box)
+		}
+		for _, name := range names {
+			if err := archiveMetric(box, name, now); err != nil {
+				logrus.Errorf("archiveMetric in archiveMetrics %q - box: %+v name: %s", err, box, name)
+			}
+		}
+	}
+}
+
+func Init() {
+	cron.SetJob("metricsarchive", "5 * * * *", archiveMetrics)
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+// catalog - notification templates per locale, arguments are positional so
+// translations can reorder them, keys match the app's localization keys.
+var catalog = map[string]map[string]string{
+	"en": {
+		"comment_title":         "%[1]s posted a message on your diary %[2]s!",
+		"comment_reply_title":   "%[1]s replied to your comment on the diary %[2]s!",
+		"comment_mention_title": "%[1]s mentioned you in a comment on the diary %[2]s!",
+		"like_comment_title":    "%[1]s liked your comment on the diary %[2]s!",
+		"like_comment_body":     "Tap to view comment",
+		"like_feedentry_title":  "%[1]s liked your growlog on the diary %[2]s!",
+		"like_feedentry_body":   "Tap to view growlog",
+
+		"alert_temperature_title":          "Temperature alert",
+		"alert_temperature_too_high_day":   "Your plant %[1]s is too hot\nIt's currently at %[2]s, try to keep it below %[3]s during the day.",
+		"alert_temperature_too_high_night": "Your plant %[1]s is too hot\nIt's currently at %[2]s, try to keep it below %[3]s during the night.",
+		"alert_temperature_too_low_day":    "Your plant %[1]s is too cold\nIt's currently at %[2]s, try to keep it above %[3]s during the day.",
+		"alert_temperature_too_low_night":  "Your plant %[1]s is too cold\nIt's currently at %[2]s, try to keep it above %[3]s during the night.",
+		"alert_humidity_title":             "Humidity alert",
+		"alert_humidity_too_high_day":      "Your plant %[1]s is too humid\nIt's currently at %[2]s, try to keep it below %[3]s during the day.",
+		"alert_humidity_too_high_night":    "Your plant %[1]s is too humid\nIt's currently at %[2]s, try to keep it below %[3]s during the night.",
+		"alert_humidity_too_low_day":       "Your plant %[1]s is too dry\nIt's currently at %[2]s, try to keep it above %[3]s during the day.",
+		"alert_humidity_too_low_night":     "Your plant %[1]s is too dry\nIt's currently at %[2]s, try to keep it above %[3]s during the night.",
+		"alert_rule_title":                 "%[1]s alert",
+		"alert_rule_too_high":              "Your plant %[1]s: %[2]s is at %[3]s, above %[4]s",
+		"alert_rule_too_low":               "Your plant %[1]s: %[2]s is at %[3]s, below %[4]s",
+		"alert_vpd_title":                  "VPD alert",
+		"alert_vpd_too_high_temperature":   "Your plant %[1]s's VPD is too high at %[2]s kPa, try to keep it below %[3]s kPa.\nIt's %[4]s, lower the temperature.",
+		"alert_vpd_too_high_humidity":      "Your plant %[1]s's VPD is too high at %[2]s kPa, try to keep it below %[3]s kPa.\nHumidity is at %[4]s, raise the humidity.",
+		"alert_vpd_too_low_temperature":    "Your plant %[1]s's VPD is too low at %[2]s kPa, try to keep it above %[3]s kPa.\nIt's %[4]s, raise the temperature.",
+		"alert_vpd_too_low_humidity":       "Your plant %[1]s's VPD is too low at %[2]s kPa, try to keep it above %[3]s kPa.\nHumidity is at %[4]s, lower the humidity.",
+
+		"alert_controller_offline_title":   "Controller offline",
+		"alert_controller_offline_body":    "Your plant %[1]s's controller stopped sending data, check its power and wifi connection.",
+		"alert_controller_recovered_title": "Controller back online",
+		"alert_controller_recovered_body":  "Your plant %[1]s's controller is sending data again.",
+		"alert_sensor_stale_title":         "Sensor offline",
+		"alert_sensor_stale_body":          "Your plant %[1]s's temperature/humidity sensor stopped sending data, check its cable.",
+		"alert_sensor_recovered_title":     "Sensor back online",
+		"alert_sensor_recovered_body":      "Your plant %[1]s's temperature/humidity sensor is sending data again.",
+
+		"digest_daily_title":          "Your diaries today",
+		"digest_weekly_title":         "Your diaries this week",
+		"digest_entries":              "%[1]s new entries on %[2]s followed plants",
+		"digest_likes":                "%[1]s likes",
+		"digest_comments":             "%[1]s comments",
+		"digest_follows":              "%[1]s new followers",
+		"digest_mail_greeting":        "Hi %[1]s,",
+		"digest_mail_followed_plants": "Followed plants:",
+		"digest_mail_plant_entries":   "- %[1]s: %[2]s new entries",
+	},
+	"fr": {
+		"comment_title":         "%[1]s a posté un message sur ton journal %[2]s !",
+		"comment_reply_title":   "%[1]s a répondu à ton commentaire sur le journal %[2]s !",
+		"comment_mention_title": "%[1]s t'a mentionné dans un commentaire sur le journal %[2]s !",
+		"like_comment_title":    "%[1]s a aimé ton commentaire sur le journal %[2]s !",
+		"like_comment_body":     "Appuie pour voir le commentaire",
+		"like_feedentry_title":  "%[1]s a aimé ton growlog sur le journal %[2]s !",
+		"like_feedentry_body":   "Appuie pour voir le growlog",
+
+		"alert_temperature_title":          "Alerte température",
+		"alert_temperature_too_high_day":   "Ta plante %[1]s a trop chaud\nIl fait actuellement %[2]s, essaie de rester sous %[3]s pendant la journée.",
+		"alert_temperature_too_high_night": "Ta plante %[1]s a trop chaud\nIl fait actuellement %[2]s, essaie de rester sous %[3]s pendant la nuit.",
+		"alert_temperature_too_low_day":    "Ta plante %[1]s a trop froid\nIl fait actuellement %[2]s, essaie de rester au-dessus de %[3]s pendant la journée.",
+		"alert_temperature_too_low_night":  "Ta plante %[1]s a trop froid\nIl fait actuellement %[2]s, essaie de rester au-dessus de %[3]s pendant la nuit.",
+		"alert_humidity_title":             "Alerte humidité",
+		"alert_humidity_too_high_day":      "L'air de ta plante %[1]s est trop humide\nIl est actuellement à %[2]s, essaie de rester sous %[3]s pendant la journée.",
+		"alert_humidity_too_high_night":    "L'air de ta plante %[1]s est trop humide\nIl est actuellement à %[2]s, essaie de rester sous %[3]s pendant la nuit.",
+		"alert_humidity_too_low_day":       "L'air de ta plante %[1]s est trop sec\nIl est actuellement à %[2]s, essaie de rester au-dessus de %[3]s pendant la journée.",
+		"alert_humidity_too_low_night":     "L'air de ta plante %[1]s est trop sec\nIl est actuellement à %[2]s, essaie de rester au-dessus de %[3]s pendant la nuit.",
+		"alert_rule_title":                 "Alerte %[1]s",
+		"alert_rule_too_high":              "Ta plante %[1]s : %[2]s est à %[3]s, au-dessus de %[4]s",
+		"alert_rule_too_low":               "Ta plante %[1]s : %[2]s est à %[3]s, en dessous de %[4]s",
+		"alert_vpd_title":                  "Alerte VPD",
+		"alert_vpd_too_high_temperature":   "Le VPD de ta plante %[1]s est trop haut à %[2]s kPa, essaie de rester sous %[3]s kPa.\nIl fait %[4]s, baisse la température.",
+		"alert_vpd_too_high_humidity":      "Le VPD de ta plante %[1]s est trop haut à %[2]s kPa, essaie de rester sous %[3]s kPa.\nL'humidité est à %[4]s, augmente l'humidité.",
+		"alert_vpd_too_low_temperature":    "Le VPD de ta plante %[1]s est trop bas à %[2]s kPa, essaie de rester au-dessus de %[3]s kPa.\nIl fait %[4]s, augmente la température.",
+		"alert_vpd_too_low_humidity":       "Le VPD de ta plante %[1]s est trop bas à %[2]s kPa, essaie de rester au-dessus de %[3]s kPa.\nL'humidité est à %[4]s, baisse l'humidité.",
+
+		"alert_controller_offline_title":   "Contrôleur hors ligne",
+		"alert_controller_offline_body":    "Le contrôleur de ta plante %[1]s n'envoie plus de données, vérifie son alimentation et sa connexion wifi.",
+		"alert_controller_recovered_title": "Contrôleur de retour en ligne",
+		"alert_controller_recovered_body":  "Le contrôleur de ta plante %[1]s envoie de nouveau des données.",
+		"alert_sensor_stale_title":         "Capteur hors ligne",
+		"alert_sensor_stale_body":          "Le capteur de température/humidité de ta plante %[1]s n'envoie plus de données, vérifie son câble.",
+		"alert_sensor_recovered_title":     "Capteur de retour en ligne",
+		"alert_sensor_recovered_body":      "Le capteur de température/humidité de ta plante %[1]s envoie de nouveau des données.",
+
+		"digest_daily_title":          "Tes journaux aujourd'hui",
+		"digest_weekly_title":         "Tes journaux cette semaine",
+		"digest_entries":              "%[1]s nouvelles entrées sur %[2]s plantes suivies",
+		"digest_likes":                "%[1]s likes",
+		"digest_comments":             "%[1]s commentaires",
+		"digest_follows":              "%[1]s nouveaux abonnés",
+		"digest_mail_greeting":        "Salut %[1]s,",
+		"digest_mail_followed_plants": "Plantes suivies :",
+		"digest_mail_plant_entries":   "- %[1]s : %[2]s nouvelles entrées",
+	},
+	"es": {
+		"comment_title":         "¡%[1]s publicó un mensaje en tu diario %[2]s!",
+		"comment_reply_title":   "¡%[1]s respondió a tu comentario en el diario %[2]s!",
+		"comment_mention_title": "¡%[1]s te mencionó en un comentario en el diario %[2]s!",
+		"like_comment_title":    "¡A %[1]s le gustó tu comentario en el diario %[2]s!",
+		"like_comment_body":     "Toca para ver el comentario",
+		"like_feedentry_title":  "¡A %[1]s le gustó tu growlog en el diario %[2]s!",
+		"like_feedentry_body":   "Toca para ver el growlog",
+
+		"alert_temperature_title":          "Alerta de temperatura",
+		"alert_temperature_too_high_day":   "Tu planta %[1]s tiene demasiado calor\nAhora está a %[2]s, intenta mantenerla por debajo de %[3]s durante el día.",
+		"alert_temperature_too_high_night": "Tu planta %[1]s tiene demasiado calor\nAhora está a %[2]s, intenta mantenerla por debajo de %[3]s durante la noche.",
+		"alert_temperature_too_low_day":    "Tu planta %[1]s tiene demasiado frío\nAhora está a %[2]s, intenta mantenerla por encima de %[3]s durante el día.",
+		"alert_temperature_too_low_night":  "Tu planta %[1]s tiene demasiado frío\nAhora está a %[2]s, intenta mantenerla por encima de %[3]s durante la noche.",
+		"alert_humidity_title":             "Alerta de humedad",
+		"alert_humidity_too_high_day":      "Tu planta %[1]s está demasiado húmeda\nAhora está a %[2]s, intenta mantenerla por debajo de %[3]s durante el día.",
+		"alert_humidity_too_high_night":    "Tu planta %[1]s está demasiado húmeda\nAhora está a %[2]s, intenta mantenerla por debajo de %[3]s durante la noche.",
+		"alert_humidity_too_low_day":       "Tu planta %[1]s está demasiado seca\nAhora está a %[2]s, intenta mantenerla por encima de %[3]s durante el día.",
+		"alert_humidity_too_low_night":     "Tu planta %[1]s está demasiado seca\nAhora está a %[2]s, intenta mantenerla por encima de %[3]s durante la noche.",
+		"alert_rule_title":                 "Alerta %[1]s",
+		"alert_rule_too_high":              "Tu planta %[1]s: %[2]s está a %[3]s, por encima de %[4]s",
+		"alert_rule_too_low":               "Tu planta %[1]s: %[2]s está a %[3]s, por debajo de %[4]s",
+		"alert_vpd_title":                  "Alerta de VPD",
+		"alert_vpd_too_high_temperature":   "El VPD de tu planta %[1]s es demasiado alto, %[2]s kPa, intenta mantenerlo por debajo de %[3]s kPa.\nHace %[4]s, baja la temperatura.",
+		"alert_vpd_too_high_humidity":      "El VPD de tu planta %[1]s es demasiado alto, %[2]s kPa, intenta mantenerlo por debajo de %[3]s kPa.\nLa humedad está a %[4]s, sube la humedad.",
+		"alert_vpd_too_low_temperature":    "El VPD de tu planta %[1]s es demasiado bajo, %[2]s kPa, intenta mantenerlo por encima de %[3]s kPa.\nHace %[4]s, sube la temperatura.",
+		"alert_vpd_too_low_humidity":       "El VPD de tu planta %[1]s es demasiado bajo, %[2]s kPa, intenta mantenerlo por encima de %[3]s kPa.\nLa humedad está a %[4]s, baja la humedad.",
+
+		"alert_controller_offline_title":   "Controlador desconectado",
+		"alert_controller_offline_body":    "El controlador de tu planta %[1]s dejó de enviar datos, revisa su alimentación y su conexión wifi.",
+		"alert_controller_recovered_title": "Controlador conectado de nuevo",
+		"alert_controller_recovered_body":  "El controlador de tu planta %[1]s vuelve a enviar datos.",
+		"alert_sensor_stale_title":         "Sensor desconectado",
+		"alert_sensor_stale_body":          "El sensor de temperatura/humedad de tu planta %[1]s dejó de enviar datos, revisa su cable.",
+		"alert_sensor_recovered_title":     "Sensor conectado de nuevo",
+		"alert_sensor_recovered_body":      "El sensor de temperatura/humedad de tu planta %[1]s vuelve a enviar datos.",
+
+		"digest_daily_title":          "Tus diarios hoy",
+		"digest_weekly_title":         "Tus diarios esta semana",
+		"digest_entries":              "%[1]s nuevas entradas en %[2]s plantas seguidas",
+		"digest_likes":                "%[1]s me gusta",
+		"digest_comments":             "%[1]s comentarios",
+		"digest_follows":              "%[1]s nuevos seguidores",
+		"digest_mail_greeting":        "Hola %[1]s,",
+		"digest_mail_followed_plants": "Plantas seguidas:",
+		"digest_mail_plant_entries":   "- %[1]s: %[2]s nuevas entradas",
+	},
+}
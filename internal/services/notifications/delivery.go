@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxDeliveryAttempts = 4
+	deliveryBackoff     = 2 * time.Second
+)
+
+type deliveryRetry struct {
+	name      string
+	transport Transport
+	un        UserNotification
+	tokens    []string
+	attempt   int
+}
+
+var retryCh chan deliveryRetry
+
+// deliver - sends un to tokens, prunes unregistered tokens and requeues a retry with
+// exponential backoff for the tokens that failed with a transient error.
+func deliver(name string, transport Transport, un UserNotification, tokens []string, attempt int) {
+	ntype := un.data.GetType()
+	retry := []string{}
+
+	results, err := transport.Send(tokens, un.payload, un.notification)
+	if err != nil {
+		logrus.Errorf("transport.Send in deliver %q - %s attempt: %d %+v", err, name, attempt, un)
+		retry = tokens
+	}
+	for _, res := range results {
+		switch {
+		case res.Err == nil:
+			prometheus.NotificationDelivery(ntype, name, "delivered")
+		case res.Unregistered:
+			prometheus.NotificationDelivery(ntype, name, "pruned")
+			logrus.Infof("Pruning unregistered %s token %s", name, res.Token)
+			if err := db.ClearUserEndNotificationToken(name, res.Token); err != nil {
+				logrus.Errorf("db.ClearUserEndNotificationToken in deliver %q - %s", err, name)
+			}
+		case res.Transient:
+			retry = append(retry, res.Token)
+		default:
+			prometheus.NotificationDelivery(ntype, name, "failed")
+			logrus.Errorf("transport.Send in deliver %q - %s token: %s", res.Err, name, res.Token)
+		}
+	}
+
+	if len(retry) == 0 {
+		return
+	}
+	if attempt >= maxDeliveryAttempts {
+		prometheus.NotificationError(ntype)
+		for range retry {
+			prometheus.NotificationDelivery(ntype, name, "failed")
+		}
+		logrus.Errorf("Giving up notification delivery through %s after %d attempts - %+v", name, attempt, un)
+		return
+	}
+	for range retry {
+		prometheus.NotificationDelivery(ntype, name, "retried")
+	}
+	backoff := deliveryBackoff * time.Duration(1<<uint(attempt-1))
+	time.AfterFunc(backoff, func() {
+		retryCh <- deliveryRetry{name: name, transport: transport, un: un, tokens: retry, attempt: attempt + 1}
+	})
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"context"
+	"errors"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"google.golang.org/api/option"
+)
+
+var (
+	_ = pflag.String("fcmconfigpath", "/etc/appbackend/fcmconfig.json", "Path to the firebase credentials file")
+)
+
+type fcmTransport struct {
+	cli *messaging.Client
+}
+
+func newFCMTransport() (Transport, error) {
+	ctx := context.Background()
+	config := &firebase.Config{ProjectID: "supergreenlab-6cd05"}
+	opt := option.WithCredentialsFile(viper.GetString("FCMConfigPath"))
+	app, err := firebase.NewApp(ctx, config, opt)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fcmTransport{cli: cli}, nil
+}
+
+func (t fcmTransport) Send(tokens []string, data map[string]string, notification *messaging.Notification) ([]SendResult, error) {
+	// the notification is rendered in the user's locale, the loc keys are only
+	// passed in the data payload, the app doesn't ship the native loc strings
+	msg := &messaging.MulticastMessage{Data: data, Notification: notification, Tokens: tokens}
+	br, err := t.cli.SendMulticast(context.Background(), msg)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SendResult, len(tokens))
+	for i, token := range tokens {
+		results[i].Token = token
+		if i >= len(br.Responses) {
+			results[i].Err = errors.New("Missing response for token")
+			results[i].Transient = true
+			continue
+		}
+		if err := br.Responses[i].Error; err != nil {
+			results[i].Err = err
+			results[i].Unregistered = messaging.IsRegistrationTokenNotRegistered(err) || messaging.IsUnregistered(err)
+			results[i].Transient = messaging.IsUnavailable(err) || messaging.IsServerUnavailable(err) || messaging.IsInternal(err) || messaging.IsMessageRateExceeded(err) || messaging.IsQuotaExceeded(err)
+		}
+	}
+	return results, nil
+}
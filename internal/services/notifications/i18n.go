@@ -0,0 +1,182 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	DefaultLocale = "en"
+
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// Temperature - celsius value, rendered in the recipient's units
+type Temperature float64
+
+func (t Temperature) render(units string) string {
+	if units == UnitsImperial {
+		return fmt.Sprintf("%d°F", int(float64(t)*9/5+32))
+	}
+	return fmt.Sprintf("%d°C", int(t))
+}
+
+// LocalizedText - message catalog key and its arguments, an empty key means the
+// first argument is sent as is, ie. for user provided texts.
+type LocalizedText struct {
+	Key  string
+	Args []interface{}
+}
+
+// Loc -
+func Loc(key string, args ...interface{}) *LocalizedText {
+	return &LocalizedText{Key: key, Args: args}
+}
+
+// Text - text that's not translated
+func Text(text string) *LocalizedText {
+	return &LocalizedText{Args: []interface{}{text}}
+}
+
+// NormalizeLocale - the catalog's locale for a language tag, ie. fr for fr-FR,
+// empty when the language isn't known
+func NormalizeLocale(locale string) string {
+	if _, ok := catalog[locale]; ok {
+		return locale
+	}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		if _, ok := catalog[strings.ToLower(locale[:i])]; ok {
+			return strings.ToLower(locale[:i])
+		}
+	}
+	if _, ok := catalog[strings.ToLower(locale)]; ok {
+		return strings.ToLower(locale)
+	}
+	return ""
+}
+
+// IsKnownLocale -
+func IsKnownLocale(locale string) bool {
+	return NormalizeLocale(locale) != ""
+}
+
+// IsKnownUnits -
+func IsKnownUnits(units string) bool {
+	return units == UnitsMetric || units == UnitsImperial
+}
+
+func catalogFor(locale string) map[string]string {
+	if messages, ok := catalog[NormalizeLocale(locale)]; ok {
+		return messages
+	}
+	return catalog[DefaultLocale]
+}
+
+// args - string arguments, as sent in the loc-args of the payload
+func (l LocalizedText) args(units string) []string {
+	args := make([]string, len(l.Args))
+	for i, arg := range l.Args {
+		switch a := arg.(type) {
+		case Temperature:
+			args[i] = a.render(units)
+		case string:
+			args[i] = a
+		default:
+			args[i] = fmt.Sprint(a)
+		}
+	}
+	return args
+}
+
+// Render - formats the text with the locale's template
+func (l LocalizedText) Render(locale, units string) string {
+	args := l.args(units)
+	if l.Key == "" {
+		return strings.Join(args, "")
+	}
+	tpl, ok := catalogFor(locale)[l.Key]
+	if !ok {
+		tpl, ok = catalog[DefaultLocale][l.Key]
+	}
+	if !ok {
+		return l.Key
+	}
+	fargs := make([]interface{}, len(args))
+	for i, a := range args {
+		fargs[i] = a
+	}
+	return fmt.Sprintf(tpl, fargs...)
+}
+
+// localize - renders the notification in the user's locale and units, the
+// catalog keys and arguments are added to the payload for the app to render
+// the text itself.
+func localize(userID uuid.UUID, data NotificationData, notification *messaging.Notification) (map[string]string, *messaging.Notification, error) {
+	payload := data.ToMap()
+	title, body := data.GetLocalization()
+	if title == nil && body == nil {
+		return payload, notification, nil
+	}
+	user, err := db.GetUser(userID)
+	if err != nil {
+		return payload, notification, err
+	}
+	locale, units := user.Locale, user.Units
+	if locale == "" {
+		locale = DefaultLocale
+	}
+
+	n := messaging.Notification{}
+	if notification != nil {
+		n = *notification
+	}
+	if title != nil {
+		payload["title"] = title.Render(locale, units)
+		n.Title = payload["title"]
+		if title.Key != "" {
+			args, err := json.Marshal(title.args(units))
+			if err != nil {
+				return payload, &n, err
+			}
+			payload["title-loc-key"] = title.Key
+			payload["title-loc-args"] = string(args)
+		}
+	}
+	if body != nil {
+		payload["body"] = body.Render(locale, units)
+		n.Body = payload["body"]
+		if body.Key != "" {
+			args, err := json.Marshal(body.args(units))
+			if err != nil {
+				return payload, &n, err
+			}
+			payload["loc-key"] = body.Key
+			payload["loc-args"] = string(args)
+		}
+	}
+	return payload, &n, nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import "testing"
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"fr", "fr"},
+		{"fr-FR", "fr"},
+		{"es_ES", "es"},
+		{"EN", "en"},
+		{"de-DE", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLocale(tt.locale); got != tt.want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+		if got := IsKnownLocale(tt.locale); got != (tt.want != "") {
+			t.Errorf("IsKnownLocale(%q) = %v", tt.locale, got)
+		}
+	}
+}
+
+func TestRenderFallsBackToBaseLanguage(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"fr-FR", "Tes journaux aujourd'hui"},
+		{"es", "Tus diarios hoy"},
+		{"de", "Your diaries today"},
+		{"", "Your diaries today"},
+	}
+	for _, tt := range tests {
+		if got := Loc("digest_daily_title").Render(tt.locale, UnitsMetric); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
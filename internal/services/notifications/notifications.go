@@ -19,10 +19,10 @@
 package notifications
 
 import (
-	"context"
 	"log"
+	"strings"
+	"time"
 
-	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
 	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
@@ -30,24 +30,39 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"google.golang.org/api/option"
 )
 
 var (
-	client *firebase.App
-	ch     chan UserNotification
-	_      = pflag.String("fcmconfigpath", "/etc/appbackend/fcmconfig.json", "Url to the redis instance")
+	ch chan UserNotification
+	_  = pflag.String("notificationtransports", "fcm", "Comma separated list of enabled notification transports (fcm, webhook, webpush, sink)")
 )
 
 type NotificationData interface {
 	ToMap() map[string]string
 	GetType() string
+	GetLocalization() (*LocalizedText, *LocalizedText)
 }
 
+// NotificationBaseData - Title and Body are replaced by TitleLoc and BodyLoc
+// rendered in the recipient's locale when they're set.
 type NotificationBaseData struct {
 	Type  string `json:"type"`
 	Title string `json:"title"`
 	Body  string `json:"body"`
+
+	TitleLoc *LocalizedText `json:"-"`
+	BodyLoc  *LocalizedText `json:"-"`
+}
+
+// NewLocalizedBaseData - base data with the default locale's texts as Title and Body
+func NewLocalizedBaseData(ntype string, title, body *LocalizedText) NotificationBaseData {
+	return NotificationBaseData{
+		Type:     ntype,
+		Title:    title.Render(DefaultLocale, UnitsMetric),
+		Body:     body.Render(DefaultLocale, UnitsMetric),
+		TitleLoc: title,
+		BodyLoc:  body,
+	}
 }
 
 func (n NotificationBaseData) Merge(a map[string]string, b map[string]string) map[string]string {
@@ -61,6 +76,10 @@ func (n NotificationBaseData) GetType() string {
 	return n.Type
 }
 
+func (n NotificationBaseData) GetLocalization() (*LocalizedText, *LocalizedText) {
+	return n.TitleLoc, n.BodyLoc
+}
+
 func (n NotificationBaseData) ToMap() map[string]string {
 	return map[string]string{
 		"type":  n.Type,
@@ -73,56 +92,95 @@ type UserNotification struct {
 	userID       uuid.UUID
 	data         NotificationData
 	notification *messaging.Notification
+
+	payload map[string]string
 }
 
-func handleUserNotifications() {
-	for un := range ch {
-		userends, err := db.GetUserEndsForUserID(un.userID)
-		if err != nil {
-			logrus.Errorf("db.GetUserEndsForUserID in handleUserNotifications %q - %+v", err, un)
-			return
+func handleUserNotification(un UserNotification) {
+	userends, err := db.GetUserEndsForUserID(un.userID)
+	if err != nil {
+		logrus.Errorf("db.GetUserEndsForUserID in handleUserNotification %q - %+v", err, un)
+		return
+	}
+	payload, notification, err := localize(un.userID, un.data, un.notification)
+	if err != nil {
+		logrus.Errorf("localize in handleUserNotification %q - %+v", err, un)
+	}
+	un.payload, un.notification = payload, notification
+	tokensMap := map[string]map[string]bool{}
+	for _, userend := range userends {
+		if !userend.NotificationToken.Valid || userend.NotificationToken.String == "" {
+			continue
 		}
-		cli, err := client.Messaging(context.Background())
-		if err != nil {
-			logrus.Errorf("client.Messaging in handleUserNotifications %q", err)
-			return
+		name := userend.NotificationTransport
+		if name == "" {
+			name = DefaultTransport
 		}
-		tokensMap := map[string]bool{}
-		for _, userend := range userends {
-			if userend.NotificationToken.Valid && userend.NotificationToken.String != "" {
-				tokensMap[userend.NotificationToken.String] = true
-			}
+		if _, ok := tokensMap[name]; !ok {
+			tokensMap[name] = map[string]bool{}
+		}
+		tokensMap[name][userend.NotificationToken.String] = true
+	}
+	for name, tm := range tokensMap {
+		transport, ok := transports[name]
+		if !ok {
+			logrus.Warningf("Notification transport %s not enabled, skipping %d tokens", name, len(tm))
+			continue
 		}
 		tokens := []string{}
-		for k := range tokensMap {
+		for k := range tm {
 			tokens = append(tokens, k)
 		}
-		if len(tokens) > 0 {
-			logrus.Infof("Sending notification to %q\n", tokens)
-			msg := &messaging.MulticastMessage{Data: un.data.ToMap(), Notification: un.notification, Tokens: tokens}
-			prometheus.NotificationSent(un.data.GetType())
-			if _, err := cli.SendMulticast(context.Background(), msg); err != nil {
-				prometheus.NotificationError(un.data.GetType())
-				logrus.Errorf("cli.SendMulticast in handleUserNotifications %q - %+v", err, un)
-			}
+		logrus.Infof("Sending notification through %s to %q\n", name, tokens)
+		prometheus.NotificationSent(un.data.GetType())
+		deliver(name, transport, un, tokens, 1)
+	}
+}
+
+// handleUserNotifications - sends the queued notifications, and the retries of
+// failed deliveries, so that all transport calls happen in the supervised worker
+func handleUserNotifications() {
+	for {
+		select {
+		case un := <-ch:
+			handleUserNotification(un)
+		case r := <-retryCh:
+			deliver(r.name, r.transport, r.un, r.tokens, r.attempt)
 		}
 	}
 }
 
+// superviseUserNotifications - restarts the notification worker if it panics
+func superviseUserNotifications() {
+	for {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logrus.Errorf("handleUserNotifications panicked %v, restarting", r)
+				}
+			}()
+			handleUserNotifications()
+		}()
+		time.Sleep(time.Second)
+	}
+}
+
 func SendNotificationToUser(userID uuid.UUID, data NotificationData, notification *messaging.Notification) {
-	ch <- UserNotification{userID, data, notification}
+	ch <- UserNotification{userID: userID, data: data, notification: notification}
 }
 
 func Init() {
-	var err error
-	ctx := context.Background()
-	config := &firebase.Config{ProjectID: "supergreenlab-6cd05"}
-	opt := option.WithCredentialsFile(viper.GetString("FCMConfigPath"))
-	client, err = firebase.NewApp(ctx, config, opt)
-	if err != nil {
-		log.Fatalf("firebase.NewApp in Init %q", err)
+	for _, name := range strings.Split(viper.GetString("NotificationTransports"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := initTransport(name); err != nil {
+			log.Fatalf("initTransport in Init %q - %s", err, name)
+		}
 	}
 
 	ch = make(chan UserNotification, 100)
-	go handleUserNotifications()
+	retryCh = make(chan deliveryRetry, 100)
+	go superviseUserNotifications()
 }
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const sinkMaxMessages = 1000
+
+var (
+	_ = pflag.String("notificationsinkpath", "", "File the sink transport appends notifications to (one json object per line), memory only if empty")
+)
+
+// SinkMessage - notification captured by the sink transport
+type SinkMessage struct {
+	Tokens       []string                `json:"tokens"`
+	Data         map[string]string       `json:"data"`
+	Notification *messaging.Notification `json:"notification,omitempty"`
+	Date         time.Time               `json:"date"`
+}
+
+type sinkTransport struct {
+	path string
+}
+
+var (
+	sinkMessages      = []SinkMessage{}
+	sinkMessagesMutex sync.Mutex
+)
+
+func newSinkTransport() (Transport, error) {
+	return sinkTransport{path: viper.GetString("NotificationSinkPath")}, nil
+}
+
+func (t sinkTransport) Send(tokens []string, data map[string]string, notification *messaging.Notification) ([]SendResult, error) {
+	msg := SinkMessage{Tokens: tokens, Data: data, Notification: notification, Date: time.Now()}
+
+	sinkMessagesMutex.Lock()
+	defer sinkMessagesMutex.Unlock()
+
+	sinkMessages = append(sinkMessages, msg)
+	if len(sinkMessages) > sinkMaxMessages {
+		sinkMessages = sinkMessages[len(sinkMessages)-sinkMaxMessages:]
+	}
+
+	if t.path == "" {
+		return successResults(tokens), nil
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(msg); err != nil {
+		return nil, err
+	}
+	return successResults(tokens), nil
+}
+
+// GetSinkMessages - returns the notifications captured by the sink transport, oldest first
+func GetSinkMessages() []SinkMessage {
+	sinkMessagesMutex.Lock()
+	defer sinkMessagesMutex.Unlock()
+	return append([]SinkMessage{}, sinkMessages...)
+}
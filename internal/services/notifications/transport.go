@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"fmt"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+const (
+	// DefaultTransport - transport used for userends that don't specify one
+	DefaultTransport = "fcm"
+)
+
+// SendResult - delivery result for a single token
+type SendResult struct {
+	Token string
+	Err   error
+	// Unregistered - the token is dead and should be removed from its userend
+	Unregistered bool
+	// Transient - the error is temporary, sending can be retried later
+	Transient bool
+}
+
+// Transport - delivers a notification to a list of tokens, the returned error is for failures
+// of the whole batch, which are considered transient.
+type Transport interface {
+	Send(tokens []string, data map[string]string, notification *messaging.Notification) ([]SendResult, error)
+}
+
+func successResults(tokens []string) []SendResult {
+	results := make([]SendResult, len(tokens))
+	for i, token := range tokens {
+		results[i] = SendResult{Token: token}
+	}
+	return results
+}
+
+var (
+	transportFactories = map[string]func() (Transport, error){
+		"fcm":     newFCMTransport,
+		"webhook": newWebhookTransport,
+		"webpush": newWebPushTransport,
+		"sink":    newSinkTransport,
+	}
+	transports = map[string]Transport{}
+)
+
+// IsKnownTransport - returns true if name is an enabled transport userends can register with
+func IsKnownTransport(name string) bool {
+	_, ok := transports[name]
+	return ok
+}
+
+func initTransport(name string) error {
+	factory, ok := transportFactories[name]
+	if !ok {
+		return fmt.Errorf("Unknown notification transport %s", name)
+	}
+	transport, err := factory()
+	if err != nil {
+		return err
+	}
+	transports[name] = transport
+	return nil
+}
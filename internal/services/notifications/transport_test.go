@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestIsKnownTransport(t *testing.T) {
+	transports = map[string]Transport{}
+	defer func() { transports = map[string]Transport{} }()
+
+	if err := initTransport("sink"); err != nil {
+		t.Fatalf("initTransport(sink): %s", err)
+	}
+	if err := initTransport("carrier-pigeon"); err == nil {
+		t.Errorf("initTransport(carrier-pigeon): expected an error")
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"sink", true},
+		{"fcm", false},
+		{"webhook", false},
+		{"webpush", false},
+		{"carrier-pigeon", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsKnownTransport(tt.name); got != tt.want {
+			t.Errorf("IsKnownTransport(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSinkTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokens       []string
+		data         map[string]string
+		notification *messaging.Notification
+	}{
+		{"data only", []string{"a"}, map[string]string{"type": "ALERT"}, nil},
+		{"with notification", []string{"a", "b"}, map[string]string{"type": "DIGEST"}, &messaging.Notification{Title: "title", Body: "body"}},
+		{"no tokens", []string{}, map[string]string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := newSinkTransport()
+			if err != nil {
+				t.Fatalf("newSinkTransport: %s", err)
+			}
+			before := len(GetSinkMessages())
+
+			results, err := transport.Send(tt.tokens, tt.data, tt.notification)
+			if err != nil {
+				t.Fatalf("Send: %s", err)
+			}
+			if len(results) != len(tt.tokens) {
+				t.Fatalf("Send returned %d results, want %d", len(results), len(tt.tokens))
+			}
+			for i, res := range results {
+				if res.Token != tt.tokens[i] || res.Err != nil || res.Unregistered || res.Transient {
+					t.Errorf("result %d = %+v, want a success for %s", i, res, tt.tokens[i])
+				}
+			}
+
+			msgs := GetSinkMessages()
+			if len(msgs) != before+1 {
+				t.Fatalf("sink has %d messages, want %d", len(msgs), before+1)
+			}
+			msg := msgs[len(msgs)-1]
+			if len(msg.Tokens) != len(tt.tokens) || msg.Data["type"] != tt.data["type"] || msg.Notification != tt.notification {
+				t.Errorf("captured %+v, want tokens %v data %v notification %v", msg, tt.tokens, tt.data, tt.notification)
+			}
+		})
+	}
+}
+
+func TestWebhookTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantBatchErr  bool
+		wantTokenErrs bool
+	}{
+		{"ok", http.StatusOK, false, false},
+		{"no content", http.StatusNoContent, false, false},
+		{"bad request", http.StatusBadRequest, false, true},
+		{"unauthorized", http.StatusUnauthorized, false, true},
+		{"not found", http.StatusNotFound, false, true},
+		{"request timeout", http.StatusRequestTimeout, true, false},
+		{"too many requests", http.StatusTooManyRequests, true, false},
+		{"server error", http.StatusInternalServerError, true, false},
+		{"bad gateway", http.StatusBadGateway, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			transport := webhookTransport{url: srv.URL, client: &http.Client{Timeout: time.Second}}
+
+			tokens := []string{"a", "b"}
+			results, err := transport.Send(tokens, map[string]string{"type": "ALERT"}, nil)
+			if (err != nil) != tt.wantBatchErr {
+				t.Fatalf("Send error = %v, wantBatchErr %v", err, tt.wantBatchErr)
+			}
+			if tt.wantBatchErr {
+				return
+			}
+			if len(results) != len(tokens) {
+				t.Fatalf("Send returned %d results, want %d", len(results), len(tokens))
+			}
+			for i, res := range results {
+				if res.Token != tokens[i] || (res.Err != nil) != tt.wantTokenErrs || res.Transient || res.Unregistered {
+					t.Errorf("result %d = %+v, want error %v and no retry", i, res, tt.wantTokenErrs)
+				}
+			}
+		})
+	}
+}
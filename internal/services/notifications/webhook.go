@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	_ = pflag.String("notificationwebhookurl", "", "Url notifications are POSTed to by the webhook transport")
+	_ = pflag.String("notificationwebhooksecret", "", "Secret used to sign webhook notifications (X-Signature header)")
+)
+
+type webhookPayload struct {
+	Tokens       []string                `json:"tokens"`
+	Data         map[string]string       `json:"data"`
+	Notification *messaging.Notification `json:"notification,omitempty"`
+}
+
+type webhookTransport struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func newWebhookTransport() (Transport, error) {
+	url := viper.GetString("NotificationWebhookURL")
+	if url == "" {
+		return nil, errors.New("Missing NotificationWebhookURL")
+	}
+	return webhookTransport{
+		url:    url,
+		secret: viper.GetString("NotificationWebhookSecret"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (t webhookTransport) Send(tokens []string, data map[string]string, notification *messaging.Notification) ([]SendResult, error) {
+	body, err := json.Marshal(webhookPayload{Tokens: tokens, Data: data, Notification: notification})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.secret != "" {
+		mac := hmac.New(sha256.New, []byte(t.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("Webhook returned status %d", resp.StatusCode)
+		if isPermanentWebhookStatus(resp.StatusCode) {
+			results := make([]SendResult, len(tokens))
+			for i, token := range tokens {
+				results[i] = SendResult{Token: token, Err: err}
+			}
+			return results, nil
+		}
+		return nil, err
+	}
+	return successResults(tokens), nil
+}
+
+// isPermanentWebhookStatus - client errors won't succeed on retry, except
+// timeouts and rate limiting
+func isPermanentWebhookStatus(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
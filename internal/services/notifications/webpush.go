@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package notifications
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	_ = pflag.String("webpushvapidpublickey", "", "VAPID public key (base64url, uncompressed P-256 point)")
+	_ = pflag.String("webpushvapidprivatekey", "", "VAPID private key (base64url, raw P-256 scalar)")
+	_ = pflag.String("webpushsubscriber", "mailto:contact@supergreenlab.com", "VAPID subscriber (mailto: or https: url)")
+)
+
+const webPushRecordSize = 4096
+
+// webPushSubscription - the browser's PushSubscription, JSON encoded in the userend's NotificationToken
+type webPushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+type webPushTransport struct {
+	publicKey  string
+	privateKey *ecdsa.PrivateKey
+	subscriber string
+	client     *http.Client
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func newWebPushTransport() (Transport, error) {
+	publicKey := viper.GetString("WebPushVAPIDPublicKey")
+	d, err := decodeBase64URL(viper.GetString("WebPushVAPIDPrivateKey"))
+	if err != nil {
+		return nil, err
+	}
+	if publicKey == "" || len(d) != 32 {
+		return nil, errors.New("Missing or invalid VAPID keys")
+	}
+	privateKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	privateKey.PublicKey.Curve = elliptic.P256()
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = elliptic.P256().ScalarBaseMult(d)
+
+	return webPushTransport{
+		publicKey:  publicKey,
+		privateKey: privateKey,
+		subscriber: viper.GetString("WebPushSubscriber"),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (t webPushTransport) Send(tokens []string, data map[string]string, notification *messaging.Notification) ([]SendResult, error) {
+	payload, err := json.Marshal(webhookPayload{Data: data, Notification: notification})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SendResult, len(tokens))
+	for i, token := range tokens {
+		results[i] = t.sendOne(token, payload)
+	}
+	return results, nil
+}
+
+func (t webPushTransport) sendOne(token string, payload []byte) SendResult {
+	res := SendResult{Token: token}
+	if err := t.post(token, payload); err != nil {
+		res.Err = err
+		if serr, ok := err.(webPushStatusError); ok {
+			res.Unregistered = serr.status == http.StatusNotFound || serr.status == http.StatusGone
+			res.Transient = serr.status == http.StatusTooManyRequests || serr.status >= 500
+		} else if _, ok := err.(net.Error); ok {
+			res.Transient = true
+		}
+	}
+	return res
+}
+
+type webPushStatusError struct {
+	status int
+	host   string
+}
+
+func (e webPushStatusError) Error() string {
+	return fmt.Sprintf("Push service returned status %d for %s", e.status, e.host)
+}
+
+func (t webPushTransport) post(token string, payload []byte) error {
+	sub := webPushSubscription{}
+	if err := json.Unmarshal([]byte(token), &sub); err != nil {
+		return err
+	}
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	body, err := webPushEncrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": t.subscriber,
+	})
+	signed, err := jwtToken.SignedString(t.privateKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", signed, t.publicKey))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return webPushStatusError{status: resp.StatusCode, host: u.Host}
+	}
+	return nil
+}
+
+func hkdfRead(secret, salt, info []byte, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), b)
+	return b, err
+}
+
+// webPushEncrypt - encrypts payload for sub as described in RFC 8291 (aes128gcm content encoding)
+func webPushEncrypt(sub webPushSubscription, payload []byte) ([]byte, error) {
+	curve := elliptic.P256()
+
+	uaPublic, err := decodeBase64URL(sub.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeBase64URL(sub.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+	uaX, uaY := elliptic.Unmarshal(curve, uaPublic)
+	if uaX == nil {
+		return nil, errors.New("Invalid p256dh key")
+	}
+
+	asPrivate, asX, asY, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := elliptic.Marshal(curve, asX, asY)
+
+	sx, _ := curve.ScalarMult(uaX, uaY, asPrivate)
+	ecdhSecret := make([]byte, 32)
+	sxb := sx.Bytes()
+	copy(ecdhSecret[32-len(sxb):], sxb)
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm, err := hkdfRead(ecdhSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdfRead(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdfRead(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// single record, 0x02 is the last record delimiter
+	ciphertext := gcm.Seal(nil, nonce, append(payload, 0x02), nil)
+	if len(ciphertext) > webPushRecordSize {
+		return nil, errors.New("Web push payload too large")
+	}
+
+	header := make([]byte, 21)
+	copy(header, salt)
+	binary.BigEndian.PutUint32(header[16:], webPushRecordSize)
+	header[20] = byte(len(asPublic))
+
+	body := append(header, asPublic...)
+	return append(body, ciphertext...), nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package plantsearch
+
+import (
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	indexBatchSize = 500
+	// indexedMessages - latest entry messages added to the plant's search document
+	indexedMessages = 50
+)
+
+func indexPlants() {
+	if n, err := db.DeleteUnsearchablePlants(); err != nil {
+		logrus.Errorf("db.DeleteUnsearchablePlants in indexPlants %q", err)
+	} else if n != 0 {
+		logrus.Infof("Removed %d unpublished or deleted plants from the search", n)
+	}
+
+	plants, err := db.GetPlantsToIndex(indexBatchSize)
+	if err != nil {
+		logrus.Errorf("db.GetPlantsToIndex in indexPlants %q", err)
+		return
+	}
+	now := time.Now()
+	for _, plant := range plants {
+		nextStageAt := null.Time{}
+		if date, ok := plant.NextStageDate(now); ok {
+			nextStageAt = null.TimeFrom(date)
+		}
+		if err := db.IndexPlant(plant.ID.UUID, plant.Stage(now), nextStageAt, indexedMessages); err != nil {
+			logrus.Errorf("db.IndexPlant in indexPlants %q - plant: %+v", err, plant)
+		}
+	}
+}
+
+func Init() {
+	cron.SetJob("plantsearch_index", "* * * * *", indexPlants)
+}
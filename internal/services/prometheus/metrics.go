@@ -36,6 +36,10 @@ var (
 		Name: "appbackend_notification_errors",
 		Help: "Number of notification errors",
 	}, []string{"type"})
+	notificationDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "appbackend_notification_deliveries",
+		Help: "Number of per-token notification delivery results",
+	}, []string{"type", "transport", "status"})
 	requestsCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "appbackend_requests",
 		Help: "Number of http requests",
@@ -60,6 +60,10 @@ func NotificationError(notificationType string) {
 	notificationErrors.WithLabelValues(notificationType).Inc()
 }
 
+func NotificationDelivery(notificationType, transport, status string) {
+	notificationDeliveries.WithLabelValues(notificationType, transport, status).Inc()
+}
+
 func AlertTriggered(metric, atype string) {
 	alertsCount.WithLabelValues(metric, atype).Inc()
 }
@@ -19,6 +19,8 @@
 package pubsub
 
 import (
+	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -65,6 +67,66 @@ func SubscribeControllerIntMetric(topic string) chan ControllerIntMetric {
 	return ch
 }
 
+type ControllerKeyValue struct {
+	ControllerID string `json:"controllerID"`
+	Key          string `json:"key"`
+	Value        string `json:"value"`
+}
+
+// SubscribeControllerKeys - streams the controller's key updates matching the
+// patterns, ie. BOX_*_TEMP, until ctx is done.
+func SubscribeControllerKeys(ctx context.Context, controllerID string, patterns []string) chan ControllerKeyValue {
+	ch := make(chan ControllerKeyValue, 100)
+	topics := make([]string, len(patterns))
+	for i, p := range patterns {
+		topics[i] = fmt.Sprintf("*.%s.*.%s", controllerID, p)
+	}
+	rps := r.PSubscribe(topics...)
+	go func() {
+		<-ctx.Done()
+		rps.Close()
+	}()
+	go func() {
+		defer close(ch)
+		for msg := range rps.Channel() {
+			keyParts := strings.Split(msg.Channel, ".")
+			if len(keyParts) < 4 {
+				continue
+			}
+			select {
+			case ch <- ControllerKeyValue{ControllerID: keyParts[1], Key: keyParts[3], Value: msg.Payload}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// PublishControllerCommand - commands are published on cmd.<controllerID>,
+// returns the number of subscribers that received it, 0 when the controller isn't connected
+func PublishControllerCommand(controllerID string, payload []byte) (int64, error) {
+	return r.Publish(fmt.Sprintf("cmd.%s", controllerID), string(payload)).Result()
+}
+
+type ControllerCommandAck struct {
+	ControllerID string
+	Payload      []byte
+}
+
+// SubscribeControllerCommandAcks - controllers ack their commands on ack.<controllerID>
+func SubscribeControllerCommandAcks() chan ControllerCommandAck {
+	ch := make(chan ControllerCommandAck, 100)
+	rps := r.PSubscribe("ack.*")
+	go func() {
+		for msg := range rps.Channel() {
+			ch <- ControllerCommandAck{ControllerID: strings.TrimPrefix(msg.Channel, "ack."), Payload: []byte(msg.Payload)}
+		}
+		close(ch)
+	}()
+	return ch
+}
+
 func Init() {
 	initRedis()
 	initPubsub()
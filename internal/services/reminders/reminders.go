@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package reminders
+
+import (
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
+	"github.com/SuperGreenLab/AppBackend/internal/services/social"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+)
+
+func sendReminder(r db.Reminder, now time.Time) error {
+	plant, err := db.GetPlant(r.PlantID)
+	if err != nil {
+		return err
+	}
+	if plant.Deleted || plant.Archived {
+		return db.SetReminderNextAt(r.ID.UUID, null.Time{})
+	}
+
+	var nextAt null.Time
+	if r.Type == db.ReminderTypeRelative {
+		// the plant date might have been changed since the reminder was scheduled
+		target, err := NextReminderDate(r, plant, now)
+		if err != nil {
+			if err := db.SetReminderNextAt(r.ID.UUID, null.Time{}); err != nil {
+				logrus.Errorf("db.SetReminderNextAt in sendReminder %q - %+v", err, r)
+			}
+			return err
+		}
+		if target.Valid && target.Time.After(now) {
+			return db.SetReminderNextAt(r.ID.UUID, target)
+		}
+	} else {
+		nextAt, err = NextReminderDate(r, plant, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	claimed, err := db.ClaimReminder(r.ID.UUID, r.NextAt.Time, nextAt, now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	body := plant.Name
+	if r.Body.Valid && r.Body.String != "" {
+		body = r.Body.String
+	}
+	data, notif := social.NewNotificationDataReminder(notifications.Text(r.Title), notifications.Text(body), "", plant.ID.UUID)
+	notifications.SendNotificationToUser(r.UserID, data, &notif)
+	return nil
+}
+
+func checkReminders() {
+	now := time.Now()
+	reminders, err := db.GetDueReminders(now)
+	if err != nil {
+		logrus.Errorf("db.GetDueReminders in checkReminders %q", err)
+		return
+	}
+	for _, r := range reminders {
+		if err := sendReminder(r, now); err != nil {
+			logrus.Errorf("sendReminder in checkReminders %q - %+v", err, r)
+		}
+	}
+}
+
+func Init() {
+	prometheus.InitNotificationSent(social.NotificationTypeReminder)
+
+	cron.SetJob("reminders", "* * * * *", checkReminders)
+}
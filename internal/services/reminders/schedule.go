@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package reminders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	cronpkg "github.com/robfig/cron/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+var (
+	relativeToSettings = map[string]bool{
+		"germinationDate": true,
+		"bloomingStart":   true,
+	}
+)
+
+// ValidateReminder - checks that the reminder's schedule is complete and parsable
+func ValidateReminder(r db.Reminder) error {
+	if r.Title == "" {
+		return errors.New("Missing title")
+	}
+	if _, err := reminderLocation(r); err != nil {
+		return err
+	}
+	switch r.Type {
+	case db.ReminderTypeCron:
+		if !r.Schedule.Valid {
+			return errors.New("Missing schedule")
+		}
+		if _, err := cronpkg.ParseStandard(r.Schedule.String); err != nil {
+			return err
+		}
+	case db.ReminderTypeInterval:
+		if !r.IntervalHours.Valid || r.IntervalHours.Int64 <= 0 {
+			return errors.New("Missing or invalid intervalHours")
+		}
+	case db.ReminderTypeRelative:
+		if !r.RelativeTo.Valid || !relativeToSettings[r.RelativeTo.String] {
+			return errors.New("Missing or invalid relativeTo")
+		}
+		if !r.RelativeDays.Valid {
+			return errors.New("Missing relativeDays")
+		}
+	default:
+		return fmt.Errorf("Unknown reminder type %s", r.Type)
+	}
+	return nil
+}
+
+// reminderLocation - time.LoadLocation returns UTC for an empty name
+func reminderLocation(r db.Reminder) (*time.Location, error) {
+	return time.LoadLocation(r.Timezone)
+}
+
+func plantDate(plant appbackend.Plant, setting string) (time.Time, error) {
+	plantSettings := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(plant.Settings), &plantSettings); err != nil {
+		return time.Time{}, err
+	}
+	dateStr, ok := plantSettings[setting].(string)
+	if !ok || dateStr == "" {
+		return time.Time{}, fmt.Errorf("Plant has no %s set", setting)
+	}
+	return time.Parse(time.RFC3339, dateStr)
+}
+
+// NextReminderDate - computes the next occurrence of the reminder after from,
+// returns an invalid null.Time when the reminder won't be sent anymore.
+func NextReminderDate(r db.Reminder, plant appbackend.Plant, from time.Time) (null.Time, error) {
+	switch r.Type {
+	case db.ReminderTypeCron:
+		schedule, err := cronpkg.ParseStandard(r.Schedule.String)
+		if err != nil {
+			return null.Time{}, err
+		}
+		loc, err := reminderLocation(r)
+		if err != nil {
+			return null.Time{}, err
+		}
+		// cron fields are the user's wall clock
+		return null.TimeFrom(schedule.Next(from.In(loc))), nil
+	case db.ReminderTypeInterval:
+		interval := time.Duration(r.IntervalHours.Int64) * time.Hour
+		base := r.CreatedAt
+		if r.CompletedAt.Valid {
+			base = r.CompletedAt.Time
+		}
+		if r.NextAt.Valid && r.NextAt.Time.After(base) {
+			base = r.NextAt.Time
+		}
+		if base.IsZero() {
+			return null.TimeFrom(from.Add(interval)), nil
+		}
+		next := base.Add(interval)
+		for !next.After(from) {
+			next = next.Add(interval)
+		}
+		return null.TimeFrom(next), nil
+	case db.ReminderTypeRelative:
+		if r.LastSentAt.Valid {
+			return null.Time{}, nil
+		}
+		date, err := plantDate(plant, r.RelativeTo.String)
+		if err != nil {
+			return null.Time{}, err
+		}
+		return null.TimeFrom(date.AddDate(0, 0, int(r.RelativeDays.Int64))), nil
+	}
+	return null.Time{}, fmt.Errorf("Unknown reminder type %s", r.Type)
+}
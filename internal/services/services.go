@@ -22,12 +22,18 @@ import (
 	"github.com/SuperGreenLab/AppBackend/internal/services/alerts"
 	"github.com/SuperGreenLab/AppBackend/internal/services/bot"
 	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	"github.com/SuperGreenLab/AppBackend/internal/services/devices"
+	"github.com/SuperGreenLab/AppBackend/internal/services/digest"
 	"github.com/SuperGreenLab/AppBackend/internal/services/discord"
+	"github.com/SuperGreenLab/AppBackend/internal/services/metricsarchive"
 	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
+	"github.com/SuperGreenLab/AppBackend/internal/services/plantsearch"
 	"github.com/SuperGreenLab/AppBackend/internal/services/prometheus"
 	"github.com/SuperGreenLab/AppBackend/internal/services/pubsub"
+	"github.com/SuperGreenLab/AppBackend/internal/services/reminders"
 	"github.com/SuperGreenLab/AppBackend/internal/services/slack"
 	"github.com/SuperGreenLab/AppBackend/internal/services/social"
+	"github.com/SuperGreenLab/AppBackend/internal/services/strains"
 )
 
 func Init() {
@@ -37,7 +43,13 @@ func Init() {
 	notifications.Init()
 	social.Init()
 	alerts.Init()
+	devices.Init()
 	slack.Init()
 	discord.Init()
 	bot.Init()
+	digest.Init()
+	reminders.Init()
+	metricsarchive.Init()
+	strains.Init()
+	plantsearch.Init()
 }
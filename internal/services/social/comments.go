@@ -19,7 +19,6 @@
 package social
 
 import (
-	"fmt"
 	"regexp"
 
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
@@ -63,14 +62,14 @@ func listenCommentsAdded() {
 				logrus.Errorf("db.GetComment in listenCommentsAdded %q - %+v", err, com)
 			}
 			if com.UserID != comReplied.UserID {
-				title := fmt.Sprintf("%s replied to your comment on the diary %s!", user.Nickname, plant.Name)
-				data, notif := NewNotificationDataPlantCommentReply(title, com.Text, "", plant.ID.UUID, feedEntry.ID.UUID, comReplied.ID.UUID)
+				title := notifications.Loc("comment_reply_title", user.Nickname, plant.Name)
+				data, notif := NewNotificationDataPlantCommentReply(title, notifications.Text(com.Text), "", plant.ID.UUID, feedEntry.ID.UUID, comReplied.ID.UUID)
 				notifications.SendNotificationToUser(comReplied.UserID, data, &notif)
 				userIDNotif = comReplied.UserID
 			}
 		} else if com.UserID != feedEntry.UserID {
-			title := fmt.Sprintf("%s posted a message on your diary %s!", user.Nickname, plant.Name)
-			data, notif := NewNotificationDataPlantComment(title, com.Text, "", plant.ID.UUID, feedEntry.ID.UUID, com.Type)
+			title := notifications.Loc("comment_title", user.Nickname, plant.Name)
+			data, notif := NewNotificationDataPlantComment(title, notifications.Text(com.Text), "", plant.ID.UUID, feedEntry.ID.UUID, com.Type)
 			notifications.SendNotificationToUser(feedEntry.UserID, data, &notif)
 			userIDNotif = feedEntry.UserID
 		}
@@ -85,12 +84,12 @@ func listenCommentsAdded() {
 			if userMentionned.ID.UUID == userIDNotif {
 				continue
 			}
-			title := fmt.Sprintf("%s mentionned you in a comment on the diary %s!", user.Nickname, plant.Name)
+			title := notifications.Loc("comment_mention_title", user.Nickname, plant.Name)
 			comID := id
 			if com.ReplyTo.Valid {
 				comID = com.ReplyTo.UUID
 			}
-			data, notif := NewNotificationDataPlantCommentReply(title, com.Text, "", plant.ID.UUID, feedEntry.ID.UUID, comID)
+			data, notif := NewNotificationDataPlantCommentReply(title, notifications.Text(com.Text), "", plant.ID.UUID, feedEntry.ID.UUID, comID)
 			notifications.SendNotificationToUser(userMentionned.ID.UUID, data, &notif)
 		}
 		slack.CommentPosted(id, *com, plant, user)
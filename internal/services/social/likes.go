@@ -19,8 +19,6 @@
 package social
 
 import (
-	"fmt"
-
 	"github.com/SuperGreenLab/AppBackend/internal/data/db"
 	"github.com/SuperGreenLab/AppBackend/internal/server/middlewares"
 	"github.com/SuperGreenLab/AppBackend/internal/services/notifications"
@@ -56,8 +54,8 @@ func listenLikesAdded() {
 				continue
 			}
 
-			title := fmt.Sprintf("%s liked your comment on the diary %s!", user.Nickname, plant.Name)
-			data, notif := NewNotificationDataLikePlantComment(title, "Tap to view comment", "", plant.ID.UUID, com.FeedEntryID, like.CommentID.UUID, com.ReplyTo)
+			title := notifications.Loc("like_comment_title", user.Nickname, plant.Name)
+			data, notif := NewNotificationDataLikePlantComment(title, notifications.Loc("like_comment_body"), "", plant.ID.UUID, com.FeedEntryID, like.CommentID.UUID, com.ReplyTo)
 			notifications.SendNotificationToUser(com.UserID, data, &notif)
 			slack.CommentLikeAdded(*like, com, plant, user)
 		} else if like.FeedEntryID.Valid {
@@ -81,8 +79,8 @@ func listenLikesAdded() {
 				logrus.Errorf("db.GetUser in listenLikesAdded %q - %+v", err, like)
 				continue
 			}
-			title := fmt.Sprintf("%s liked your growlog on the diary %s!", user.Nickname, plant.Name)
-			data, notif := NewNotificationDataLikePlantFeedEntry(title, "Tap to view growlog", "", plant.ID.UUID, like.FeedEntryID.UUID)
+			title := notifications.Loc("like_feedentry_title", user.Nickname, plant.Name)
+			data, notif := NewNotificationDataLikePlantFeedEntry(title, notifications.Loc("like_feedentry_body"), "", plant.ID.UUID, like.FeedEntryID.UUID)
 			notifications.SendNotificationToUser(plant.UserID, data, &notif)
 			slack.PostLikeAdded(*like, plant, user)
 		}
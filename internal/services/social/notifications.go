@@ -50,20 +50,17 @@ func (n NotificationDataPlantComment) ToMap() map[string]string {
 	})
 }
 
-func NewNotificationDataPlantComment(title, body, imageUrl string, plantID, feedEntryID uuid.UUID, commentType string) (NotificationDataPlantComment, messaging.Notification) {
+func NewNotificationDataPlantComment(title, body *notifications.LocalizedText, imageUrl string, plantID, feedEntryID uuid.UUID, commentType string) (NotificationDataPlantComment, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypePlantComment, title, body)
 	return NotificationDataPlantComment{
-			NotificationBaseData: notifications.NotificationBaseData{
-				Type:  NotificationTypePlantComment,
-				Title: title,
-				Body:  body,
-			},
-			PlantID:     plantID,
-			FeedEntryID: feedEntryID,
-			CommentType: commentType,
+			NotificationBaseData: base,
+			PlantID:              plantID,
+			FeedEntryID:          feedEntryID,
+			CommentType:          commentType,
 		},
 		messaging.Notification{
-			Title:    title,
-			Body:     body,
+			Title:    base.Title,
+			Body:     base.Body,
 			ImageURL: imageUrl,
 		}
 }
@@ -85,20 +82,17 @@ func (n NotificationDataPlantCommentReply) ToMap() map[string]string {
 	})
 }
 
-func NewNotificationDataPlantCommentReply(title, body, imageUrl string, plantID, feedEntryID uuid.UUID, commentID uuid.UUID) (NotificationDataPlantCommentReply, messaging.Notification) {
+func NewNotificationDataPlantCommentReply(title, body *notifications.LocalizedText, imageUrl string, plantID, feedEntryID uuid.UUID, commentID uuid.UUID) (NotificationDataPlantCommentReply, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypePlantCommentReply, title, body)
 	return NotificationDataPlantCommentReply{
-			NotificationBaseData: notifications.NotificationBaseData{
-				Type:  NotificationTypePlantCommentReply,
-				Title: title,
-				Body:  body,
-			},
-			PlantID:     plantID,
-			FeedEntryID: feedEntryID,
-			CommentID:   commentID,
+			NotificationBaseData: base,
+			PlantID:              plantID,
+			FeedEntryID:          feedEntryID,
+			CommentID:            commentID,
 		},
 		messaging.Notification{
-			Title:    title,
-			Body:     body,
+			Title:    base.Title,
+			Body:     base.Body,
 			ImageURL: imageUrl,
 		}
 }
@@ -116,6 +110,19 @@ func (n NotificationDataReminder) ToMap() map[string]string {
 	})
 }
 
+func NewNotificationDataReminder(title, body *notifications.LocalizedText, imageUrl string, plantID uuid.UUID) (NotificationDataReminder, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypeReminder, title, body)
+	return NotificationDataReminder{
+			NotificationBaseData: base,
+			PlantID:              plantID,
+		},
+		messaging.Notification{
+			Title:    base.Title,
+			Body:     base.Body,
+			ImageURL: imageUrl,
+		}
+}
+
 type NotificationDataLikePlantComment struct {
 	notifications.NotificationBaseData
 
@@ -138,21 +145,18 @@ func (n NotificationDataLikePlantComment) ToMap() map[string]string {
 	return n.Merge(m, m2)
 }
 
-func NewNotificationDataLikePlantComment(title, body, imageUrl string, plantID, feedEntryID uuid.UUID, commentID uuid.UUID, replyTo uuid.NullUUID) (NotificationDataLikePlantComment, messaging.Notification) {
+func NewNotificationDataLikePlantComment(title, body *notifications.LocalizedText, imageUrl string, plantID, feedEntryID uuid.UUID, commentID uuid.UUID, replyTo uuid.NullUUID) (NotificationDataLikePlantComment, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypeLikePlantComment, title, body)
 	return NotificationDataLikePlantComment{
-			NotificationBaseData: notifications.NotificationBaseData{
-				Type:  NotificationTypeLikePlantComment,
-				Title: title,
-				Body:  body,
-			},
-			PlantID:     plantID,
-			FeedEntryID: feedEntryID,
-			CommentID:   commentID,
-			ReplyTo:     replyTo,
+			NotificationBaseData: base,
+			PlantID:              plantID,
+			FeedEntryID:          feedEntryID,
+			CommentID:            commentID,
+			ReplyTo:              replyTo,
 		},
 		messaging.Notification{
-			Title:    title,
-			Body:     body,
+			Title:    base.Title,
+			Body:     base.Body,
 			ImageURL: imageUrl,
 		}
 }
@@ -172,19 +176,16 @@ func (n NotificationDataLikePlantFeedEntry) ToMap() map[string]string {
 	})
 }
 
-func NewNotificationDataLikePlantFeedEntry(title, body, imageUrl string, plantID, feedEntryID uuid.UUID) (NotificationDataLikePlantFeedEntry, messaging.Notification) {
+func NewNotificationDataLikePlantFeedEntry(title, body *notifications.LocalizedText, imageUrl string, plantID, feedEntryID uuid.UUID) (NotificationDataLikePlantFeedEntry, messaging.Notification) {
+	base := notifications.NewLocalizedBaseData(NotificationTypeLikePlantFeedEntry, title, body)
 	return NotificationDataLikePlantFeedEntry{
-			NotificationBaseData: notifications.NotificationBaseData{
-				Type:  NotificationTypeLikePlantFeedEntry,
-				Title: title,
-				Body:  body,
-			},
-			PlantID:     plantID,
-			FeedEntryID: feedEntryID,
+			NotificationBaseData: base,
+			PlantID:              plantID,
+			FeedEntryID:          feedEntryID,
 		},
 		messaging.Notification{
-			Title:    title,
-			Body:     body,
+			Title:    base.Title,
+			Body:     base.Body,
 			ImageURL: imageUrl,
 		}
 }
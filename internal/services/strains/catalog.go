@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package strains
+
+import (
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/gofrs/uuid"
+)
+
+// Catalog - seed banks and strains the plants' settings are mapped to, the
+// live catalog is in postgres, tests use an in-memory one
+type Catalog interface {
+	GetSeedBankByAlias(alias string) (db.SeedBank, error)
+	GetSimilarSeedBank(name string, minSimilarity float64) (db.SeedBank, error)
+	UpsertSeedBank(name string) (uuid.UUID, error)
+	CreateSeedBankAlias(seedBankID uuid.UUID, alias string) error
+
+	GetStrainByAlias(seedBankID uuid.NullUUID, alias string) (db.Strain, error)
+	GetSimilarStrain(seedBankID uuid.NullUUID, name string, minSimilarity float64) (db.Strain, error)
+	UpsertStrain(strain db.Strain) (uuid.UUID, error)
+	CreateStrainAlias(strainID uuid.UUID, alias string) error
+
+	SetPlantStrain(ps db.PlantStrain) error
+}
+
+type dbCatalog struct{}
+
+func (dbCatalog) GetSeedBankByAlias(alias string) (db.SeedBank, error) {
+	return db.GetSeedBankByAlias(alias)
+}
+
+func (dbCatalog) GetSimilarSeedBank(name string, minSimilarity float64) (db.SeedBank, error) {
+	return db.GetSimilarSeedBank(name, minSimilarity)
+}
+
+func (dbCatalog) UpsertSeedBank(name string) (uuid.UUID, error) {
+	return db.UpsertSeedBank(name)
+}
+
+func (dbCatalog) CreateSeedBankAlias(seedBankID uuid.UUID, alias string) error {
+	return db.CreateSeedBankAlias(seedBankID, alias)
+}
+
+func (dbCatalog) GetStrainByAlias(seedBankID uuid.NullUUID, alias string) (db.Strain, error) {
+	return db.GetStrainByAlias(seedBankID, alias)
+}
+
+func (dbCatalog) GetSimilarStrain(seedBankID uuid.NullUUID, name string, minSimilarity float64) (db.Strain, error) {
+	return db.GetSimilarStrain(seedBankID, name, minSimilarity)
+}
+
+func (dbCatalog) UpsertStrain(strain db.Strain) (uuid.UUID, error) {
+	return db.UpsertStrain(strain)
+}
+
+func (dbCatalog) CreateStrainAlias(strainID uuid.UUID, alias string) error {
+	return db.CreateStrainAlias(strainID, alias)
+}
+
+func (dbCatalog) SetPlantStrain(ps db.PlantStrain) error {
+	return db.SetPlantStrain(ps)
+}
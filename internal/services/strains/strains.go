@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package strains
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	"github.com/SuperGreenLab/AppBackend/internal/services/cron"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+	udb "upper.io/db.v3"
+)
+
+const (
+	mapBatchSize = 1000
+	// minSimilarity - trigram similarity above which a setting is considered a
+	// typo of an existing catalog entry rather than a new one
+	minSimilarity = 0.85
+	// verifyMinGrowers - pending catalog entries are verified once the public
+	// plants of that many growers are mapped to them
+	verifyMinGrowers = 3
+)
+
+var numbersRegexp = regexp.MustCompile("[0-9]+")
+
+// Normalize - settings are matched against the aliases case and spaces insensitive
+func Normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// isTypo - similar names are only the same entry when they have the same
+// numbers, ie. "Gorilla Glue #4" and "Gorilla Glue #5" are different strains
+func isTypo(alias, name string) bool {
+	return strings.Join(numbersRegexp.FindAllString(alias, -1), " ") == strings.Join(numbersRegexp.FindAllString(Normalize(name), -1), " ")
+}
+
+// mapSeedBank - only public plants' settings add aliases and pending entries
+// to the catalog, the others are only matched against the existing aliases and
+// stay unmapped when there's none
+func mapSeedBank(c Catalog, name string, public bool) (uuid.NullUUID, error) {
+	alias := Normalize(name)
+	seedBank, err := c.GetSeedBankByAlias(alias)
+	if err == nil {
+		return seedBank.ID, nil
+	} else if err != udb.ErrNoMoreRows {
+		return uuid.NullUUID{}, err
+	} else if !public {
+		return uuid.NullUUID{}, nil
+	}
+
+	var id uuid.UUID
+	seedBank, err = c.GetSimilarSeedBank(alias, minSimilarity)
+	if err == nil && isTypo(alias, seedBank.Name) {
+		id = seedBank.ID.UUID
+	} else if err == nil || err == udb.ErrNoMoreRows {
+		id, err = c.UpsertSeedBank(strings.Join(strings.Fields(name), " "))
+		if err != nil {
+			return uuid.NullUUID{}, err
+		}
+	} else {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, c.CreateSeedBankAlias(id, alias)
+}
+
+// mapStrain - like mapSeedBank, within the seed bank's strains
+func mapStrain(c Catalog, seedBankID uuid.NullUUID, name, plantType string, public bool) (uuid.NullUUID, error) {
+	alias := Normalize(name)
+	strain, err := c.GetStrainByAlias(seedBankID, alias)
+	if err == nil {
+		return strain.ID, nil
+	} else if err != udb.ErrNoMoreRows {
+		return uuid.NullUUID{}, err
+	} else if !public {
+		return uuid.NullUUID{}, nil
+	}
+
+	var id uuid.UUID
+	strain, err = c.GetSimilarStrain(seedBankID, alias, minSimilarity)
+	if err == nil && isTypo(alias, strain.Name) {
+		id = strain.ID.UUID
+	} else if err == nil || err == udb.ErrNoMoreRows {
+		strain = db.Strain{SeedBankID: seedBankID, Name: strings.Join(strings.Fields(name), " ")}
+		if plantType != "" {
+			strain.Type = null.StringFrom(plantType)
+		}
+		id, err = c.UpsertStrain(strain)
+		if err != nil {
+			return uuid.NullUUID{}, err
+		}
+	} else {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, c.CreateStrainAlias(id, alias)
+}
+
+// MapPlant - links the plant's strain and seedBank settings to catalog
+// entries, unknown ones are added to the catalog when the plant is public
+func MapPlant(c Catalog, plant appbackend.Plant, now time.Time) error {
+	ps := db.PlantStrain{PlantID: plant.ID.UUID, MappedAt: now}
+	var err error
+
+	if seedBank := plant.SettingString("seedBank"); Normalize(seedBank) != "" {
+		ps.SeedBankID, err = mapSeedBank(c, seedBank, plant.Public)
+		if err != nil {
+			return err
+		}
+	}
+	if strain := plant.SettingString("strain"); Normalize(strain) != "" {
+		ps.StrainID, err = mapStrain(c, ps.SeedBankID, strain, plant.SettingString("plantType"), plant.Public)
+		if err != nil {
+			return err
+		}
+	}
+	return c.SetPlantStrain(ps)
+}
+
+func mapPlants() {
+	plants, err := db.GetPlantsToMapStrains(mapBatchSize)
+	if err != nil {
+		logrus.Errorf("db.GetPlantsToMapStrains in mapPlants %q", err)
+		return
+	}
+	now := time.Now()
+	for _, plant := range plants {
+		if err := MapPlant(dbCatalog{}, plant, now); err != nil {
+			logrus.Errorf("MapPlant in mapPlants %q - plant: %+v", err, plant)
+		}
+	}
+	if err := db.VerifyCatalog(verifyMinGrowers); err != nil {
+		logrus.Errorf("db.VerifyCatalog in mapPlants %q", err)
+	}
+}
+
+func Init() {
+	cron.SetJob("strains_map", "*/5 * * * *", mapPlants)
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package strains
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SuperGreenLab/AppBackend/internal/data/db"
+	appbackend "github.com/SuperGreenLab/AppBackend/pkg"
+	"github.com/gofrs/uuid"
+	udb "upper.io/db.v3"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Blue Dream", "blue dream"},
+		{"  Blue   Dream ", "blue dream"},
+		{"BLUE\tDREAM", "blue dream"},
+		{"", ""},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.name); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsTypo(t *testing.T) {
+	tests := []struct {
+		alias string
+		name  string
+		want  bool
+	}{
+		{"blue dreem", "Blue Dream", true},
+		{"gorilla glue #4", "Gorilla Glue #4", true},
+		{"gorilla glue #5", "Gorilla Glue #4", false},
+		{"gorilla glue", "Gorilla Glue #4", false},
+		{"og kush 18", "OG Kush #18", true},
+		{"og kush 1 8", "OG Kush #18", false},
+	}
+	for _, tt := range tests {
+		if got := isTypo(tt.alias, tt.name); got != tt.want {
+			t.Errorf("isTypo(%q, %q) = %v, want %v", tt.alias, tt.name, got, tt.want)
+		}
+	}
+}
+
+// memoryCatalog - Catalog without similarity search
+type memoryCatalog struct {
+	seedBanks     map[string]uuid.UUID
+	seedBankAlias map[string]uuid.UUID
+	strains       map[string]db.Strain
+	strainAlias   map[string]db.Strain
+	plantStrains  []db.PlantStrain
+}
+
+func newMemoryCatalog() *memoryCatalog {
+	return &memoryCatalog{
+		seedBanks:     map[string]uuid.UUID{},
+		seedBankAlias: map[string]uuid.UUID{},
+		strains:       map[string]db.Strain{},
+		strainAlias:   map[string]db.Strain{},
+	}
+}
+
+func strainKey(seedBankID uuid.NullUUID, name string) string {
+	return fmt.Sprintf("%s.%s", seedBankID.UUID, name)
+}
+
+func (c *memoryCatalog) GetSeedBankByAlias(alias string) (db.SeedBank, error) {
+	id, ok := c.seedBankAlias[alias]
+	if !ok {
+		return db.SeedBank{}, udb.ErrNoMoreRows
+	}
+	return db.SeedBank{ID: uuid.NullUUID{UUID: id, Valid: true}}, nil
+}
+
+func (c *memoryCatalog) GetSimilarSeedBank(name string, minSimilarity float64) (db.SeedBank, error) {
+	return db.SeedBank{}, udb.ErrNoMoreRows
+}
+
+func (c *memoryCatalog) UpsertSeedBank(name string) (uuid.UUID, error) {
+	if _, ok := c.seedBanks[name]; !ok {
+		c.seedBanks[name] = uuid.Must(uuid.NewV4())
+	}
+	return c.seedBanks[name], nil
+}
+
+func (c *memoryCatalog) CreateSeedBankAlias(seedBankID uuid.UUID, alias string) error {
+	c.seedBankAlias[alias] = seedBankID
+	return nil
+}
+
+func (c *memoryCatalog) GetStrainByAlias(seedBankID uuid.NullUUID, alias string) (db.Strain, error) {
+	strain, ok := c.strainAlias[strainKey(seedBankID, alias)]
+	if !ok {
+		return db.Strain{}, udb.ErrNoMoreRows
+	}
+	return strain, nil
+}
+
+func (c *memoryCatalog) GetSimilarStrain(seedBankID uuid.NullUUID, name string, minSimilarity float64) (db.Strain, error) {
+	return db.Strain{}, udb.ErrNoMoreRows
+}
+
+func (c *memoryCatalog) UpsertStrain(strain db.Strain) (uuid.UUID, error) {
+	key := strainKey(strain.SeedBankID, strain.Name)
+	if _, ok := c.strains[key]; !ok {
+		strain.ID = uuid.NullUUID{UUID: uuid.Must(uuid.NewV4()), Valid: true}
+		c.strains[key] = strain
+	}
+	return c.strains[key].ID.UUID, nil
+}
+
+func (c *memoryCatalog) CreateStrainAlias(strainID uuid.UUID, alias string) error {
+	for _, strain := range c.strains {
+		if strain.ID.UUID == strainID {
+			c.strainAlias[strainKey(strain.SeedBankID, alias)] = strain
+			return nil
+		}
+	}
+	return udb.ErrNoMoreRows
+}
+
+func (c *memoryCatalog) SetPlantStrain(ps db.PlantStrain) error {
+	c.plantStrains = append(c.plantStrains, ps)
+	return nil
+}
+
+func testPlant(public bool, seedBank, strain string) appbackend.Plant {
+	return appbackend.Plant{
+		ID:       uuid.NullUUID{UUID: uuid.Must(uuid.NewV4()), Valid: true},
+		Public:   public,
+		Settings: fmt.Sprintf(`{"seedBank": %q, "strain": %q}`, seedBank, strain),
+	}
+}
+
+func TestMapPlant(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("private plant with an unknown strain", func(t *testing.T) {
+		c := newMemoryCatalog()
+		plant := testPlant(false, "Unknown Seeds", "Unknown Kush")
+		if err := MapPlant(c, plant, now); err != nil {
+			t.Fatalf("MapPlant() error = %v", err)
+		}
+		want := []db.PlantStrain{{PlantID: plant.ID.UUID, MappedAt: now}}
+		if !reflect.DeepEqual(c.plantStrains, want) {
+			t.Errorf("plantStrains = %+v, want %+v", c.plantStrains, want)
+		}
+		if len(c.seedBanks) != 0 || len(c.strains) != 0 {
+			t.Errorf("private plant added seedBanks %v strains %v", c.seedBanks, c.strains)
+		}
+	})
+
+	t.Run("public plant adds its settings, private plants match them", func(t *testing.T) {
+		c := newMemoryCatalog()
+		public := testPlant(true, "Barney's Farm", "Blue Dream")
+		if err := MapPlant(c, public, now); err != nil {
+			t.Fatalf("MapPlant() error = %v", err)
+		}
+		if len(c.seedBanks) != 1 || len(c.strains) != 1 {
+			t.Fatalf("public plant added seedBanks %v strains %v", c.seedBanks, c.strains)
+		}
+		mapped := c.plantStrains[0]
+		if !mapped.SeedBankID.Valid || !mapped.StrainID.Valid {
+			t.Fatalf("public plant mapping = %+v", mapped)
+		}
+
+		private := testPlant(false, "  barney's FARM", "blue   dream ")
+		if err := MapPlant(c, private, now); err != nil {
+			t.Fatalf("MapPlant() error = %v", err)
+		}
+		want := db.PlantStrain{PlantID: private.ID.UUID, SeedBankID: mapped.SeedBankID, StrainID: mapped.StrainID, MappedAt: now}
+		if got := c.plantStrains[1]; !reflect.DeepEqual(got, want) {
+			t.Errorf("private plant mapping = %+v, want %+v", got, want)
+		}
+	})
+}
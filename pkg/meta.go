@@ -19,10 +19,8 @@
 package appbackend
 
 import (
-	"errors"
 	"time"
 
-	"github.com/go-redis/redis"
 	"github.com/sirupsen/logrus"
 )
 
@@ -37,9 +35,8 @@ type MetricsMeta struct {
 }
 
 type MetricsLoader func(device Device, from, to time.Time, module, metric string, i int) (TimeSeries, error)
-type GetLedBox func(i int) (int, error)
 
-func LoadMetricsMeta(device Device, box Box, from, to time.Time, loader MetricsLoader, getLedBox GetLedBox) MetricsMeta {
+func LoadMetricsMeta(device Device, box Box, from, to time.Time, loader MetricsLoader, topology DeviceTopology) MetricsMeta {
 	meta := MetricsMeta{Date: time.Now()}
 	if temp, err := loader(device, from, to, "BOX", "TEMP", int(*box.DeviceBox)); err == nil {
 		meta.Temperature = &temp
@@ -60,18 +57,11 @@ func LoadMetricsMeta(device Device, box Box, from, to time.Time, loader MetricsL
 		meta.Timer = &timer
 	}
 	dimmings := []TimeSeries{}
-	for i := 0; ; i += 1 {
-		if ledBox, err := getLedBox(i); err != nil || ledBox != int(*box.DeviceBox) {
-			if err != nil {
-				if !errors.Is(err, redis.Nil) {
-					logrus.Errorf("kv.GetLedBox in LoadMetricsMeta %q - box: %+v device: %+v i: %d", err, box, device, i)
-				}
-				break
+	if boxTopology, ok := topology.Box(int(*box.DeviceBox)); ok {
+		for _, i := range boxTopology.Leds {
+			if dimming, err := loader(device, from, to, "LED", "DIM", i); err == nil {
+				dimmings = append(dimmings, dimming)
 			}
-			continue
-		}
-		if dimming, err := loader(device, from, to, "LED", "DIM", i); err == nil {
-			dimmings = append(dimmings, dimming)
 		}
 	}
 	meta.Dimming = &dimmings
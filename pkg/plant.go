@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package appbackend
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PlantStages - settings dates starting each grow stage, in grow order
+var PlantStages = []struct {
+	Name    string
+	Setting string
+}{
+	{"germination", "germinationDate"},
+	{"veg", "veggingStart"},
+	{"bloom", "bloomingStart"},
+	{"drying", "dryingStart"},
+	{"curing", "curingStart"},
+}
+
+// PlantStageDefault - stage of the plants without any stage date
+const PlantStageDefault = "veg"
+
+// SettingString - string stored in the plant's settings, ie. strain, empty when not set
+func (o Plant) SettingString(setting string) string {
+	settings := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(o.Settings), &settings); err != nil {
+		return ""
+	}
+	str, _ := settings[setting].(string)
+	return str
+}
+
+// SettingDate - RFC3339 date stored in the plant's settings, ie. germinationDate
+func (o Plant) SettingDate(setting string) (time.Time, bool) {
+	dateStr := o.SettingString(setting)
+	if dateStr == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(time.RFC3339, dateStr)
+	return date, err == nil
+}
+
+// Lifetime - from germination, or creation when not set, to now, archived
+// plants end on their last update
+func (o Plant) Lifetime(now time.Time) (time.Time, time.Time) {
+	from := o.CreatedAt
+	if date, ok := o.SettingDate("germinationDate"); ok && date.Before(now) {
+		from = date
+	}
+	to := now
+	if o.Archived && o.UpdatedAt.Before(now) {
+		to = o.UpdatedAt
+	}
+	if to.Before(from) {
+		from = to
+	}
+	return from, to
+}
+
+// Stage - latest of PlantStages started before now
+func (o Plant) Stage(now time.Time) string {
+	stage := PlantStageDefault
+	for _, ps := range PlantStages {
+		if date, ok := o.SettingDate(ps.Setting); ok && !date.After(now) {
+			stage = ps.Name
+		}
+	}
+	return stage
+}
+
+// NextStageDate - earliest of PlantStages planned after now, the plant's
+// stage changes then
+func (o Plant) NextStageDate(now time.Time) (time.Time, bool) {
+	next, ok := time.Time{}, false
+	for _, ps := range PlantStages {
+		if date, found := o.SettingDate(ps.Setting); found && date.After(now) && (!ok || date.Before(next)) {
+			next, ok = date, true
+		}
+	}
+	return next, ok
+}
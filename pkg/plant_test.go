@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package appbackend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlantStage(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := func(d int) string { return now.Add(time.Duration(d) * 24 * time.Hour).Format(time.RFC3339) }
+	tests := []struct {
+		name     string
+		settings string
+		want     string
+	}{
+		{"no settings", ``, PlantStageDefault},
+		{"no stage date", `{"strain":"Blue Dream"}`, PlantStageDefault},
+		{"germinated", `{"germinationDate":"` + day(-3) + `"}`, "germination"},
+		{"vegging", `{"germinationDate":"` + day(-20) + `","veggingStart":"` + day(-10) + `"}`, "veg"},
+		{"blooming", `{"veggingStart":"` + day(-40) + `","bloomingStart":"` + day(-5) + `"}`, "bloom"},
+		{"curing", `{"bloomingStart":"` + day(-80) + `","dryingStart":"` + day(-20) + `","curingStart":"` + day(-10) + `"}`, "curing"},
+		{"stage starting now", `{"bloomingStart":"` + day(0) + `"}`, "bloom"},
+		{"planned stage", `{"veggingStart":"` + day(-10) + `","bloomingStart":"` + day(5) + `"}`, "veg"},
+		{"invalid date", `{"bloomingStart":"yesterday"}`, PlantStageDefault},
+	}
+	for _, tt := range tests {
+		if got := (Plant{Settings: tt.settings}).Stage(now); got != tt.want {
+			t.Errorf("%s: Stage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlantNextStageDate(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := func(d int) time.Time { return now.Add(time.Duration(d) * 24 * time.Hour) }
+	day := func(d int) string { return date(d).Format(time.RFC3339) }
+	tests := []struct {
+		name     string
+		settings string
+		want     time.Time
+		wantOK   bool
+	}{
+		{"no stage date", `{}`, time.Time{}, false},
+		{"past stages only", `{"veggingStart":"` + day(-10) + `"}`, time.Time{}, false},
+		{"stage starting now", `{"bloomingStart":"` + day(0) + `"}`, time.Time{}, false},
+		{"planned stage", `{"veggingStart":"` + day(-10) + `","bloomingStart":"` + day(5) + `"}`, date(5), true},
+		{"earliest planned stage", `{"dryingStart":"` + day(60) + `","bloomingStart":"` + day(5) + `"}`, date(5), true},
+	}
+	for _, tt := range tests {
+		got, ok := (Plant{Settings: tt.settings}).NextStageDate(now)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("%s: NextStageDate() = %s, %v, want %s, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
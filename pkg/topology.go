@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2021  SuperGreenLab <towelie@supergreenlab.com>
+ * Author: Constantin Clauzel <constantin.clauzel@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package appbackend
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// TopologyMaxBoxes - controllers have at most 3 boxes
+	TopologyMaxBoxes = 3
+	// TopologyMaxLeds - LED channels probed when loading the topology
+	TopologyMaxLeds = 12
+	// TopologyMaxSHT21 - SHT21 sensors probed when loading the topology
+	TopologyMaxSHT21 = 3
+)
+
+// DeviceTopology - how the controller's LED channels, sensors and blowers map to its boxes
+type DeviceTopology struct {
+	Identifier string           `json:"identifier"`
+	Boxes      []BoxTopology    `json:"boxes"`
+	Leds       []LedTopology    `json:"leds"`
+	Sensors    []SensorTopology `json:"sensors"`
+	LoadedAt   time.Time        `json:"loadedAt"`
+}
+
+type BoxTopology struct {
+	Index   int   `json:"index"`
+	Enabled bool  `json:"enabled"`
+	Leds    []int `json:"leds"`
+	// TempSource - 0 when the box has no sensor, n for SHT21_<n-1>
+	TempSource    int  `json:"tempSource"`
+	SensorPresent bool `json:"sensorPresent"`
+	Blower        bool `json:"blower"`
+}
+
+type LedTopology struct {
+	Index int `json:"index"`
+	Box   int `json:"box"`
+}
+
+type SensorTopology struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Present bool   `json:"present"`
+}
+
+// TopologyKeys - controller keys NewDeviceTopology reads
+func TopologyKeys() []string {
+	keys := []string{}
+	for i := 0; i < TopologyMaxBoxes; i++ {
+		keys = append(keys, fmt.Sprintf("BOX_%d_ENABLED", i), fmt.Sprintf("BOX_%d_TEMP_SOURCE", i), fmt.Sprintf("BOX_%d_BLOWER_DAY", i))
+	}
+	for i := 0; i < TopologyMaxLeds; i++ {
+		keys = append(keys, fmt.Sprintf("LED_%d_BOX", i))
+	}
+	for i := 0; i < TopologyMaxSHT21; i++ {
+		keys = append(keys, fmt.Sprintf("SHT21_%d_PRESENT", i))
+	}
+	return keys
+}
+
+// NewDeviceTopology - values are the controller's keys without the <cid>.KV. prefix,
+// missing keys mean the box, LED channel or sensor doesn't exist
+func NewDeviceTopology(identifier string, values map[string]string, loadedAt time.Time) DeviceTopology {
+	t := DeviceTopology{Identifier: identifier, Boxes: []BoxTopology{}, Leds: []LedTopology{}, Sensors: []SensorTopology{}, LoadedAt: loadedAt}
+	intValue := func(key string) (int, bool) {
+		v, ok := values[key]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+
+	for i := 0; i < TopologyMaxSHT21; i++ {
+		if present, ok := intValue(fmt.Sprintf("SHT21_%d_PRESENT", i)); ok {
+			t.Sensors = append(t.Sensors, SensorTopology{Type: "SHT21", Index: i, Present: present != 0})
+		}
+	}
+	for i := 0; i < TopologyMaxLeds; i++ {
+		if box, ok := intValue(fmt.Sprintf("LED_%d_BOX", i)); ok {
+			t.Leds = append(t.Leds, LedTopology{Index: i, Box: box})
+		}
+	}
+	for i := 0; i < TopologyMaxBoxes; i++ {
+		enabled, ok := intValue(fmt.Sprintf("BOX_%d_ENABLED", i))
+		if !ok {
+			continue
+		}
+		box := BoxTopology{Index: i, Enabled: enabled != 0, Leds: []int{}}
+		box.TempSource, _ = intValue(fmt.Sprintf("BOX_%d_TEMP_SOURCE", i))
+		if box.TempSource != 0 {
+			for _, s := range t.Sensors {
+				if s.Index == box.TempSource-1 {
+					box.SensorPresent = s.Present
+				}
+			}
+		}
+		_, box.Blower = values[fmt.Sprintf("BOX_%d_BLOWER_DAY", i)]
+		for _, l := range t.Leds {
+			if l.Box == i {
+				box.Leds = append(box.Leds, l.Index)
+			}
+		}
+		sort.Ints(box.Leds)
+		t.Boxes = append(t.Boxes, box)
+	}
+	return t
+}
+
+// Box - returns the box at the controller's box index
+func (t DeviceTopology) Box(i int) (BoxTopology, bool) {
+	for _, b := range t.Boxes {
+		if b.Index == i {
+			return b, true
+		}
+	}
+	return BoxTopology{}, false
+}